@@ -25,44 +25,20 @@ func main() {
 		spheres = append(spheres, geodesic.Chamfer(spheres[i]))
 	}
 
-	for x := 0; x < screen.Width; x++ {
-		//fmt.Println(x)
-		for y := 0; y < screen.Height; y++ {
-			angle := projection.Pixels[y*screen.Width+x]
-			idx := geodesic.Find(spheres, angle.Vector())
-			//idx2 := geodesic.NaiveFind(spheres[len(spheres)-1], angle.Vector())
-			border := false
-
-			if y > 0 {
-				if idx != geodesic.Find(spheres, projection.Pixels[(y-1)*screen.Width+x].Vector()) {
-					border = true
-				}
-			}
-			if !border && y < screen.Height - 1 {
-				if idx != geodesic.Find(spheres, projection.Pixels[(y+1)*screen.Width+x].Vector()) {
-					border = true
-				}
-			}
-			if !border && x > 0 {
-				if idx != geodesic.Find(spheres, projection.Pixels[y*screen.Width+(x-1)].Vector()) {
-					border = true
-				}
-			}
-			if !border && x < screen.Width - 1 {
-				if idx != geodesic.Find(spheres, projection.Pixels[(y*screen.Width)+(x+1)].Vector()) {
-					border = true
-				}
-			}
+	cells := make([]int, len(projection.Pixels))
+	for pidx, angle := range projection.Pixels {
+		cells[pidx] = geodesic.Find(spheres, angle.Vector())
+	}
 
-			if border {
-				img.Set(x, y, color.Gray{Y: 64})
-			} else {
-				img.Set(x, y, color.Gray{Y: 192})
-			}
+	for pidx, border := range projection.Borders(cells) {
+		x, y := pidx%screen.Width, pidx/screen.Width
+		if border {
+			img.Set(x, y, color.Gray{Y: 64})
+		} else {
+			img.Set(x, y, color.Gray{Y: 192})
 		}
 	}
 
-
 	out, err := os.Create("file-08.png")
 	if err != nil {
 		fmt.Println(err)
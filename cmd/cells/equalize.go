@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/willbeason/worldproc/pkg/geodesic"
 	"github.com/willbeason/worldproc/pkg/noise"
+	"github.com/willbeason/worldproc/pkg/planet"
 	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/sun"
 	"github.com/willbeason/worldproc/pkg/water"
 	"image"
 	"image/color"
@@ -93,16 +95,10 @@ func main() {
 			{threshold, color.RGBA{0, 0, 0, 255}},
 		})
 
-	pxWaterHeigts := make([]float64, screen.Width*screen.Height)
-	pxLandHeights := make([]float64, screen.Width*screen.Height)
-	for x := 0; x < screen.Width; x++ {
-		for y := 0; y < screen.Height; y++ {
-			pidx := y*screen.Width + x
-			angle := projection.Pixels[pidx]
-			idx := geodesic.Find(spheres, angle.Vector())
-			pxWaterHeigts[pidx] = waters[idx]
-			pxLandHeights[pidx] = heights[idx] / 2.6
-		}
+	cells := render.NewCellMap(projection, sphere)
+	pxWaterHeigts := make([]float64, len(cells.Cells))
+	for pidx, idx := range cells.Cells {
+		pxWaterHeigts[pidx] = waters[idx]
 	}
 	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
 	screen.Paint(pxWaterHeigts, csw, img)
@@ -120,8 +116,15 @@ func main() {
 			{1.0, color.RGBA{255, 255, 255, 255}},
 		})
 
+	p := &planet.Planet{Heights: heights}
+	shades := planet.DefaultHillshade.Shade(p, sphere, sun.Constant{})
+	pxLandHeights := cells.Sample(heights)
+	for pidx := range pxLandHeights {
+		pxLandHeights[pidx] /= 2.6
+	}
+	dry := make([]float64, len(cells.Cells))
 	img2 := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
-	screen.PaintAndShade(pxLandHeights, csl, img2)
+	screen.PaintLandWater(pxLandHeights, dry, cells.Sample(shades), csl, img2)
 
 	render.WriteImage(img, fmt.Sprintf("hydro-%d-e.png", iters))
 	render.WriteImage(img2, fmt.Sprintf("hydro-%d-f.png", iters))
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/willbeason/worldproc/pkg/geodesic"
 	"github.com/willbeason/worldproc/pkg/noise"
+	"github.com/willbeason/worldproc/pkg/planet"
 	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/sun"
 	"image"
 	"image/color"
 	"image/png"
@@ -28,20 +30,13 @@ func main() {
 	depth := 20
 	perlinNoise := noise.NewPerlinFractal(10, depth, 0.8)
 
-	heights := make([]float64, screen.Width*screen.Height)
-
 	sphere := spheres[nSpheres]
-	for x := 0; x < screen.Width; x++ {
-		//fmt.Println(x)
-		for y := 0; y < screen.Height; y++ {
-			pidx := y*screen.Width + x
-			angle := projection.Pixels[pidx]
-			idx := geodesic.Find(spheres, angle.Vector())
-			pos := sphere.Centers[idx]
-
-			heights[pidx] = perlinNoise.ValueAt(pos)
-		}
+	p := &planet.Planet{Heights: make([]float64, len(sphere.Centers))}
+	for cell, pos := range sphere.Centers {
+		p.Heights[cell] = perlinNoise.ValueAt(pos)
 	}
+	cells := render.NewCellMap(projection, sphere)
+	shades := planet.DefaultHillshade.Shade(p, sphere, sun.Constant{})
 
 	csl := render.NewColorScale(
 		[]render.ColorPoint{
@@ -57,7 +52,8 @@ func main() {
 		})
 
 	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
-	screen.PaintAndShade(heights, csl, img)
+	dry := make([]float64, len(cells.Cells))
+	screen.PaintLandWater(cells.Sample(p.Heights), dry, cells.Sample(shades), csl, img)
 
 	out, err := os.Create(fmt.Sprintf("map2-%d-%d.png", nSpheres, depth))
 	if err != nil {
@@ -8,7 +8,6 @@ import (
 	"github.com/willbeason/worldproc/pkg/render"
 	"github.com/willbeason/worldproc/pkg/water"
 	"image"
-	"math"
 	"math/rand"
 	"sort"
 	"time"
@@ -27,7 +26,7 @@ func main() {
 	spheres := geodesic.New(size, false)
 
 	depth := 30
-	perlinNoise := noise.NewPerlinFractal(10, depth, 0.8)
+	perlinNoise := noise.NewPerlinFractal(seed, 10, depth, 0.8)
 
 	sphere := spheres[len(spheres)-1]
 
@@ -57,13 +56,13 @@ func main() {
 	avgWater := oceanWater / float64(len(sortedHeights)/2)
 	fmt.Println(halfway, avgWater)
 
-	projection := render.Project(screen, render.Equirectangular{})
+	cells := render.NewCellMap(render.Project(screen, render.Equirectangular{}), sphere)
 
 	quanta := 0.01
 	iters := int(avgWater / quanta)
 	fmt.Println("Total Iters:", iters)
 
-	renderImg(seed, projection, spheres, p.Heights, p.Waters, p.Flows, 0)
+	renderImg(seed, cells, p.Heights, p.Waters, p.Flows, 0)
 	for iter := 1; iter < iters; iter++ {
 		fmt.Print(iter, "...", "Raining")
 		water.Rain(quanta, p.Waters, p.Heights, p.Flows, sphere)
@@ -71,57 +70,33 @@ func main() {
 		if iter%5 == 0 {
 			fmt.Print("...", "Equalizing")
 			water.Equalize(p.Waters, p.Heights, sphere)
-			renderImg(seed, projection, spheres, p.Heights, p.Waters, p.Flows, iter)
+			renderImg(seed, cells, p.Heights, p.Waters, p.Flows, iter)
 		}
 
 		fmt.Println()
 	}
 
 	water.Equalize(p.Waters, p.Heights, sphere)
-	renderImg(seed, projection, spheres, p.Heights, p.Waters, p.Flows, iters)
-
-
+	renderImg(seed, cells, p.Heights, p.Waters, p.Flows, iters)
 }
 
-func renderImg(seed int64, projection render.Projection, spheres []*geodesic.Geodesic, heights, waters, flow []float64, id int) {
-	screen := projection.Screen
+func renderImg(seed int64, cells *render.CellMap, heights, waters, flow []float64, id int) {
+	screen := cells.Screen
 	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
 
-	pxWaterHeights := make([]float64, screen.Width*screen.Height)
-	pxLandHeights := make([]float64, screen.Width*screen.Height)
-
-	sphere := spheres[len(spheres)-1]
-	for x := 0; x < screen.Width; x++ {
-		for y := 0; y < screen.Height; y++ {
-			pidx := y*screen.Width + x
-			angle := projection.Pixels[pidx]
-			v := angle.Vector()
-			idx := geodesic.Find(spheres, v)
-			dist := math.Sqrt(geodesic.DistSq(v, sphere.Centers[idx]))
-
-			pxW1 := waters[idx] + flow[idx]/2000.0
-			pxH1 := heights[idx]
-
-			// Linearly interpolate the cell's stats with the second-closest cell.
-			idx2 := 0
-			distSq2 := math.MaxFloat64
-			for _, n := range sphere.Faces[idx].Neighbors {
-				nDistSq2 := geodesic.DistSq(v, sphere.Centers[n])
-				if nDistSq2 < distSq2 {
-					idx2 = n
-					distSq2 = nDistSq2
-				}
-			}
-			dist2 := math.Sqrt(distSq2)
-			pxW2 := waters[idx2] + flow[idx2]/2000.0
-			pxH2 := heights[idx2]
-
-			pxWaterHeights[pidx] = render.Lerp(pxW1, pxW2, dist/(dist+dist2))
-			pxLandHeights[pidx] = render.Lerp(pxH1, pxH2, dist/(dist+dist2))
-		}
+	cellWaters := make([]float64, len(waters))
+	for i := range cellWaters {
+		cellWaters[i] = waters[i] + flow[i]/2000.0
 	}
+	pxWaterHeights := cells.Sample(cellWaters)
+	pxLandHeights := cells.Sample(heights)
 
-	screen.PaintLandWater(pxLandHeights, pxWaterHeights, img)
+	// The land is lit evenly.
+	shades := make([]float64, len(pxLandHeights))
+	for i := range shades {
+		shades[i] = 1.0
+	}
+	screen.PaintLandWater(pxLandHeights, pxWaterHeights, shades, render.LandColorScale, img)
 
 	render.WriteImage(img, fmt.Sprintf("renders/hydro-%d-%d.png", seed, id))
 }
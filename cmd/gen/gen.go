@@ -4,39 +4,113 @@ import (
 	"flag"
 	"fmt"
 	"github.com/willbeason/worldproc/pkg/climate"
+	"github.com/willbeason/worldproc/pkg/erosion"
+	"github.com/willbeason/worldproc/pkg/export"
 	"github.com/willbeason/worldproc/pkg/geodesic"
 	"github.com/willbeason/worldproc/pkg/noise"
 	"github.com/willbeason/worldproc/pkg/planet"
 	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/store"
 	"github.com/willbeason/worldproc/pkg/sun"
+	"github.com/willbeason/worldproc/pkg/tiles"
 	"image"
+	"image/color"
 	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"time"
 )
 
 var seed = flag.Int64("seed", time.Now().UnixNano(),
 	"The seed of the planet to generate")
 
+var erosionIterations = flag.Int("erosion", erosion.Default.Iterations,
+	"How many times to erode new terrain, or 0 to leave it as generated")
+
+var meshFile = flag.String("mesh", "",
+	"If set, the .glb, .obj or .ply file to write the planet's surface to")
+
+var exaggeration = flag.Float64("exaggeration", 0.05,
+	"How far to raise the surface of the mesh per unit of height")
+
+var heightmapFile = flag.String("heightmap", "",
+	"If set, the .png, .pfm, .tif or .raw file to write the planet's heights to, as rendered")
+
+var geoTIFFFile = flag.String("geotiff", "",
+	"If set, the file to write an equirectangular render of the planet to as a GeoTIFF")
+
+var riversFile = flag.String("rivers", "",
+	"If set, the .geojson file to write the planet's rivers to, which are also drawn on a render")
+
+var riverFlow = flag.Float64("river-flow", 20,
+	"The least flow a channel carries to be a river")
+
+var radius = flag.Float64("radius", export.DefaultRadius,
+	"The radius of the planet in meters, recorded in georeferenced files")
+
+var tilesPath = flag.String("tiles", "",
+	"If set, the directory or .wpa archive to write Web Mercator map tiles of the planet to")
+
+var maxZoom = flag.Int("zoom", 3,
+	"The deepest zoom level of map tiles to write")
+
+var cubeMapPath = flag.String("cubemap", "",
+	"If set, the directory or .wpa archive to write a cube map of the planet to")
+
+var animation = flag.String("animation", "frames",
+	"How to write the climate simulation: frames for one PNG per step, or gif or apng for animations")
+
+var timestamp = flag.Bool("timestamp", true,
+	"Whether to write the simulated date on each frame of the climate simulation")
+
+var palettesPath = flag.String("palettes", "",
+	"If set, a directory of palette files to paint with instead of the defaults, named land, temperature, wind or pressure with a .json, .yaml or .yml extension")
+
+var legend = flag.Bool("legend", false,
+	"Whether to draw a legend of the color scale on each render of the climate")
+
+var snapshotEvery = flag.Int("snapshots", 0,
+	"If set, how many steps of the climate simulation to save a snapshot for worldproc serve after")
+
 func main() {
 	flag.Parse()
 	rand.Seed(*seed)
+	if *palettesPath != "" {
+		loadPalettes(*palettesPath)
+	}
 
 	size := 9
 	spheres := geodesic.New(size, false)
 
 	sphere := spheres[size]
-	p := loadOrCreate(*seed, size, sphere)
+	p := loadOrCreate(*seed, spheres)
+	if *meshFile != "" {
+		writeMesh(p, sphere, *meshFile)
+	}
+	if *tilesPath != "" || *cubeMapPath != "" {
+		writeTiles(&tiles.Renderer{Planet: p, Sphere: sphere, ColorScale: colorScales[landPalette]})
+	}
 	screen := render.Screen{
 		Width:  1920,
 		Height: 960,
 	}
 	projection := render.Project(screen, render.Equirectangular{})
-	renderImg(*seed, "", projection, spheres, sun.Constant{}, p)
+	cells := render.NewCellMap(projection, sphere)
+	renderImg(*seed, "", sphere, cells, sun.Constant{}, p)
+	if *heightmapFile != "" {
+		writeHeightmap(p, cells, *heightmapFile)
+	}
+	if *geoTIFFFile != "" {
+		writeGeoTIFF(p, sphere, cells, *geoTIFFFile)
+	}
+	if *riversFile != "" {
+		writeRivers(p, sphere, cells, *riversFile)
+	}
 
 	if len(p.Climates) == 0 {
 		fmt.Println("Initializing Climate")
-		initializeClimate(p, sphere, spheres, projection)
+		initializeClimate(p, sphere)
 		planet.Save(*seed, p)
 	} else {
 		fmt.Println("Loaded Climate")
@@ -52,7 +126,8 @@ func main() {
 	nDiffuse := 1
 	nWind := 10
 	light := &sun.Directional{}
-	idx := 0
+	sinks := climateSinks(*seed)
+	step, nSnapshots := 0, 0
 	for day := 0; day < 20; day++ {
 		for i := 0; i < imax; i++ {
 			t := float64(day) + float64(i) / float64(imax)
@@ -73,12 +148,24 @@ func main() {
 			fmt.Println()
 
 			// Heat up for a year before rendering.
-			RenderClimate(*seed, idx, projection, spheres, p.Climates)
-			idx++
+			RenderClimate(sinks, t, cells, p.Climates)
+			if *snapshotEvery > 0 && step%*snapshotEvery == 0 {
+				planet.SaveSnapshot(*seed, nSnapshots, &planet.Snapshot{Date: t, Climates: p.Climates})
+				nSnapshots++
+			}
+			step++
 
 			printAveragePressure(p.Climates)
 		}
 	}
+
+	for _, sink := range sinks {
+		err := sink.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
 }
 
 func printAveragePressure(climates []climate.Climate) {
@@ -92,7 +179,7 @@ func printAveragePressure(climates []climate.Climate) {
 	fmt.Printf("Mean Velocity: %.04f\n", totV / float64(len(climates)))
 }
 
-func initializeClimate(p *planet.Planet, sphere *geodesic.Geodesic, spheres []*geodesic.Geodesic, projection render.Projection) {
+func initializeClimate(p *planet.Planet, sphere *geodesic.Geodesic) {
 	light := &sun.Directional{}
 	p.Climates = make([]climate.Climate, len(p.Heights))
 	for i, w := range p.Waters {
@@ -131,7 +218,7 @@ func initializeClimate(p *planet.Planet, sphere *geodesic.Geodesic, spheres []*g
 
 			//if day >= 360 || i == 0 {
 			//	// Heat up for a year before rendering.
-			//	RenderClimate(*seed, idx, projection, spheres, p.Climates)
+			//	RenderClimate(*seed, idx, cells, p.Climates)
 			//	idx++
 			//}
 		}
@@ -179,8 +266,9 @@ func diffuseHeat(climates []climate.Climate, sphere *geodesic.Geodesic, seconds
 	}
 }
 
-func loadOrCreate(seed int64, size int, sphere *geodesic.Geodesic) *planet.Planet {
-	p := planet.Load(seed, size)
+func loadOrCreate(seed int64, spheres []*geodesic.Geodesic) *planet.Planet {
+	sphere := spheres[len(spheres)-1]
+	p := planet.Load(seed, spheres)
 	mutated := false
 	if p == nil {
 		p = &planet.Planet{}
@@ -189,6 +277,12 @@ func loadOrCreate(seed int64, size int, sphere *geodesic.Geodesic) *planet.Plane
 	if len(p.Heights) == 0 {
 		perlinNoise := noise.NewPerlinFractal(seed, 10, 30, 0.6)
 		planet.AddTerrain(p, sphere, perlinNoise)
+		if *erosionIterations > 0 {
+			fmt.Println("Eroding Terrain")
+			e := erosion.Default
+			e.Iterations = *erosionIterations
+			e.Erode(p.Heights, sphere, seed)
+		}
 		mutated = true
 	}
 	if len(p.Waters) == 0 {
@@ -201,68 +295,237 @@ func loadOrCreate(seed int64, size int, sphere *geodesic.Geodesic) *planet.Plane
 	return p
 }
 
-func RenderClimate(seed int64, idx int, projection render.Projection, spheres []*geodesic.Geodesic, climates []climate.Climate) {
-	img, img2, img3 := renderClimate(projection, spheres, climates)
-	n := 17
-	render.WriteImage(img, fmt.Sprintf("renders/wind-test-%d/temp-%d-%03d.png", n, seed, idx))
-	render.WriteImage(img2, fmt.Sprintf("renders/wind-test-%d/wind-%d-%03d.png", n, seed, idx))
-	render.WriteImage(img3, fmt.Sprintf("renders/wind-test-%d/pressure-%d-%03d.png", n, seed, idx))
+func writeMesh(p *planet.Planet, sphere *geodesic.Geodesic, file string) {
+	m, err := export.FromPlanet(p, sphere, *exaggeration, colorScales[landPalette])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = export.Write(m, file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
 
-func renderImg(seed int64, name string, projection render.Projection, spheres []*geodesic.Geodesic, light sun.Light, p *planet.Planet) {
-	img := planet.RenderTerrain(p, projection, spheres, light)
-	render.WriteImage(img, fmt.Sprintf("renders/%d-%s.png", seed, name))
+func writeHeightmap(p *planet.Planet, cells *render.CellMap, file string) {
+	r, err := export.RasterFromLayer(p, planet.HeightsLayer, cells)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	r.Radius = *radius
+
+	err = export.WriteRaster(r, file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
 
-func renderClimate(projection render.Projection, spheres []*geodesic.Geodesic, climates []climate.Climate) (*image.RGBA, *image.RGBA, *image.RGBA) {
-	screen := projection.Screen
+func writeGeoTIFF(p *planet.Planet, sphere *geodesic.Geodesic, cells *render.CellMap, file string) {
+	img := planet.RenderTerrain(p, sphere, cells, sun.Constant{}, colorScales[landPalette])
 
-	pxTemperatures := make([]float64, screen.Width*screen.Height)
-	pxAirVelocities := make([]float64, screen.Width*screen.Height)
-	pxAirPressures := make([]float64, screen.Width*screen.Height)
+	out, err := os.Create(file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	sphere := spheres[len(spheres)-1]
-	for x := 0; x < screen.Width; x++ {
-		for y := 0; y < screen.Height; y++ {
-			pidx := y*screen.Width + x
-			angle := projection.Pixels[pidx]
-			v := angle.Vector()
-			idx := geodesic.Find(spheres, v)
-			dist := math.Sqrt(geodesic.DistSq(v, sphere.Centers[idx]))
-
-			pxT1 := climates[idx].AirTemperature()
-			pxA1 := climates[idx].AirVelocity.Length()
-			pxP1 := climates[idx].Pressure()
-
-			// Linearly interpolate the cell's stats with the second-closest cell.
-			idx2 := 0
-			distSq2 := math.MaxFloat64
-			for _, n := range sphere.Faces[idx].Neighbors {
-				nDistSq2 := geodesic.DistSq(v, sphere.Centers[n])
-				if nDistSq2 < distSq2 {
-					idx2 = n
-					distSq2 = nDistSq2
-				}
-			}
-			dist2 := math.Sqrt(distSq2)
-			pxT2 := climates[idx2].AirTemperature()
-			pxA2 := climates[idx2].AirVelocity.Length()
-			pxP2 := climates[idx2].Pressure()
-
-			pxTemperatures[pidx] = render.Lerp(pxT1, pxT2, dist/(dist+dist2))
-			pxAirVelocities[pidx] = render.Lerp(pxA1, pxA2, dist/(dist+dist2))
-			pxAirPressures[pidx] = render.Lerp(pxP1, pxP2, dist/(dist+dist2))
+	err = export.WriteGeoTIFF(out, img, cells.Projection, *radius)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = out.Close()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func writeRivers(p *planet.Planet, sphere *geodesic.Geodesic, cells *render.CellMap, file string) {
+	rivers := planet.Rivers(p, sphere, *riverFlow)
+
+	out, err := os.Create(file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = export.WriteRivers(out, rivers, sphere)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = out.Close()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	img := planet.RenderTerrain(p, sphere, cells, sun.Constant{}, colorScales[landPalette])
+	planet.DrawRivers(render.NewOverlay(cells.Projection, img), rivers, sphere, planet.RiverColor)
+	render.WriteImage(img, fmt.Sprintf("renders/%d-rivers.png", *seed))
+}
+
+func writeTiles(r *tiles.Renderer) {
+	if *tilesPath != "" {
+		err := withStore(*tilesPath, func(s store.Store) error {
+			return r.Pyramid(s, tiles.WebMercator{}, 0, *maxZoom)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
 	}
 
-	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
-	screen.PaintTemperature(pxTemperatures, img)
+	if *cubeMapPath != "" {
+		err := withStore(*cubeMapPath, r.CubeMap)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+}
 
-	img2 := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
-	screen.PaintAirVelocities(pxAirVelocities, img2)
+// withStore calls fn with an archive at path if it ends in .wpa, or with the
+// directory at path otherwise.
+func withStore(path string, fn func(s store.Store) error) error {
+	if filepath.Ext(path) != ".wpa" {
+		return fn(store.Dir(path))
+	}
+
+	archive, err := store.CreateArchive(path)
+	if err != nil {
+		return err
+	}
+	err = fn(archive)
+	if err != nil {
+		_ = archive.Close()
+		return err
+	}
+	return archive.Close()
+}
 
-	img3 := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
-	screen.PaintAirPressure(pxAirPressures, img3)
+// climateSinks returns the sinks RenderClimate writes temperature, wind and
+// pressure to, in the format of the animation flag.
+func climateSinks(seed int64) []*render.Captioned {
+	n := 17
+	var sinks []*render.Captioned
+	for _, name := range []string{"temp", "wind", "pressure"} {
+		var file string
+		switch *animation {
+		case "frames":
+			file = fmt.Sprintf("renders/wind-test-%d/%s-%d-%%03d.png", n, name, seed)
+		case "gif":
+			file = fmt.Sprintf("renders/wind-test-%d/%s-%d.gif", n, name, seed)
+		case "apng":
+			file = fmt.Sprintf("renders/wind-test-%d/%s-%d.png", n, name, seed)
+		default:
+			fmt.Printf("unknown animation format %q\n", *animation)
+			os.Exit(1)
+		}
+
+		sink, err := render.CreateAnimation(file, time.Second/24)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, &render.Captioned{
+			Sink:       sink,
+			TextScale:  2,
+			Color:      color.RGBA{R: 255, G: 255, B: 255, A: 255},
+			Background: color.RGBA{A: 160},
+		})
+	}
+	return sinks
+}
+
+// RenderClimate writes the temperature, wind and pressure of climates at date
+// to sinks.
+func RenderClimate(sinks []*render.Captioned, date float64, cells *render.CellMap, climates []climate.Climate) {
+	img, img2, img3 := renderClimate(cells, climates)
+	for i, img := range []*image.RGBA{img, img2, img3} {
+		if *timestamp {
+			sinks[i].Text = sun.FormatDate(date)
+		}
+		err := sinks[i].WriteFrame(img)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func renderImg(seed int64, name string, sphere *geodesic.Geodesic, cells *render.CellMap, light sun.Light, p *planet.Planet) {
+	img := planet.RenderTerrain(p, sphere, cells, light, colorScales[landPalette])
+	render.WriteImage(img, fmt.Sprintf("renders/%d-%s.png", seed, name))
+}
+
+func renderClimate(cells *render.CellMap, climates []climate.Climate) (*image.RGBA, *image.RGBA, *image.RGBA) {
+	temperatures := make([]float64, len(climates))
+	airVelocities := make([]float64, len(climates))
+	airPressures := make([]float64, len(climates))
+	for i := range climates {
+		temperatures[i] = climates[i].AirTemperature()
+		airVelocities[i] = climates[i].AirVelocity.Length()
+		airPressures[i] = climates[i].Pressure()
+	}
+
+	img := paintClimate(cells, temperatures, planet.TemperatureLayer, "Temperature (K)")
+	img2 := paintClimate(cells, airVelocities, planet.WindLayer, "Wind")
+	img3 := paintClimate(cells, airPressures, planet.PressureLayer, "Pressure")
 
 	return img, img2, img3
 }
+
+// paintClimate paints values with the color scale of the palette named name,
+// with a legend titled title if the legend flag is set.
+func paintClimate(cells *render.CellMap, values []float64, name, title string) *image.RGBA {
+	screen := cells.Screen
+	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
+	cs := colorScales[name]
+	screen.Paint(cells.Sample(values), cs, img)
+
+	if *legend {
+		// Captions are in the bottom left corner, so keep out of their way.
+		l := render.Legend{
+			Scale:      cs,
+			Title:      title,
+			TextScale:  2,
+			Color:      color.RGBA{R: 255, G: 255, B: 255, A: 255},
+			Background: color.RGBA{A: 160},
+		}
+		width, height := l.Size()
+		l.Draw(img, screen.Width-width, screen.Height-height)
+	}
+	return img
+}
+
+// landPalette names the palette of the color of land by its height. The
+// others are named after the climate layer they paint.
+const landPalette = "land"
+
+// colorScales are the color scales to paint with, by the name of their
+// palette.
+var colorScales = map[string]*render.ColorScale{
+	landPalette:             render.LandColorScale,
+	planet.TemperatureLayer: render.TemperatureColorScale,
+	planet.WindLayer:        render.AirVelocityColorScale,
+	planet.PressureLayer:    render.AirPressureColorScale,
+}
+
+// loadPalettes replaces the color scales with any palette files in dir named
+// after them.
+func loadPalettes(dir string) {
+	err := render.LoadPalettes(dir, colorScales)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/willbeason/worldproc/pkg/geodesic"
 	"github.com/willbeason/worldproc/pkg/render"
 	"image"
 	"image/color"
-	"image/png"
 	"math"
 	"os"
+	"time"
 )
 
+var out = flag.String("out", "renders/tiling/frame-%03d.png",
+	"The .gif or .png file to write the animation to, or the pattern of the files to write each frame to")
+
 func findCell(spheres []*geodesic.Geodesic, v geodesic.Vector, weight float64) int {
 	previous := geodesic.Find(spheres[:len(spheres)-1], v)
 	next := geodesic.Find(spheres, v)
@@ -37,32 +41,12 @@ func drawBorders(spheres []*geodesic.Geodesic, projection render.Projection, wei
 		cellId[i] = findCell(spheres, px.Vector(), weight)
 	}
 
-	isBorder := make([]bool, len(projection.Pixels))
-	for i, cell := range cellId {
-		x := i / projection.Width
-		y := i % projection.Width
-
-		if y > 0 && cellId[i-1] != cell {
-			isBorder[i] = true
-			continue
-		}
-		if y < projection.Width - 1 && cellId[i+1] != cell {
-			isBorder[i] = true
-			continue
-		}
-		if x > 0 && cellId[i - projection.Width] != cell {
-			isBorder[i] = true
-			continue
-		}
-		if x < projection.Height - 1 && cellId[i + projection.Width] != cell {
-			isBorder[i] = true
-			continue
-		}
-	}
-	return isBorder
+	return projection.Borders(cellId)
 }
 
 func main() {
+	flag.Parse()
+
 	screen := render.Screen{
 		Width:  1500,
 		Height: 750,
@@ -72,6 +56,12 @@ func main() {
 
 	spheres := []*geodesic.Geodesic{geodesic.Dodecahedron()}
 
+	sink, err := render.CreateAnimation(*out, time.Second/24)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	for frame := 0; frame < 120; frame++ {
 		fmt.Printf("Frame %03d\n", frame)
 		if frame % 24 == 0 {
@@ -92,22 +82,16 @@ func main() {
 			}
 		}
 
-		out, err := os.Create(fmt.Sprintf("renders/tiling/frame-%03d.png", frame))
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-
-		err = png.Encode(out, img)
+		err = sink.WriteFrame(img)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+	}
 
-		err = out.Close()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+	err = sink.Close()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 }
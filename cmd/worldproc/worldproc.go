@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/serve"
+	"net/http"
+	"os"
+)
+
+const usage = `Usage: worldproc <command> [flags]
+
+Commands:
+  serve  view a saved planet on a globe in the browser
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "serve":
+		runServe(os.Args[2:])
+	default:
+		fmt.Printf("unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// landPalette names the palette of the color of land by its height. The
+// others are named after the climate layer they paint.
+const landPalette = "land"
+
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	seed := flags.Int64("seed", 0,
+		"The seed of the saved planet to serve")
+	size := flags.Int("size", 9,
+		"The size of the sphere to show the planet on")
+	addr := flags.String("addr", "localhost:8080",
+		"The address to serve the viewer at")
+	palettes := flags.String("palettes", "",
+		"If set, a directory of palette files to paint with instead of the defaults, named land, temperature, wind or pressure with a .json, .yaml or .yml extension")
+	_ = flags.Parse(args)
+
+	colorScales := map[string]*render.ColorScale{landPalette: render.LandColorScale}
+	for name, cs := range serve.DefaultColorScales {
+		colorScales[name] = cs
+	}
+	if *palettes != "" {
+		err := render.LoadPalettes(*palettes, colorScales)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	land := colorScales[landPalette]
+	delete(colorScales, landPalette)
+
+	spheres := geodesic.New(*size, false)
+	p := planet.Load(*seed, spheres)
+	if p == nil {
+		fmt.Printf("no saved planet with seed %d\n", *seed)
+		os.Exit(1)
+	}
+	snapshots := planet.LoadSnapshots(*seed, spheres)
+
+	s := &serve.Server{
+		Planet:    p,
+		Sphere:    spheres[*size],
+		Snapshots: snapshots,
+
+		ColorScales: colorScales,
+		Land:        land,
+	}
+	fmt.Printf("Serving planet %d with %d snapshots at http://%s/\n", *seed, len(snapshots), *addr)
+	err := http.ListenAndServe(*addr, s)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
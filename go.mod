@@ -2,4 +2,7 @@ module github.com/willbeason/worldproc
 
 go 1.14
 
-require github.com/google/go-cmp v0.5.1
+require (
+	github.com/google/go-cmp v0.5.1
+	gopkg.in/yaml.v3 v3.0.1
+)
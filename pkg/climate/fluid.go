@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/willbeason/worldproc/pkg/geodesic"
 	"math"
-	"sort"
 	"sync"
 )
 
@@ -36,12 +35,6 @@ type airDelta struct {
 	theta0, theta1 float64
 }
 
-type airNeighbor struct {
-	idx        int
-	toNeighbor geodesic.Vector
-	theta      float64
-}
-
 func Flow(climates []Climate, sphere *geodesic.Geodesic, minutes float64) {
 	fmt.Print("A")
 	// Precalculate the pressure everywhere.
@@ -125,23 +118,28 @@ func calculateDelta(i int, airVelocity geodesic.Vector, air, airEnergy float64,
 	}
 	norm := airVelocity.Normalize()
 
-	aNeighbors := make([]airNeighbor, len(neighbors))
-	for i, n := range neighbors {
-		aNeighbors[i].idx = n
-		aNeighbors[i].toNeighbor = sphere.Centers[n].Sub(center).Normalize()
-		aNeighbors[i].theta = math.Acos(aNeighbors[i].toNeighbor.Dot(norm))
+	thetas := make([]float64, len(neighbors))
+	closest := 0
+	for k, n := range neighbors {
+		toNeighbor := sphere.Centers[n].Sub(center).Normalize()
+		thetas[k] = math.Acos(toNeighbor.Dot(norm))
+		if thetas[k] < thetas[closest] {
+			closest = k
+		}
 	}
 
-	sort.Slice(aNeighbors, func(i, j int) bool {
-		// Smallest angles first
-		return aNeighbors[i].theta < aNeighbors[j].theta
-	})
+	// Neighbors are ordered around the face, so the next closest to the
+	// direction of the wind is on one side or the other of the closest.
+	next := (closest + 1) % len(neighbors)
+	if prev := (closest + len(neighbors) - 1) % len(neighbors); thetas[prev] < thetas[next] {
+		next = prev
+	}
 
-	n0 := aNeighbors[0].idx
-	n1 := aNeighbors[1].idx
+	n0 := neighbors[closest]
+	n1 := neighbors[next]
 
-	theta0 := aNeighbors[0].theta
-	theta1 := aNeighbors[1].theta
+	theta0 := thetas[closest]
+	theta1 := thetas[next]
 
 	// Don't let pressure get below 0.01.
 	// Delta air is half of the velocity.
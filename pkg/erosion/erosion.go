@@ -0,0 +1,286 @@
+// Package erosion wears terrain down with the water running over it, carving
+// valleys where it flows and filling basins and seas with what it carries.
+package erosion
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/water"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Erosion is a model of how terrain erodes.
+//
+// Heights and slopes are as on the unit sphere, so slopes are in height per
+// radian and drainage areas are in steradians. The same rates erode spheres
+// of every size alike.
+type Erosion struct {
+	// Iterations is how many times to erode.
+	Iterations int
+
+	// Incision is how fast rivers cut into the land, by the stream power law:
+	// each iteration lowers a cell by Incision times its drainage area to the
+	// power of AreaExponent times its slope toward where it drains. Cells
+	// are never lowered below where they drain to.
+	Incision     float64
+	AreaExponent float64
+
+	// RainVariation is how much rain varies from cell to cell and from
+	// iteration to iteration, as a fraction of the average.
+	RainVariation float64
+
+	// TalusSlope is the steepest slope loose material rests at, and
+	// ThermalRate the fraction of the excess height over it which slides
+	// downhill each iteration. ThermalRate should be at most 1/6 so no cell
+	// sheds more than it has in excess.
+	TalusSlope  float64
+	ThermalRate float64
+
+	// SeaCoverage is the fraction of the surface below sea level, where
+	// rivers end and drop what they carry.
+	SeaCoverage float64
+}
+
+// Default is a moderate amount of erosion, with the sea covering as much of
+// the planet as cmd/gen floods.
+var Default = Erosion{
+	Iterations:    20,
+	Incision:      0.005,
+	AreaExponent:  0.5,
+	RainVariation: 0.5,
+	TalusSlope:    4.0,
+	ThermalRate:   0.1,
+	SeaCoverage:   0.5,
+}
+
+// Erode wears down heights on sphere. Rain falls as seed decides, so eroding
+// the same heights with the same seed always gives the same result.
+//
+// Material is moved rather than lost, so the sum of heights weighted by the
+// areas of their cells doesn't change: what rivers carve away is carried
+// downstream and dropped in the first basin or sea it reaches, filling basins
+// no higher than where they overflow and the sea floor no higher than sea
+// level.
+func (e *Erosion) Erode(heights []float64, sphere *geodesic.Geodesic, seed int64) {
+	if len(heights) == 0 {
+		return
+	}
+	r := rand.New(rand.NewSource(seed))
+	seaLevel := SeaLevel(heights, e.SeaCoverage)
+	distances := neighborDistances(sphere)
+	areas := sphere.Geometry().Areas
+	fill := newSeaFill(len(heights))
+
+	for i := 0; i < e.Iterations; i++ {
+		rain := make([]float64, len(heights))
+		for c := range rain {
+			rain[c] = areas[c] * (1 + e.RainVariation*(2*r.Float64()-1))
+		}
+
+		e.incise(heights, sphere, distances, areas, rain, seaLevel, fill)
+		e.relax(heights, sphere, distances, areas)
+	}
+}
+
+// SeaLevel returns the height below which coverage of heights lies.
+func SeaLevel(heights []float64, coverage float64) float64 {
+	sorted := make([]float64, len(heights))
+	copy(sorted, heights)
+	sort.Float64s(sorted)
+
+	idx := int(float64(len(sorted)) * coverage)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// neighborDistances returns the distance from each cell to each of its
+// neighbors, in the same order as their Neighbors.
+func neighborDistances(sphere *geodesic.Geodesic) [][]float64 {
+	result := make([][]float64, len(sphere.Faces))
+	for i, face := range sphere.Faces {
+		result[i] = make([]float64, len(face.Neighbors))
+		for k, n := range face.Neighbors {
+			result[i][k] = sphere.Centers[i].Sub(sphere.Centers[n]).Length()
+		}
+	}
+	return result
+}
+
+// minSea is the smallest fraction of the surface below sea level which is
+// sea. Smaller hollows are basins, which fill and overflow.
+const minSea = 0.001
+
+// seaCells returns which cells are sea: those below sea level and connected
+// to at least minSea of the surface below it.
+func seaCells(heights []float64, sphere *geodesic.Geodesic, areas []float64, seaLevel float64) []bool {
+	result := make([]bool, len(heights))
+	visited := make([]bool, len(heights))
+	for start, h := range heights {
+		if visited[start] || h >= seaLevel {
+			continue
+		}
+
+		visited[start] = true
+		body := []int{start}
+		area := 0.0
+		for i := 0; i < len(body); i++ {
+			area += areas[body[i]]
+			for _, n := range sphere.Faces[body[i]].Neighbors {
+				if !visited[n] && heights[n] < seaLevel {
+					visited[n] = true
+					body = append(body, n)
+				}
+			}
+		}
+
+		if area >= minSea*4*math.Pi {
+			for _, c := range body {
+				result[c] = true
+			}
+		}
+	}
+	return result
+}
+
+// distanceTo returns the distance from cell i to its neighbor n.
+func distanceTo(sphere *geodesic.Geodesic, distances [][]float64, i, n int) float64 {
+	for k, neighbor := range sphere.Faces[i].Neighbors {
+		if neighbor == n {
+			return distances[i][k]
+		}
+	}
+	return sphere.Centers[i].Sub(sphere.Centers[n]).Length()
+}
+
+// incise lowers heights where rain runs over them, and drops what it carves
+// away in basins and the sea. Sediment is carried as volume, the height it
+// was carved from times the area of its cell.
+func (e *Erosion) incise(heights []float64, sphere *geodesic.Geodesic, distances [][]float64, areas, rain []float64, seaLevel float64, fill *seaFill) {
+	sea := seaCells(heights, sphere, areas, seaLevel)
+	var outlets []int
+	for c, isSea := range sea {
+		if isSea {
+			outlets = append(outlets, c)
+		}
+	}
+	d := water.Drain(heights, sphere, outlets)
+	drained := d.Accumulate(rain)
+
+	// Solve for the new heights implicitly from the sea upward, so each
+	// cell is lowered toward the new height of the cell it drains into and
+	// never past it however fast it erodes.
+	// Water in basins is still, so carves nothing.
+	inBasin := make([]bool, len(heights))
+	for c, h := range heights {
+		inBasin[c] = d.Filled[c] > h
+	}
+	eroded := make([]float64, len(heights))
+	for k := len(d.Order) - 1; k >= 0; k-- {
+		c := d.Order[k]
+		r := d.Receivers[c]
+		if r == c || inBasin[c] || heights[c] <= heights[r] {
+			continue
+		}
+		f := e.Incision * math.Pow(drained[c], e.AreaExponent) / distanceTo(sphere, distances, c, r)
+		lowered := (heights[c] + f*heights[r]) / (1 + f)
+		eroded[c] = (heights[c] - lowered) * areas[c]
+		heights[c] = lowered
+	}
+
+	// Carry sediment downstream, dropping it where the water stops.
+	sediment := eroded
+	for _, c := range d.Order {
+		if sediment[c] <= 0 {
+			continue
+		}
+		r := d.Receivers[c]
+		switch {
+		case sea[c]:
+			fill.deposit(heights, sphere, areas, sea, c, sediment[c], seaLevel)
+			sediment[c] = 0
+			continue
+		case r == c:
+			// The water never reaches the sea, so everything stays here.
+			heights[c] += sediment[c] / areas[c]
+			sediment[c] = 0
+			continue
+		case inBasin[c]:
+			deposit := math.Min(sediment[c], (d.Filled[c]-heights[c])*areas[c])
+			heights[c] += deposit / areas[c]
+			sediment[c] -= deposit
+		}
+		sediment[r] += sediment[c]
+		sediment[c] = 0
+	}
+}
+
+// seaFill spreads sediment through the sea. It keeps what it needs between
+// deposits, so depositing allocates nothing once it has searched the sea.
+type seaFill struct {
+	// visited is the generation each cell was last reached in.
+	visited    []int
+	generation int
+	queue      []int
+}
+
+func newSeaFill(cells int) *seaFill {
+	return &seaFill{visited: make([]int, cells)}
+}
+
+// deposit drops a volume of sediment in the sea at c, filling the sea floor
+// nearest c up to sea level. If the sea c is in fills, the rest spreads evenly
+// over it.
+func (f *seaFill) deposit(heights []float64, sphere *geodesic.Geodesic, areas []float64, sea []bool, c int, sediment, seaLevel float64) {
+	f.generation++
+	f.visited[c] = f.generation
+	f.queue = append(f.queue[:0], c)
+	for i := 0; i < len(f.queue) && sediment > 0; i++ {
+		cur := f.queue[i]
+		if deposit := math.Min(sediment, (seaLevel-heights[cur])*areas[cur]); deposit > 0 {
+			heights[cur] += deposit / areas[cur]
+			sediment -= deposit
+		}
+		for _, n := range sphere.Faces[cur].Neighbors {
+			if sea[n] && f.visited[n] != f.generation {
+				f.visited[n] = f.generation
+				f.queue = append(f.queue, n)
+			}
+		}
+	}
+
+	if sediment > 0 {
+		area := 0.0
+		for _, cur := range f.queue {
+			area += areas[cur]
+		}
+		for _, cur := range f.queue {
+			heights[cur] += sediment / area
+		}
+	}
+}
+
+// relax slides material down slopes steeper than TalusSlope. The volume which
+// slides is as for the smaller of the two cells, so neither changes by more
+// than ThermalRate allows.
+func (e *Erosion) relax(heights []float64, sphere *geodesic.Geodesic, distances [][]float64, areas []float64) {
+	changes := make([]float64, len(heights))
+	for i, face := range sphere.Faces {
+		for k, n := range face.Neighbors {
+			// Each pair of neighbors is considered once, from the higher.
+			excess := heights[i] - heights[n] - e.TalusSlope*distances[i][k]
+			if excess <= 0 {
+				continue
+			}
+			moved := e.ThermalRate * excess / 2 * math.Min(areas[i], areas[n])
+			changes[i] -= moved / areas[i]
+			changes[n] += moved / areas[n]
+		}
+	}
+
+	for i, change := range changes {
+		heights[i] += change
+	}
+}
@@ -0,0 +1,96 @@
+package erosion
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/noise"
+	"math"
+	"testing"
+)
+
+func noiseHeights(g *geodesic.Geodesic) []float64 {
+	n := noise.NewPerlinFractal(1, 10, 30, 0.6)
+	heights := make([]float64, len(g.Centers))
+	for i, c := range g.Centers {
+		heights[i] = n.ValueAt(c)
+	}
+	return heights
+}
+
+// volume returns the sum of heights weighted by the areas of their cells.
+func volume(g *geodesic.Geodesic, heights []float64) float64 {
+	result := 0.0
+	for i, a := range g.Geometry().Areas {
+		result += a * heights[i]
+	}
+	return result
+}
+
+func TestErosion_Erode(t *testing.T) {
+	g := geodesic.New(4, true)[4]
+	heights := noiseHeights(g)
+	before := volume(g, heights)
+	seaLevel := SeaLevel(heights, Default.SeaCoverage)
+
+	eroded := append([]float64(nil), heights...)
+	Default.Erode(eroded, g, 1)
+
+	if after := volume(g, eroded); math.Abs(after-before) > 1e-12 {
+		t.Errorf("got volume %v after eroding, want %v", after, before)
+	}
+
+	// Land is worn down, and what is worn away fills the sea.
+	land, sea := 0.0, 0.0
+	for i, h := range heights {
+		if h < seaLevel {
+			sea += eroded[i] - h
+		} else {
+			land += eroded[i] - h
+		}
+	}
+	if land >= 0 || sea <= 0 {
+		t.Errorf("got land raised by %v and sea by %v, want land lowered and sea raised", land, sea)
+	}
+
+	again := append([]float64(nil), heights...)
+	Default.Erode(again, g, 1)
+	if diff := cmp.Diff(eroded, again); diff != "" {
+		t.Errorf("eroding with the same seed differed:\n%s", diff)
+	}
+
+	other := append([]float64(nil), heights...)
+	Default.Erode(other, g, 2)
+	if cmp.Equal(eroded, other) {
+		t.Error("eroding with different seeds gave the same heights")
+	}
+}
+
+func TestErosion_Relax(t *testing.T) {
+	g := geodesic.New(3, true)[3]
+	heights := make([]float64, len(g.Centers))
+	heights[0] = 1
+
+	e := Erosion{
+		Iterations:  200,
+		TalusSlope:  1,
+		ThermalRate: 1.0 / 6,
+	}
+	want := volume(g, heights)
+	e.Erode(heights, g, 0)
+
+	if got := volume(g, heights); math.Abs(got-want) > 1e-12 {
+		t.Errorf("got volume %v after relaxing, want %v", got, want)
+	}
+	if heights[0] >= 1 {
+		t.Errorf("got peak %v after relaxing, want less than 1", heights[0])
+	}
+
+	distances := neighborDistances(g)
+	for i, face := range g.Faces {
+		for k, n := range face.Neighbors {
+			if slope := (heights[i] - heights[n]) / distances[i][k]; slope > e.TalusSlope+1e-3 {
+				t.Fatalf("got slope %v from cell %d to %d, want at most %v", slope, i, n, e.TalusSlope)
+			}
+		}
+	}
+}
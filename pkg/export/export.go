@@ -0,0 +1,147 @@
+// Package export writes planets as triangle meshes for 3D tools, and their
+// layers as rasters of raw values for game engines and GIS tools.
+//
+// Meshes have one vertex at the center of every face of a geodesic sphere,
+// and a triangle between every three mutually adjacent faces, so each vertex
+// carries exactly the values of its cell.
+package export
+
+import (
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mesh is a triangle mesh of the surface of a planet with unit radius.
+type Mesh struct {
+	Positions []geodesic.Vector
+	// Normals are the unit normals of the surface at each vertex.
+	Normals []geodesic.Vector
+	// Colors is the color of each vertex, or nil if the mesh is uncolored.
+	Colors []color.RGBA
+	// Attributes are any other values at each vertex.
+	Attributes []Attribute
+	// Triangles lists the vertices of each triangle counter-clockwise when
+	// seen from outside the planet.
+	Triangles [][3]int
+}
+
+// Attribute is a named value at every vertex of a Mesh.
+type Attribute struct {
+	Name   string
+	Values []float64
+}
+
+// New returns the mesh of g with each vertex moved away from the center of
+// the sphere by exaggeration times its height. heights may be nil, in which
+// case the mesh is of the unit sphere.
+func New(g *geodesic.Geodesic, heights []float64, exaggeration float64) (*Mesh, error) {
+	if heights != nil && len(heights) != len(g.Centers) {
+		return nil, fmt.Errorf("got %d heights for %d faces", len(heights), len(g.Centers))
+	}
+
+	if heights == nil {
+		heights = make([]float64, len(g.Centers))
+	}
+	m := &Mesh{
+		Positions: make([]geodesic.Vector, len(g.Centers)),
+		Normals:   geodesic.SurfaceNormals(g, heights, exaggeration),
+		Triangles: g.Triangles(),
+	}
+	for i, c := range g.Centers {
+		m.Positions[i] = c.Scale(1 + exaggeration*heights[i])
+	}
+
+	return m, nil
+}
+
+// FromPlanet returns the mesh of p on g, colored as planet.RenderTerrain
+// colors it before shading, with dry land colored by land. Every layer of p is
+// attached as an Attribute, with Vector layers by their length and
+// Categorical layers by category.
+//
+// p must have a height for every face of g. Its waters and flows may be
+// missing, in which case it is colored as if dry.
+func FromPlanet(p *planet.Planet, g *geodesic.Geodesic, exaggeration float64, land *render.ColorScale) (*Mesh, error) {
+	if len(p.Heights) != len(g.Centers) {
+		return nil, fmt.Errorf("planet has %d heights for %d faces", len(p.Heights), len(g.Centers))
+	}
+	if len(p.Waters) > 0 && len(p.Waters) != len(g.Centers) {
+		return nil, fmt.Errorf("planet has %d waters for %d faces", len(p.Waters), len(g.Centers))
+	}
+	if len(p.Flows) > 0 && len(p.Flows) != len(g.Centers) {
+		return nil, fmt.Errorf("planet has %d flows for %d faces", len(p.Flows), len(g.Centers))
+	}
+
+	m, err := New(g, p.Heights, exaggeration)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Colors = make([]color.RGBA, len(g.Centers))
+	for i, h := range p.Heights {
+		w := 0.0
+		if len(p.Waters) > 0 {
+			w = p.Waters[i]
+		}
+		if len(p.Flows) > 0 {
+			w += p.Flows[i] / 2000.0
+		}
+		m.Colors[i] = render.TerrainColor(land, h, w)
+	}
+
+	for _, name := range p.LayerNames() {
+		l := p.Layer(name)
+		if l.Len() != len(g.Centers) {
+			return nil, fmt.Errorf("layer %q has %d values for %d faces", name, l.Len(), len(g.Centers))
+		}
+		m.Attributes = append(m.Attributes, Attribute{Name: name, Values: l.Magnitudes()})
+	}
+
+	return m, nil
+}
+
+// Write writes m to file in the format its extension names: .glb, .obj or
+// .ply.
+func Write(m *Mesh, file string) error {
+	var write func(io.Writer, *Mesh) error
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".glb":
+		write = WriteGLB
+	case ".obj":
+		write = WriteOBJ
+	case ".ply":
+		write = WritePLY
+	default:
+		return fmt.Errorf("unknown mesh format %q", ext)
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = write(out, m)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// identifier replaces characters in name which formats don't allow in the
+// names of properties with underscores.
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,278 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testPlanet(g *geodesic.Geodesic) *planet.Planet {
+	p := &planet.Planet{
+		Heights: make([]float64, len(g.Centers)),
+		Waters:  make([]float64, len(g.Centers)),
+	}
+	for i, c := range g.Centers {
+		p.Heights[i] = c.Z
+		p.Waters[i] = math.Max(0, -c.Z)
+	}
+	_ = p.AddLayer(planet.NewCategoricalLayer("biome", []string{"south", "north"}, make([]int, len(g.Centers))))
+	return p
+}
+
+func TestNew(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Chamfer(geodesic.Dodecahedron()))
+	heights := make([]float64, len(g.Centers))
+	for i, c := range g.Centers {
+		heights[i] = c.X
+	}
+
+	m, err := New(g, heights, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(m.Triangles), 2*len(g.Centers)-4; got != want {
+		t.Errorf("got %d triangles, want %d", got, want)
+	}
+	for i, p := range m.Positions {
+		want := g.Centers[i].Length() * (1 + 0.1*heights[i])
+		if math.Abs(p.Length()-want) > 1e-12 {
+			t.Errorf("vertex %d: got radius %g, want %g", i, p.Length(), want)
+		}
+		if m.Normals[i].Dot(g.Centers[i]) < 0.9 {
+			t.Errorf("vertex %d: got normal %v, want outward", i, m.Normals[i])
+		}
+	}
+
+	_, err = New(g, heights[1:], 0.1)
+	if err == nil {
+		t.Error("got New() error nil for too few heights")
+	}
+}
+
+func TestFromPlanet(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+	m, err := FromPlanet(testPlanet(g), g, 0.05, render.LandColorScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, a := range m.Attributes {
+		names = append(names, a.Name)
+	}
+	if diff := cmp.Diff([]string{"heights", "waters", "biome"}, names); diff != "" {
+		t.Error(diff)
+	}
+	if len(m.Colors) != len(g.Centers) {
+		t.Errorf("got %d colors, want %d", len(m.Colors), len(g.Centers))
+	}
+
+	// Dry land is colored by the scale given.
+	red := color.RGBA{R: 255, A: 255}
+	land := render.NewColorScale([]render.ColorPoint{{Threshold: 0, Color: red}, {Threshold: 1, Color: red}})
+	m, err = FromPlanet(testPlanet(g), g, 0.05, land)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range g.Centers {
+		if c.Z > 0 && m.Colors[i] != red {
+			t.Fatalf("face %d: got dry land colored %v, want %v", i, m.Colors[i], red)
+		}
+	}
+}
+
+func TestFromPlanet_Invalid(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+
+	tcs := []struct {
+		name   string
+		planet func() *planet.Planet
+	}{{
+		name: "no heights",
+		planet: func() *planet.Planet {
+			p := testPlanet(g)
+			p.Heights = nil
+			return p
+		},
+	}, {
+		name: "too few waters",
+		planet: func() *planet.Planet {
+			p := testPlanet(g)
+			p.Waters = p.Waters[1:]
+			return p
+		},
+	}}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FromPlanet(tc.planet(), g, 0.05, render.LandColorScale)
+			if err == nil {
+				t.Error("got FromPlanet() error nil")
+			}
+		})
+	}
+}
+
+func TestWriteGLB(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+	m, err := FromPlanet(testPlanet(g), g, 0.05, render.LandColorScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = WriteGLB(buf, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if got := binary.LittleEndian.Uint32(data); got != glbMagic {
+		t.Fatalf("got magic %x, want %x", got, glbMagic)
+	}
+	if got := binary.LittleEndian.Uint32(data[4:]); got != 2 {
+		t.Errorf("got version %d, want 2", got)
+	}
+	if got := binary.LittleEndian.Uint32(data[8:]); int(got) != len(data) {
+		t.Errorf("got length %d, want %d", got, len(data))
+	}
+
+	jsonLength := int(binary.LittleEndian.Uint32(data[12:]))
+	if binary.LittleEndian.Uint32(data[16:]) != glbChunkJSON {
+		t.Fatal("first chunk is not JSON")
+	}
+	binStart := 20 + jsonLength
+	binLength := int(binary.LittleEndian.Uint32(data[binStart:]))
+	if binary.LittleEndian.Uint32(data[binStart+4:]) != glbChunkBIN {
+		t.Fatal("second chunk is not BIN")
+	}
+	if jsonLength%4 != 0 || binLength%4 != 0 {
+		t.Errorf("got chunk lengths %d and %d, want multiples of 4", jsonLength, binLength)
+	}
+	if binStart+8+binLength != len(data) {
+		t.Errorf("chunks end at %d, want %d", binStart+8+binLength, len(data))
+	}
+
+	doc := gltf{}
+	err = json.Unmarshal(data[20:binStart], &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primitive := doc.Meshes[0].Primitives[0]
+	for _, name := range []string{"POSITION", "NORMAL", "COLOR_0", "_HEIGHTS", "_WATERS", "_BIOME"} {
+		accessor, found := primitive.Attributes[name]
+		if !found {
+			t.Errorf("missing attribute %s", name)
+			continue
+		}
+		if got := doc.Accessors[accessor].Count; got != len(g.Centers) {
+			t.Errorf("%s: got count %d, want %d", name, got, len(g.Centers))
+		}
+	}
+	if got := doc.Accessors[primitive.Indices].Count; got != 3*len(m.Triangles) {
+		t.Errorf("got %d indices, want %d", got, 3*len(m.Triangles))
+	}
+	for i, view := range doc.BufferViews {
+		if view.ByteOffset%4 != 0 || view.ByteOffset+view.ByteLength > binLength {
+			t.Errorf("buffer view %d: got offset %d and length %d in %d bytes", i, view.ByteOffset, view.ByteLength, binLength)
+		}
+	}
+}
+
+func TestWritePLY(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+	m, err := FromPlanet(testPlanet(g), g, 0.05, render.LandColorScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = WritePLY(buf, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(buf)
+	var header []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "end_header" {
+			break
+		}
+		header = append(header, line)
+	}
+
+	want := []string{
+		"ply",
+		"format binary_little_endian 1.0",
+		"comment worldproc planet",
+		"element vertex 42",
+		"property float x",
+		"property float y",
+		"property float z",
+		"property float nx",
+		"property float ny",
+		"property float nz",
+		"property uchar red",
+		"property uchar green",
+		"property uchar blue",
+		"property float heights",
+		"property float waters",
+		"property float biome",
+		"element face 80",
+		"property list uchar int vertex_indices",
+	}
+	if diff := cmp.Diff(want, header); diff != "" {
+		t.Error(diff)
+	}
+
+	// Each vertex has nine floats and three bytes, and each face a count and
+	// three indices.
+	if got, want := r.Buffered(), 42*(9*4+3)+80*(1+3*4); got != want {
+		t.Errorf("got %d bytes of data, want %d", got, want)
+	}
+}
+
+func TestWriteOBJ(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+	m, err := FromPlanet(testPlanet(g), g, 0.05, render.LandColorScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = WriteOBJ(buf, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		counts[fields[0]]++
+		if fields[0] == "v" && len(fields) != 7 {
+			t.Errorf("got vertex %q, want position and color", scanner.Text())
+		}
+	}
+
+	want := map[string]int{"#": 1, "v": 42, "vn": 42, "f": 80}
+	if diff := cmp.Diff(want, counts); diff != "" {
+		t.Error(diff)
+	}
+}
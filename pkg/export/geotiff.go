@@ -0,0 +1,188 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image"
+	"io"
+	"sort"
+)
+
+// DefaultRadius is the radius of planets in meters unless otherwise given,
+// the mean radius of the Earth.
+const DefaultRadius = 6371000.0
+
+func radius(r float64) float64 {
+	if r == 0 {
+		return DefaultRadius
+	}
+	return r
+}
+
+// GeoTIFF tags.
+const (
+	tagModelTransformation = 34264
+	tagGeoKeyDirectory     = 34735
+	tagGeoDoubleParams     = 34736
+	tagGeoASCIIParams      = 34737
+)
+
+// GeoTIFF keys, and the values of them written.
+const (
+	keyGTModelType      = 1024
+	modelTypeGeographic = 2
+
+	keyGTRasterType   = 1025
+	rasterPixelIsArea = 1
+
+	keyGTCitation = 1026
+
+	keyGeographicType = 2048
+	keyGeogCitation   = 2049
+	keyGeogDatum      = 2050
+
+	keyGeogPrimeMeridian = 2051
+	primeMeridianZero    = 8901
+
+	keyGeogAngularUnits = 2054
+	angularUnitDegree   = 9102
+
+	keyGeogEllipsoid     = 2056
+	keyGeogSemiMajorAxis = 2057
+	keyGeogSemiMinorAxis = 2058
+
+	userDefined = 32767
+)
+
+// geoKey is an entry of a GeoTIFF key directory, with exactly one of its
+// values set.
+type geoKey struct {
+	id     uint16
+	short  uint16
+	double float64
+	ascii  string
+}
+
+// GeoTransform returns the affine transform from the raster space of an
+// image rendered with projection to planetocentric longitude and latitude in
+// degrees:
+//
+//	longitude = t[0] + t[1]*i
+//	latitude  = t[2] + t[3]*j
+//
+// where i and j count pixels right and down from the top-left corner of the
+// image, so the center of the top-left pixel is at 0.5, 0.5. The projection
+// must be render.Equirectangular.
+func GeoTransform(projection render.Projection) ([4]float64, error) {
+	if _, ok := projection.Projector.(render.Equirectangular); !ok {
+		return [4]float64{}, fmt.Errorf("can't georeference %s projection", ProjectionName(projection.Projector))
+	}
+
+	// Pixel indices count pixel centers, which are half a pixel in from the
+	// corners raster space counts from.
+	x0, y0 := projection.Coordinates(-0.5, -0.5)
+	x1, y1 := projection.Coordinates(0.5, 0.5)
+
+	// Equirectangular maps x to longitude and y to latitude linearly, with
+	// the whole circle of longitude across the width of the screen and half a
+	// circle of latitude across its height.
+	return [4]float64{x0 * 360, (x1 - x0) * 360, y0 * 180, (y1 - y0) * 180}, nil
+}
+
+// geoFields returns the GeoTIFF fields which georeference an image rendered
+// with projection on a spherical planet of the given radius in meters, using a
+// planetocentric geographic coordinate system.
+func geoFields(projection render.Projection, planetRadius float64) ([]tiffField, error) {
+	t, err := GeoTransform(projection)
+	if err != nil {
+		return nil, err
+	}
+	planetRadius = radius(planetRadius)
+
+	transformation := doubleField(tagModelTransformation,
+		t[1], 0, 0, t[0],
+		0, t[3], 0, t[2],
+		0, 0, 0, 0,
+		0, 0, 0, 1,
+	)
+
+	keys := []geoKey{
+		{id: keyGTModelType, short: modelTypeGeographic},
+		{id: keyGTRasterType, short: rasterPixelIsArea},
+		{id: keyGTCitation, ascii: "worldproc equirectangular"},
+		{id: keyGeographicType, short: userDefined},
+		{id: keyGeogCitation, ascii: fmt.Sprintf("Planetocentric sphere of radius %g m", planetRadius)},
+		{id: keyGeogDatum, short: userDefined},
+		{id: keyGeogPrimeMeridian, short: primeMeridianZero},
+		{id: keyGeogAngularUnits, short: angularUnitDegree},
+		{id: keyGeogEllipsoid, short: userDefined},
+		{id: keyGeogSemiMajorAxis, double: planetRadius},
+		{id: keyGeogSemiMinorAxis, double: planetRadius},
+	}
+	directory, doubles, ascii := geoKeyDirectory(keys)
+
+	fields := []tiffField{transformation, shortField(tagGeoKeyDirectory, directory...)}
+	if len(doubles) > 0 {
+		fields = append(fields, doubleField(tagGeoDoubleParams, doubles...))
+	}
+	if len(ascii) > 0 {
+		fields = append(fields, asciiField(tagGeoASCIIParams, ascii))
+	}
+	return fields, nil
+}
+
+// geoKeyDirectory encodes keys as the values of the GeoKeyDirectory,
+// GeoDoubleParams and GeoAsciiParams tags.
+func geoKeyDirectory(keys []geoKey) (directory []uint16, doubles []float64, ascii string) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].id < keys[j].id
+	})
+
+	// Version 1.1.0 of the key directory, followed by the number of keys.
+	directory = []uint16{1, 1, 0, uint16(len(keys))}
+	for _, k := range keys {
+		switch {
+		case k.ascii != "":
+			directory = append(directory, k.id, tagGeoASCIIParams, uint16(len(k.ascii)+1), uint16(len(ascii)))
+			ascii += k.ascii + "|"
+		case k.double != 0:
+			directory = append(directory, k.id, tagGeoDoubleParams, 1, uint16(len(doubles)))
+			doubles = append(doubles, k.double)
+		default:
+			directory = append(directory, k.id, 0, 1, k.short)
+		}
+	}
+	return directory, doubles, ascii
+}
+
+// WriteGeoTIFF writes img, rendered with an equirectangular projection of a
+// spherical planet of the given radius in meters, as an RGBA GeoTIFF. If
+// radius is zero it is DefaultRadius.
+func WriteGeoTIFF(w io.Writer, img *image.RGBA, projection render.Projection, radius float64) error {
+	geo, err := geoFields(projection, radius)
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	pixels := &bytes.Buffer{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		start := img.PixOffset(bounds.Min.X, y)
+		pixels.Write(img.Pix[start : start+4*bounds.Dx()])
+	}
+
+	fields := append([]tiffField{
+		shortField(tagBitsPerSample, 8, 8, 8, 8),
+		// RGB.
+		shortField(tagPhotometricInterpretation, 2),
+		shortField(tagSamplesPerPixel, 4),
+		// image.RGBA premultiplies colors by alpha, which TIFF calls
+		// associated alpha.
+		shortField(tagExtraSamples, 1),
+		// Unsigned integers.
+		shortField(tagSampleFormat, 1, 1, 1, 1),
+	}, geo...)
+
+	return writeTIFF(w, bounds.Dx(), bounds.Dy(), pixels.Bytes(), fields)
+}
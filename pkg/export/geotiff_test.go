@@ -0,0 +1,150 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestGeoTransform(t *testing.T) {
+	projection := render.Project(render.Screen{Width: 36, Height: 18}, render.Equirectangular{})
+	transform, err := GeoTransform(projection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The center of every pixel is transformed to the longitude and latitude
+	// it was rendered at.
+	for pidx, a := range projection.Pixels {
+		i := float64(pidx%projection.Width) + 0.5
+		j := float64(pidx/projection.Width) + 0.5
+		longitude := transform[0] + transform[1]*i
+		latitude := transform[2] + transform[3]*j
+		if math.Abs(longitude-a.Phi*180/math.Pi) > 1e-9 || math.Abs(latitude-a.Theta*180/math.Pi) > 1e-9 {
+			t.Fatalf("pixel %d: got %g, %g, want %g, %g", pidx, longitude, latitude, a.Phi*180/math.Pi, a.Theta*180/math.Pi)
+		}
+	}
+
+	_, err = GeoTransform(render.Project(render.Screen{Width: 4, Height: 2}, render.Mollweide{}))
+	if err == nil {
+		t.Error("got GeoTransform() error nil for Mollweide projection")
+	}
+}
+
+func TestWriteTIFF_Georeferenced(t *testing.T) {
+	r := testRaster(t, render.Equirectangular{})
+	r.Radius = 3389500
+	buf := &bytes.Buffer{}
+	err := WriteTIFF(buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := readTIFF(t, buf.Bytes())
+	transform, _ := GeoTransform(r.Projection)
+	want := []float64{
+		transform[1], 0, 0, transform[0],
+		0, transform[3], 0, transform[2],
+		0, 0, 0, 0,
+		0, 0, 0, 1,
+	}
+	if diff := cmp.Diff(want, readFloat64s(fields[tagModelTransformation].value)); diff != "" {
+		t.Error(diff)
+	}
+
+	keys := readGeoKeys(t, fields)
+	if got := keys[keyGTModelType]; got != uint16(modelTypeGeographic) {
+		t.Errorf("got model type %v, want geographic", got)
+	}
+	for _, key := range []uint16{keyGeogSemiMajorAxis, keyGeogSemiMinorAxis} {
+		if got := keys[key]; got != r.Radius {
+			t.Errorf("key %d: got %v, want %g", key, got, r.Radius)
+		}
+	}
+	if got, want := keys[keyGeogCitation], "Planetocentric sphere of radius 3.3895e+06 m"; got != want {
+		t.Errorf("got citation %q, want %q", got, want)
+	}
+
+	// Projections other than equirectangular aren't georeferenced.
+	buf.Reset()
+	err = WriteTIFF(buf, testRaster(t, render.Mollweide{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := readTIFF(t, buf.Bytes())[tagGeoKeyDirectory]; found {
+		t.Error("got Mollweide raster georeferenced")
+	}
+}
+
+func TestWriteGeoTIFF(t *testing.T) {
+	projection := render.Project(render.Screen{Width: 8, Height: 4}, render.Equirectangular{})
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteGeoTIFF(buf, img, projection, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	fields := readTIFF(t, data)
+	if got := fields[tagSamplesPerPixel].uint(0); got != 4 {
+		t.Errorf("got %d samples per pixel, want 4", got)
+	}
+	offset := fields[tagStripOffsets].uint(0)
+	length := fields[tagStripByteCounts].uint(0)
+	if diff := cmp.Diff(img.Pix, data[offset:offset+length]); diff != "" {
+		t.Error(diff)
+	}
+	if got := readGeoKeys(t, fields)[keyGeogSemiMajorAxis]; got != DefaultRadius {
+		t.Errorf("got radius %v, want %g", got, DefaultRadius)
+	}
+
+	err = WriteGeoTIFF(buf, img, render.Project(render.Screen{Width: 8, Height: 4}, render.Orthographic{}), 0)
+	if err == nil {
+		t.Error("got WriteGeoTIFF() error nil for orthographic projection")
+	}
+}
+
+func readFloat64s(data []byte) []float64 {
+	result := make([]float64, len(data)/8)
+	for i := range result {
+		result[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return result
+}
+
+// readGeoKeys returns the value of every GeoTIFF key in fields: a uint16,
+// float64 or string.
+func readGeoKeys(t *testing.T, fields map[uint16]tiffField) map[uint16]interface{} {
+	t.Helper()
+	directory := fields[tagGeoKeyDirectory]
+	n := int(directory.uint(3))
+	doubles := readFloat64s(fields[tagGeoDoubleParams].value)
+	ascii := string(fields[tagGeoASCIIParams].value)
+
+	result := make(map[uint16]interface{}, n)
+	for i := 1; i <= n; i++ {
+		id, location := uint16(directory.uint(4*i)), directory.uint(4*i+1)
+		count, value := int(directory.uint(4*i+2)), int(directory.uint(4*i+3))
+		switch location {
+		case 0:
+			result[id] = uint16(value)
+		case tagGeoDoubleParams:
+			result[id] = doubles[value]
+		case tagGeoASCIIParams:
+			// Strings end with a pipe rather than a null.
+			result[id] = ascii[value : value+count-1]
+		default:
+			t.Fatalf("key %d: got location %d", id, location)
+		}
+	}
+	return result
+}
@@ -0,0 +1,233 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"io"
+	"math"
+	"strings"
+)
+
+// Constants from the glTF 2.0 specification.
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbVersion   = 2
+	glbChunkJSON = 0x4E4F534A // "JSON"
+	glbChunkBIN  = 0x004E4942 // "BIN\x00"
+
+	componentUnsignedByte = 5121
+	componentUnsignedInt  = 5125
+	componentFloat        = 5126
+
+	targetArrayBuffer        = 34962
+	targetElementArrayBuffer = 34963
+
+	modeTriangles = 4
+)
+
+type gltf struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Mesh int `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Normalized    bool      `json:"normalized,omitempty"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+// glbBuilder collects the binary data of a glTF file and the accessors which
+// describe it.
+type glbBuilder struct {
+	doc gltf
+	bin bytes.Buffer
+}
+
+// add appends data as a new buffer view described by accessor, returning the
+// index of the accessor.
+func (b *glbBuilder) add(data []byte, target int, accessor gltfAccessor) int {
+	b.doc.BufferViews = append(b.doc.BufferViews, gltfBufferView{
+		ByteOffset: b.bin.Len(),
+		ByteLength: len(data),
+		Target:     target,
+	})
+	b.bin.Write(data)
+	// Every element of every view must start on a multiple of four bytes.
+	for b.bin.Len()%4 != 0 {
+		b.bin.WriteByte(0)
+	}
+
+	accessor.BufferView = len(b.doc.BufferViews) - 1
+	b.doc.Accessors = append(b.doc.Accessors, accessor)
+	return len(b.doc.Accessors) - 1
+}
+
+func putFloat32(data []byte, f float64) {
+	binary.LittleEndian.PutUint32(data, math.Float32bits(float32(f)))
+}
+
+// vectors returns vs as consecutive 32-bit floats.
+func vectors(vs []geodesic.Vector) []byte {
+	data := make([]byte, 12*len(vs))
+	for i, v := range vs {
+		putFloat32(data[12*i:], v.X)
+		putFloat32(data[12*i+4:], v.Y)
+		putFloat32(data[12*i+8:], v.Z)
+	}
+	return data
+}
+
+// WriteGLB writes m as a binary glTF 2.0 file, with a single mesh in a single
+// scene.
+//
+// Colors are written as COLOR_0. Attributes are written as custom vertex
+// attributes named as the attribute in upper case with a leading underscore,
+// as glTF requires, so "waters" becomes "_WATERS".
+func WriteGLB(w io.Writer, m *Mesh) error {
+	b := &glbBuilder{doc: gltf{
+		Asset:  gltfAsset{Version: "2.0", Generator: "worldproc"},
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{Mesh: 0}},
+	}}
+	attributes := make(map[string]int)
+	n := len(m.Positions)
+
+	min := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range m.Positions {
+		for k, v := range []float64{p.X, p.Y, p.Z} {
+			// Bounds must be exact for the values as stored.
+			v = float64(float32(v))
+			min[k] = math.Min(min[k], v)
+			max[k] = math.Max(max[k], v)
+		}
+	}
+	attributes["POSITION"] = b.add(vectors(m.Positions), targetArrayBuffer, gltfAccessor{
+		ComponentType: componentFloat, Count: n, Type: "VEC3", Min: min, Max: max,
+	})
+
+	if m.Normals != nil {
+		attributes["NORMAL"] = b.add(vectors(m.Normals), targetArrayBuffer, gltfAccessor{
+			ComponentType: componentFloat, Count: n, Type: "VEC3",
+		})
+	}
+
+	if m.Colors != nil {
+		data := make([]byte, 4*n)
+		for i, c := range m.Colors {
+			data[4*i], data[4*i+1], data[4*i+2], data[4*i+3] = c.R, c.G, c.B, 255
+		}
+		attributes["COLOR_0"] = b.add(data, targetArrayBuffer, gltfAccessor{
+			ComponentType: componentUnsignedByte, Normalized: true, Count: n, Type: "VEC4",
+		})
+	}
+
+	for _, a := range m.Attributes {
+		data := make([]byte, 4*n)
+		for i, v := range a.Values {
+			putFloat32(data[4*i:], v)
+		}
+		attributes["_"+strings.ToUpper(identifier(a.Name))] = b.add(data, targetArrayBuffer, gltfAccessor{
+			ComponentType: componentFloat, Count: n, Type: "SCALAR",
+		})
+	}
+
+	indices := make([]byte, 12*len(m.Triangles))
+	for i, tri := range m.Triangles {
+		for k, v := range tri {
+			binary.LittleEndian.PutUint32(indices[12*i+4*k:], uint32(v))
+		}
+	}
+	indexAccessor := b.add(indices, targetElementArrayBuffer, gltfAccessor{
+		ComponentType: componentUnsignedInt, Count: 3 * len(m.Triangles), Type: "SCALAR",
+	})
+
+	b.doc.Meshes = []gltfMesh{{Primitives: []gltfPrimitive{{
+		Attributes: attributes,
+		Indices:    indexAccessor,
+		Mode:       modeTriangles,
+	}}}}
+	b.doc.Buffers = []gltfBuffer{{ByteLength: b.bin.Len()}}
+
+	doc, err := json.Marshal(b.doc)
+	if err != nil {
+		return err
+	}
+	// The JSON chunk is padded with spaces and the binary chunk with zeros.
+	for len(doc)%4 != 0 {
+		doc = append(doc, ' ')
+	}
+
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header, glbMagic)
+	binary.LittleEndian.PutUint32(header[4:], glbVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(12+8+len(doc)+8+b.bin.Len()))
+	_, err = w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	err = writeChunk(w, glbChunkJSON, doc)
+	if err != nil {
+		return err
+	}
+	return writeChunk(w, glbChunkBIN, b.bin.Bytes())
+}
+
+func writeChunk(w io.Writer, chunkType uint32, data []byte) error {
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header, uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[4:], chunkType)
+	_, err := w.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
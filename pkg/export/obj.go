@@ -0,0 +1,42 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteOBJ writes m as a Wavefront OBJ file.
+//
+// OBJ has no standard way to store vertex colors, so they are written as three
+// extra components of each vertex, which Blender and MeshLab read. Attributes
+// can't be stored and are left out.
+func WriteOBJ(w io.Writer, m *Mesh) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# worldproc planet: %d vertices, %d triangles\n", len(m.Positions), len(m.Triangles))
+	for i, p := range m.Positions {
+		if m.Colors != nil {
+			c := m.Colors[i]
+			fmt.Fprintf(out, "v %g %g %g %.4f %.4f %.4f\n", p.X, p.Y, p.Z,
+				float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+		} else {
+			fmt.Fprintf(out, "v %g %g %g\n", p.X, p.Y, p.Z)
+		}
+	}
+	for _, n := range m.Normals {
+		fmt.Fprintf(out, "vn %g %g %g\n", n.X, n.Y, n.Z)
+	}
+
+	// OBJ numbers vertices from 1.
+	for _, tri := range m.Triangles {
+		a, b, c := tri[0]+1, tri[1]+1, tri[2]+1
+		if m.Normals != nil {
+			fmt.Fprintf(out, "f %d//%d %d//%d %d//%d\n", a, a, b, b, c, c)
+		} else {
+			fmt.Fprintf(out, "f %d %d %d\n", a, b, c)
+		}
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WritePLY writes m as a binary little-endian PLY file.
+//
+// Attributes are written as extra float properties of each vertex, named as
+// the attribute with any characters other than letters, digits and
+// underscores replaced by underscores.
+func WritePLY(w io.Writer, m *Mesh) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, "ply")
+	fmt.Fprintln(out, "format binary_little_endian 1.0")
+	fmt.Fprintln(out, "comment worldproc planet")
+	fmt.Fprintf(out, "element vertex %d\n", len(m.Positions))
+	fmt.Fprintln(out, "property float x")
+	fmt.Fprintln(out, "property float y")
+	fmt.Fprintln(out, "property float z")
+	if m.Normals != nil {
+		fmt.Fprintln(out, "property float nx")
+		fmt.Fprintln(out, "property float ny")
+		fmt.Fprintln(out, "property float nz")
+	}
+	if m.Colors != nil {
+		fmt.Fprintln(out, "property uchar red")
+		fmt.Fprintln(out, "property uchar green")
+		fmt.Fprintln(out, "property uchar blue")
+	}
+	for _, a := range m.Attributes {
+		fmt.Fprintf(out, "property float %s\n", identifier(a.Name))
+	}
+	fmt.Fprintf(out, "element face %d\n", len(m.Triangles))
+	fmt.Fprintln(out, "property list uchar int vertex_indices")
+	fmt.Fprintln(out, "end_header")
+
+	var record []byte
+	putFloat := func(f float64) {
+		record = append(record, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(record[len(record)-4:], math.Float32bits(float32(f)))
+	}
+
+	for i, p := range m.Positions {
+		record = record[:0]
+		putFloat(p.X)
+		putFloat(p.Y)
+		putFloat(p.Z)
+		if m.Normals != nil {
+			n := m.Normals[i]
+			putFloat(n.X)
+			putFloat(n.Y)
+			putFloat(n.Z)
+		}
+		if m.Colors != nil {
+			c := m.Colors[i]
+			record = append(record, c.R, c.G, c.B)
+		}
+		for _, a := range m.Attributes {
+			putFloat(a.Values[i])
+		}
+		_, err := out.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	face := make([]byte, 13)
+	face[0] = 3
+	for _, tri := range m.Triangles {
+		for k, v := range tri {
+			binary.LittleEndian.PutUint32(face[1+4*k:], uint32(v))
+		}
+		_, err := out.Write(face)
+		if err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
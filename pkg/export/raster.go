@@ -0,0 +1,263 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Raster is a layer of a planet sampled at every pixel of a projection, with
+// its values kept rather than painted.
+type Raster struct {
+	Width, Height int
+	// Values holds the value at each pixel row by row, in the same order as
+	// the pixels of images rendered with the same projection. Pixels which
+	// don't show the sphere are NaN.
+	Values []float64
+
+	// Name and Units describe the values.
+	Name  string
+	Units string
+	// Projection is the projection the raster was sampled with.
+	Projection render.Projection
+	// Radius is the radius of the planet in meters, which georeferenced
+	// formats record, or DefaultRadius if zero.
+	Radius float64
+}
+
+// NewRaster samples values, one per cell, at every pixel of cells.
+func NewRaster(cells *render.CellMap, name, units string, values []float64) *Raster {
+	r := &Raster{
+		Width:      cells.Width,
+		Height:     cells.Height,
+		Values:     cells.Sample(values),
+		Name:       name,
+		Units:      units,
+		Projection: cells.Projection,
+	}
+	r.maskInvisible()
+	return r
+}
+
+// RasterFromLayer samples the layer of p named name at every pixel of cells.
+//
+// As with planet.RenderLayer, Vector layers are sampled by their length and
+// Categorical layers take the category of the closest cell.
+func RasterFromLayer(p *planet.Planet, name string, cells *render.CellMap) (*Raster, error) {
+	l := p.Layer(name)
+	if l == nil {
+		return nil, fmt.Errorf("planet has no layer %q", name)
+	}
+	if l.Kind != planet.Categorical {
+		return NewRaster(cells, name, l.Units, l.Magnitudes()), nil
+	}
+
+	values := l.Magnitudes()
+	r := &Raster{
+		Width:      cells.Width,
+		Height:     cells.Height,
+		Values:     make([]float64, len(cells.Cells)),
+		Name:       name,
+		Units:      l.Units,
+		Projection: cells.Projection,
+	}
+	for pidx, cell := range cells.Cells {
+		r.Values[pidx] = values[cell]
+	}
+	r.maskInvisible()
+	return r, nil
+}
+
+func (r *Raster) maskInvisible() {
+	for pidx, visible := range r.Projection.Visible {
+		if !visible {
+			r.Values[pidx] = math.NaN()
+		}
+	}
+}
+
+// Bounds returns the least and greatest values of r, ignoring pixels which
+// don't show the sphere. Both are zero if no pixels do.
+func (r *Raster) Bounds() (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range r.Values {
+		if math.IsNaN(v) {
+			continue
+		}
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	if min > max {
+		return 0, 0
+	}
+	return min, max
+}
+
+// RasterMetadata describes a Raster written to a file, and is written
+// alongside it so tools can interpret the values.
+type RasterMetadata struct {
+	Name  string `json:"name"`
+	Units string `json:"units,omitempty"`
+	// Format is the format of the file: "png16", "pfm", "tiff" or "raw".
+	Format string `json:"format"`
+	// SampleType is how each value is stored, such as "float32le" for
+	// little-endian 32-bit floats.
+	SampleType string `json:"sampleType"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	// Projection names the projection the raster was sampled with.
+	Projection string `json:"projection"`
+	// RowOrder is "top-down" if the first row in the file is the top row of
+	// the rendered image, or "bottom-up" if it is the bottom row.
+	RowOrder string `json:"rowOrder"`
+	// Min and Max are the least and greatest values. 16-bit PNGs map them to
+	// 1 and 65535, leaving 0 for pixels which don't show the sphere.
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	// NoData is the value of pixels which don't show the sphere.
+	NoData string `json:"noData"`
+	// Radius is the radius of the planet in meters.
+	Radius float64 `json:"radius"`
+}
+
+// Metadata describes r as written in format.
+func (r *Raster) Metadata(format string) RasterMetadata {
+	min, max := r.Bounds()
+	result := RasterMetadata{
+		Name:       r.Name,
+		Units:      r.Units,
+		Format:     format,
+		SampleType: "float32le",
+		Width:      r.Width,
+		Height:     r.Height,
+		Projection: ProjectionName(r.Projection.Projector),
+		RowOrder:   "top-down",
+		Min:        min,
+		Max:        max,
+		NoData:     "NaN",
+		Radius:     radius(r.Radius),
+	}
+	switch format {
+	case "png16":
+		result.SampleType = "uint16be"
+		result.NoData = "0"
+	case "pfm":
+		result.RowOrder = "bottom-up"
+	}
+	return result
+}
+
+// ProjectionName returns the lower-case name of the type of p, such as
+// "equirectangular".
+func ProjectionName(p render.Projector) string {
+	name := fmt.Sprintf("%T", p)
+	name = strings.TrimPrefix(name, "*")
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.ToLower(name)
+}
+
+// WriteGray16 writes r as a 16-bit grayscale PNG, scaling its values so the
+// least is 1 and the greatest is 65535. Pixels which don't show the sphere
+// are 0.
+func WriteGray16(w io.Writer, r *Raster) error {
+	min, max := r.Bounds()
+	scale := 0.0
+	if max > min {
+		scale = 65534 / (max - min)
+	}
+
+	img := image.NewGray16(image.Rect(0, 0, r.Width, r.Height))
+	for pidx, v := range r.Values {
+		if math.IsNaN(v) {
+			continue
+		}
+		gray := uint16(1 + math.Round((v-min)*scale))
+		img.Pix[2*pidx] = byte(gray >> 8)
+		img.Pix[2*pidx+1] = byte(gray)
+	}
+	return png.Encode(w, img)
+}
+
+// WritePFM writes r as a little-endian Portable Float Map. PFM stores rows
+// from the bottom of the image up.
+func WritePFM(w io.Writer, r *Raster) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "Pf\n%d %d\n-1.0\n", r.Width, r.Height)
+
+	row := make([]byte, 4*r.Width)
+	for y := r.Height - 1; y >= 0; y-- {
+		putFloat32s(row, r.Values[y*r.Width:(y+1)*r.Width])
+		_, err := out.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// WriteRaw writes the values of r as little-endian 32-bit floats with no
+// header, row by row from the top of the image.
+func WriteRaw(w io.Writer, r *Raster) error {
+	data := make([]byte, 4*len(r.Values))
+	putFloat32s(data, r.Values)
+	_, err := w.Write(data)
+	return err
+}
+
+func putFloat32s(data []byte, values []float64) {
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(v)))
+	}
+}
+
+// WriteRaster writes r to file in the format its extension names: .png for a
+// 16-bit grayscale PNG, .pfm, .tif or .tiff for a 32-bit float TIFF, or .raw
+// or .bin for raw floats. Its RasterMetadata is written alongside it to file
+// with .json appended.
+func WriteRaster(r *Raster, file string) error {
+	var write func(io.Writer, *Raster) error
+	var format string
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".png":
+		write, format = WriteGray16, "png16"
+	case ".pfm":
+		write, format = WritePFM, "pfm"
+	case ".tif", ".tiff":
+		write, format = WriteTIFF, "tiff"
+	case ".raw", ".bin":
+		write, format = WriteRaw, "raw"
+	default:
+		return fmt.Errorf("unknown raster format %q", ext)
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = write(out, r)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := json.MarshalIndent(r.Metadata(format), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file+".json", metadata, os.ModePerm)
+}
@@ -0,0 +1,271 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testRaster(t *testing.T, projector render.Projector) *Raster {
+	t.Helper()
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+	cells := render.NewCellMap(render.Project(render.Screen{Width: 20, Height: 10}, projector), g)
+	r, err := RasterFromLayer(testPlanet(g), planet.HeightsLayer, cells)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRasterFromLayer(t *testing.T) {
+	r := testRaster(t, render.Mollweide{})
+
+	for pidx, visible := range r.Projection.Visible {
+		if got := math.IsNaN(r.Values[pidx]); got == visible {
+			t.Fatalf("pixel %d: got value %g, want NaN %t", pidx, r.Values[pidx], !visible)
+		}
+	}
+
+	// Heights are the z coordinate of each cell, so reach nearly to -1 and 1.
+	min, max := r.Bounds()
+	if min > -0.8 || min < -1 || max < 0.8 || max > 1 {
+		t.Errorf("got Bounds() = %g, %g, want near -1, 1", min, max)
+	}
+
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+	cells := render.NewCellMap(render.Project(render.Screen{Width: 4, Height: 2}, render.Equirectangular{}), g)
+	_, err := RasterFromLayer(testPlanet(g), "rainfall", cells)
+	if err == nil {
+		t.Error("got RasterFromLayer() error nil for a missing layer")
+	}
+}
+
+func TestWriteGray16(t *testing.T) {
+	r := testRaster(t, render.Mollweide{})
+	buf := &bytes.Buffer{}
+	err := WriteGray16(buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, ok := decoded.(*image.Gray16)
+	if !ok {
+		t.Fatalf("got %T, want *image.Gray16", decoded)
+	}
+
+	var least, greatest uint16 = math.MaxUint16, 0
+	for pidx, visible := range r.Projection.Visible {
+		gray := img.Gray16At(pidx%r.Width, pidx/r.Width).Y
+		if !visible {
+			if gray != 0 {
+				t.Errorf("pixel %d: got %d, want 0 off the sphere", pidx, gray)
+			}
+			continue
+		}
+		if gray < least {
+			least = gray
+		}
+		if gray > greatest {
+			greatest = gray
+		}
+	}
+	if least != 1 || greatest != math.MaxUint16 {
+		t.Errorf("got values from %d to %d, want 1 to %d", least, greatest, math.MaxUint16)
+	}
+}
+
+func TestWritePFM(t *testing.T) {
+	r := testRaster(t, render.Equirectangular{})
+	buf := &bytes.Buffer{}
+	err := WritePFM(buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := "Pf\n20 10\n-1.0\n"
+	if got := buf.String()[:len(header)]; got != header {
+		t.Fatalf("got header %q, want %q", got, header)
+	}
+	data := buf.Bytes()[len(header):]
+	if got, want := len(data), 4*r.Width*r.Height; got != want {
+		t.Fatalf("got %d bytes of values, want %d", got, want)
+	}
+
+	// The first row in the file is the bottom row of the image.
+	got := readFloat32s(data[:4*r.Width])
+	want := float32s(r.Values[(r.Height-1)*r.Width:])
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteRaw(t *testing.T) {
+	r := testRaster(t, render.Equirectangular{})
+	buf := &bytes.Buffer{}
+	err := WriteRaw(buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(float32s(r.Values), readFloat32s(buf.Bytes())); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteTIFF(t *testing.T) {
+	r := testRaster(t, render.Mollweide{})
+	buf := &bytes.Buffer{}
+	err := WriteTIFF(buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	fields := readTIFF(t, data)
+	for tag, want := range map[uint16]uint32{
+		tagImageWidth:      20,
+		tagImageLength:     10,
+		tagBitsPerSample:   32,
+		tagSampleFormat:    3,
+		tagSamplesPerPixel: 1,
+	} {
+		if got := fields[tag].uint(0); got != want {
+			t.Errorf("tag %d: got %d, want %d", tag, got, want)
+		}
+	}
+	if got := string(fields[tagGDALNoData].value); got != "nan\x00" {
+		t.Errorf("got no data value %q, want %q", got, "nan\x00")
+	}
+
+	offset := fields[tagStripOffsets].uint(0)
+	length := fields[tagStripByteCounts].uint(0)
+	got := readFloat32s(data[offset : offset+length])
+	if diff := cmp.Diff(float32s(r.Values), got, cmp.Comparer(sameFloat32)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteRaster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := testRaster(t, render.Equirectangular{})
+	for _, name := range []string{"heights.png", "heights.pfm", "heights.tif", "heights.raw"} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name)
+			err := WriteRaster(r, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadFile(file + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got RasterMetadata
+			err = json.Unmarshal(data, &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != planet.HeightsLayer || got.Width != 20 || got.Height != 10 || got.Projection != "equirectangular" {
+				t.Errorf("got metadata %+v", got)
+			}
+		})
+	}
+
+	err = WriteRaster(r, filepath.Join(dir, "heights.jpg"))
+	if err == nil {
+		t.Error("got WriteRaster() error nil for an unknown format")
+	}
+}
+
+func TestProjectionName(t *testing.T) {
+	if got := ProjectionName(render.Orthographic{}); got != "orthographic" {
+		t.Errorf("got ProjectionName() = %q, want %q", got, "orthographic")
+	}
+}
+
+func float32s(values []float64) []float32 {
+	result := make([]float32, len(values))
+	for i, v := range values {
+		result[i] = float32(v)
+	}
+	return result
+}
+
+func readFloat32s(data []byte) []float32 {
+	result := make([]float32, len(data)/4)
+	for i := range result {
+		result[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return result
+}
+
+func sameFloat32(a, b float32) bool {
+	return a == b || a != a && b != b
+}
+
+// readTIFF returns the fields of the first directory of a little-endian TIFF.
+func readTIFF(t *testing.T, data []byte) map[uint16]tiffField {
+	t.Helper()
+	if !bytes.Equal(data[:4], []byte{'I', 'I', 42, 0}) {
+		t.Fatalf("got header %q, want little-endian TIFF", data[:4])
+	}
+
+	sizes := map[uint16]int{tiffASCII: 1, tiffShort: 2, tiffLong: 4, tiffDouble: 8}
+	offset := binary.LittleEndian.Uint32(data[4:])
+	if offset%2 != 0 {
+		t.Fatalf("got directory at odd offset %d", offset)
+	}
+	n := int(binary.LittleEndian.Uint16(data[offset:]))
+	result := make(map[uint16]tiffField, n)
+	lastTag := uint16(0)
+	for i := 0; i < n; i++ {
+		entry := data[int(offset)+2+12*i:]
+		f := tiffField{
+			tag:   binary.LittleEndian.Uint16(entry),
+			kind:  binary.LittleEndian.Uint16(entry[2:]),
+			count: binary.LittleEndian.Uint32(entry[4:]),
+		}
+		if f.tag <= lastTag {
+			t.Fatalf("got tag %d after %d, want ascending tags", f.tag, lastTag)
+		}
+		lastTag = f.tag
+
+		size := sizes[f.kind] * int(f.count)
+		if size <= 4 {
+			f.value = entry[8 : 8+size]
+		} else {
+			valueOffset := binary.LittleEndian.Uint32(entry[8:])
+			f.value = data[valueOffset : int(valueOffset)+size]
+		}
+		result[f.tag] = f
+	}
+	return result
+}
+
+// uint returns the i-th value of a Short or Long field.
+func (f tiffField) uint(i int) uint32 {
+	if f.kind == tiffShort {
+		return uint32(binary.LittleEndian.Uint16(f.value[2*i:]))
+	}
+	return binary.LittleEndian.Uint32(f.value[4*i:])
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"encoding/json"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/water"
+	"io"
+	"math"
+)
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// WriteRivers writes rivers on g to w as GeoJSON: a FeatureCollection with a
+// line through the centers of the cells of each river, in degrees of
+// longitude and latitude. Each feature has the Strahler order of its river,
+// and the index of the feature it flows into or -1, as properties.
+//
+// As GeoJSON asks, rivers crossing the antimeridian are split there into
+// MultiLineStrings.
+func WriteRivers(w io.Writer, rivers []water.River, g *geodesic.Geodesic) error {
+	collection := geoJSONCollection{
+		Type:     "FeatureCollection",
+		Features: make([]geoJSONFeature, len(rivers)),
+	}
+	for i, r := range rivers {
+		points := make([]geodesic.Vector, len(r.Cells))
+		for j, cell := range r.Cells {
+			points[j] = g.Centers[cell]
+		}
+
+		geometry := geoJSONGeometry{Type: "LineString"}
+		lines := lonLatLines(points)
+		if len(lines) == 1 {
+			geometry.Coordinates = lines[0]
+		} else {
+			geometry.Type = "MultiLineString"
+			geometry.Coordinates = lines
+		}
+
+		collection.Features[i] = geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geometry,
+			Properties: map[string]interface{}{
+				"order": r.Order,
+				"into":  r.Into,
+			},
+		}
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
+
+// lonLatLines returns the longitudes and latitudes of points in degrees,
+// split into lines wherever they cross the antimeridian.
+func lonLatLines(points []geodesic.Vector) [][][2]float64 {
+	var lines [][][2]float64
+	var line [][2]float64
+	for i, p := range points {
+		a := p.Angle()
+		next := [2]float64{a.Phi * 180 / math.Pi, a.Theta * 180 / math.Pi}
+		if i > 0 {
+			prev := line[len(line)-1]
+			if d := next[0] - prev[0]; math.Abs(d) > 180 {
+				// Meet the antimeridian at the latitude it is crossed at.
+				edge := 180.0
+				if d > 0 {
+					edge = -180
+				}
+				unwrapped := next[0] + 2*edge
+				lat := prev[1] + (next[1]-prev[1])*(edge-prev[0])/(unwrapped-prev[0])
+				lines = append(lines, append(line, [2]float64{edge, lat}))
+				line = [][2]float64{{-edge, lat}}
+			}
+		}
+		line = append(line, next)
+	}
+	return append(lines, line)
+}
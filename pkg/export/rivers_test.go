@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/water"
+	"math"
+	"testing"
+)
+
+func TestWriteRivers(t *testing.T) {
+	degrees := func(lon, lat float64) geodesic.Vector {
+		return geodesic.Angle{Theta: lat * math.Pi / 180, Phi: lon * math.Pi / 180}.Vector()
+	}
+	g := &geodesic.Geodesic{Centers: []geodesic.Vector{
+		degrees(0, 0),
+		degrees(10, 20),
+		degrees(170, 0),
+		degrees(-170, 10),
+	}}
+	rivers := []water.River{
+		{Cells: []int{0, 1}, Order: 1, Into: 1},
+		{Cells: []int{2, 3}, Order: 2, Into: -1},
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteRivers(buf, rivers, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := geoJSONCollection{}
+	err = json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := geoJSONCollection{
+		Type: "FeatureCollection",
+		Features: []geoJSONFeature{{
+			Type: "Feature",
+			Geometry: geoJSONGeometry{
+				Type:        "LineString",
+				Coordinates: []interface{}{[]interface{}{0.0, 0.0}, []interface{}{10.0, 20.0}},
+			},
+			Properties: map[string]interface{}{"order": 1.0, "into": 1.0},
+		}, {
+			Type: "Feature",
+			Geometry: geoJSONGeometry{
+				Type: "MultiLineString",
+				Coordinates: []interface{}{
+					[]interface{}{[]interface{}{170.0, 0.0}, []interface{}{180.0, 5.0}},
+					[]interface{}{[]interface{}{-180.0, 5.0}, []interface{}{-170.0, 10.0}},
+				},
+			},
+			Properties: map[string]interface{}{"order": 2.0, "into": -1.0},
+		}},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/willbeason/worldproc/pkg/render"
+	"io"
+	"sort"
+)
+
+// TIFF tags written by WriteTIFF.
+const (
+	tagImageWidth                = 256
+	tagImageLength               = 257
+	tagBitsPerSample             = 258
+	tagCompression               = 259
+	tagPhotometricInterpretation = 262
+	tagStripOffsets              = 273
+	tagSamplesPerPixel           = 277
+	tagRowsPerStrip              = 278
+	tagStripByteCounts           = 279
+	tagPlanarConfiguration       = 284
+	tagExtraSamples              = 338
+	tagSampleFormat              = 339
+	// tagGDALNoData is GDAL's tag for the value of missing pixels.
+	tagGDALNoData = 42113
+)
+
+// TIFF field types.
+const (
+	tiffASCII  = 2
+	tiffShort  = 3
+	tiffLong   = 4
+	tiffDouble = 12
+)
+
+// tiffField is an entry of a TIFF image file directory.
+type tiffField struct {
+	tag, kind uint16
+	count     uint32
+	// value is the little-endian encoding of the field's values.
+	value []byte
+}
+
+func shortField(tag uint16, values ...uint16) tiffField {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, values)
+	return tiffField{tag: tag, kind: tiffShort, count: uint32(len(values)), value: buf.Bytes()}
+}
+
+func longField(tag uint16, values ...uint32) tiffField {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, values)
+	return tiffField{tag: tag, kind: tiffLong, count: uint32(len(values)), value: buf.Bytes()}
+}
+
+func doubleField(tag uint16, values ...float64) tiffField {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, values)
+	return tiffField{tag: tag, kind: tiffDouble, count: uint32(len(values)), value: buf.Bytes()}
+}
+
+func asciiField(tag uint16, s string) tiffField {
+	value := append([]byte(s), 0)
+	return tiffField{tag: tag, kind: tiffASCII, count: uint32(len(value)), value: value}
+}
+
+// WriteTIFF writes r as a little-endian TIFF of 32-bit floats, row by row from
+// the top of the image, with pixels which don't show the sphere NaN.
+//
+// If r was sampled with render.Equirectangular, the TIFF is a GeoTIFF.
+func WriteTIFF(w io.Writer, r *Raster) error {
+	pixels := make([]byte, 4*len(r.Values))
+	putFloat32s(pixels, r.Values)
+
+	fields := []tiffField{
+		shortField(tagBitsPerSample, 32),
+		// BlackIsZero.
+		shortField(tagPhotometricInterpretation, 1),
+		shortField(tagSamplesPerPixel, 1),
+		// IEEE floating point.
+		shortField(tagSampleFormat, 3),
+		asciiField(tagGDALNoData, "nan"),
+	}
+	if _, ok := r.Projection.Projector.(render.Equirectangular); ok {
+		geo, err := geoFields(r.Projection, r.Radius)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, geo...)
+	}
+	return writeTIFF(w, r.Width, r.Height, pixels, fields)
+}
+
+// writeTIFF writes a single strip of uncompressed pixels as a little-endian
+// TIFF, with fields describing the samples of each pixel.
+func writeTIFF(w io.Writer, width, height int, pixels []byte, fields []tiffField) error {
+	const headerSize = 8
+
+	fields = append([]tiffField{
+		longField(tagImageWidth, uint32(width)),
+		longField(tagImageLength, uint32(height)),
+		shortField(tagCompression, 1),
+		longField(tagStripOffsets, headerSize),
+		longField(tagRowsPerStrip, uint32(height)),
+		longField(tagStripByteCounts, uint32(len(pixels))),
+		shortField(tagPlanarConfiguration, 1),
+	}, fields...)
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].tag < fields[j].tag
+	})
+
+	// The image follows the header, then the values too long to fit in the
+	// directory, then the directory itself.
+	values := &bytes.Buffer{}
+	valuesOffset := headerSize + len(pixels)
+	if valuesOffset%2 != 0 {
+		values.WriteByte(0)
+	}
+	directory := &bytes.Buffer{}
+	_ = binary.Write(directory, binary.LittleEndian, uint16(len(fields)))
+	for _, f := range fields {
+		_ = binary.Write(directory, binary.LittleEndian, f.tag)
+		_ = binary.Write(directory, binary.LittleEndian, f.kind)
+		_ = binary.Write(directory, binary.LittleEndian, f.count)
+		if len(f.value) <= 4 {
+			inline := make([]byte, 4)
+			copy(inline, f.value)
+			directory.Write(inline)
+			continue
+		}
+		_ = binary.Write(directory, binary.LittleEndian, uint32(valuesOffset+values.Len()))
+		values.Write(f.value)
+		// Values must start on word boundaries.
+		if values.Len()%2 != 0 {
+			values.WriteByte(0)
+		}
+	}
+	// There is no next directory.
+	_ = binary.Write(directory, binary.LittleEndian, uint32(0))
+
+	directoryOffset := valuesOffset + values.Len()
+	header := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[4:], uint32(directoryOffset))
+
+	for _, data := range [][]byte{header, pixels, values.Bytes(), directory.Bytes()} {
+		_, err := w.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
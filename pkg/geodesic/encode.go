@@ -0,0 +1,275 @@
+package geodesic
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// The binary sphere format stores a Geodesic as flat arrays so it can be
+// decoded without parsing. All values are little-endian.
+//
+//	magic     [4]byte "GEOD"
+//	version   uint32
+//	nFaces    uint32
+//	nLinks    uint32, the total length of all neighbor lists
+//	centers   [nFaces][3]float64
+//	offsets   [nFaces+1]uint32, where face i's neighbors are
+//	          neighbors[offsets[i]:offsets[i+1]]
+//	neighbors [nLinks]uint32
+//	edges     [nLinks]uint32, the edge id between a face and each neighbor
+//	checksum  uint32, the CRC-32 (IEEE) of everything preceding it
+//
+// Version 1 did not order neighbors, so they are ordered when decoding it.
+const (
+	binaryMagic   = "GEOD"
+	binaryVersion = 2
+
+	headerSize = 16
+)
+
+// ErrInvalidFormat is returned when decoding data which is not a valid
+// encoded Geodesic.
+var ErrInvalidFormat = errors.New("geodesic: invalid sphere format")
+
+// Encode writes g to w in the binary sphere format.
+func Encode(w io.Writer, g *Geodesic) error {
+	nFaces := len(g.Faces)
+	nLinks := 0
+	for _, face := range g.Faces {
+		nLinks += len(face.Neighbors)
+	}
+	if len(g.Centers) != nFaces {
+		return fmt.Errorf("geodesic: %d centers for %d faces", len(g.Centers), nFaces)
+	}
+
+	size := headerSize + 24*nFaces + 4*(nFaces+1) + 8*nLinks + 4
+	b := make([]byte, size)
+	copy(b, binaryMagic)
+	binary.LittleEndian.PutUint32(b[4:], binaryVersion)
+	binary.LittleEndian.PutUint32(b[8:], uint32(nFaces))
+	binary.LittleEndian.PutUint32(b[12:], uint32(nLinks))
+
+	pos := headerSize
+	for _, c := range g.Centers {
+		binary.LittleEndian.PutUint64(b[pos:], math.Float64bits(c.X))
+		binary.LittleEndian.PutUint64(b[pos+8:], math.Float64bits(c.Y))
+		binary.LittleEndian.PutUint64(b[pos+16:], math.Float64bits(c.Z))
+		pos += 24
+	}
+
+	offsets := b[pos:]
+	neighbors := offsets[4*(nFaces+1):]
+	edges := neighbors[4*nLinks:]
+
+	if g.edgeIDs == nil {
+		g.ensureEdges()
+	}
+
+	link := 0
+	for i, face := range g.Faces {
+		binary.LittleEndian.PutUint32(offsets[4*i:], uint32(link))
+		for k, n := range face.Neighbors {
+			var id int
+			if g.edgeIDs != nil {
+				id = g.edgeIDs[i][k]
+			} else {
+				id = g.Edges[Edge{L: i, R: n}]
+			}
+			binary.LittleEndian.PutUint32(neighbors[4*link:], uint32(n))
+			binary.LittleEndian.PutUint32(edges[4*link:], uint32(id))
+			link++
+		}
+	}
+	binary.LittleEndian.PutUint32(offsets[4*nFaces:], uint32(link))
+
+	binary.LittleEndian.PutUint32(b[size-4:], crc32.ChecksumIEEE(b[:size-4]))
+
+	_, err := w.Write(b)
+	return err
+}
+
+// Decode reads a Geodesic from data in the binary sphere format.
+//
+// The result does not refer to data, so data may be reused or unmapped once
+// Decode returns.
+func Decode(data []byte) (*Geodesic, error) {
+	if len(data) < headerSize+4 || string(data[:4]) != binaryMagic {
+		return nil, ErrInvalidFormat
+	}
+	version := binary.LittleEndian.Uint32(data[4:])
+	if version != 1 && version != binaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidFormat, version)
+	}
+	nFaces := int(binary.LittleEndian.Uint32(data[8:]))
+	nLinks := int(binary.LittleEndian.Uint32(data[12:]))
+
+	size := headerSize + 24*nFaces + 4*(nFaces+1) + 8*nLinks + 4
+	if len(data) != size {
+		return nil, fmt.Errorf("%w: got %d bytes, want %d", ErrInvalidFormat, len(data), size)
+	}
+	want := binary.LittleEndian.Uint32(data[size-4:])
+	if got := crc32.ChecksumIEEE(data[:size-4]); got != want {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidFormat)
+	}
+
+	g := &Geodesic{
+		Centers: make([]Vector, nFaces),
+		Faces:   make([]Node, nFaces),
+		edgeIDs: make([][]int, nFaces),
+	}
+
+	pos := headerSize
+	for i := range g.Centers {
+		g.Centers[i] = Vector{
+			X: math.Float64frombits(binary.LittleEndian.Uint64(data[pos:])),
+			Y: math.Float64frombits(binary.LittleEndian.Uint64(data[pos+8:])),
+			Z: math.Float64frombits(binary.LittleEndian.Uint64(data[pos+16:])),
+		}
+		pos += 24
+	}
+
+	offsets := data[pos:]
+	neighbors := offsets[4*(nFaces+1):]
+	edges := neighbors[4*nLinks:]
+
+	// Every face's neighbors share one backing array so decoding doesn't
+	// allocate per face.
+	allNeighbors := make([]int, nLinks)
+	allEdges := make([]int, nLinks)
+	for link := range allNeighbors {
+		n := int(binary.LittleEndian.Uint32(neighbors[4*link:]))
+		if n >= nFaces {
+			return nil, fmt.Errorf("%w: neighbor %d out of range", ErrInvalidFormat, n)
+		}
+		e := int(binary.LittleEndian.Uint32(edges[4*link:]))
+		if e >= nLinks {
+			return nil, fmt.Errorf("%w: edge %d out of range", ErrInvalidFormat, e)
+		}
+		allNeighbors[link] = n
+		allEdges[link] = e
+	}
+
+	start := 0
+	if binary.LittleEndian.Uint32(offsets) != 0 {
+		return nil, fmt.Errorf("%w: bad offset for face 0", ErrInvalidFormat)
+	}
+	for i := range g.Faces {
+		end := int(binary.LittleEndian.Uint32(offsets[4*(i+1):]))
+		if end < start || end > nLinks {
+			return nil, fmt.Errorf("%w: bad offset for face %d", ErrInvalidFormat, i)
+		}
+		g.Faces[i].Neighbors = allNeighbors[start:end:end]
+		g.edgeIDs[i] = allEdges[start:end:end]
+		start = end
+	}
+
+	if version == 1 {
+		g.orderNeighbors()
+	}
+	return g, nil
+}
+
+// DecodeJSON reads a Geodesic from the JSON format used by older sphere caches.
+//
+// The JSON format doesn't record edge ids. They are recovered if the sphere is
+// the Dodecahedron or was chamfered from another sphere, as every cached
+// sphere was, so chamfering the result numbers the new faces exactly as
+// chamfering the original sphere does. Otherwise they are renumbered when
+// needed, and chamfering may number the new faces differently.
+func DecodeJSON(data []byte) (*Geodesic, error) {
+	g := &Geodesic{}
+	err := json.Unmarshal(data, g)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Centers) != len(g.Faces) {
+		return nil, fmt.Errorf("%w: %d centers for %d faces", ErrInvalidFormat, len(g.Centers), len(g.Faces))
+	}
+	for i, face := range g.Faces {
+		for _, n := range face.Neighbors {
+			if n < 0 || n >= len(g.Faces) {
+				return nil, fmt.Errorf("%w: face %d has neighbor %d out of range", ErrInvalidFormat, i, n)
+			}
+		}
+	}
+	g.orderNeighbors()
+	g.Edges = recoverEdges(g)
+	return g, nil
+}
+
+// recoverEdges returns the edge ids of g if it is the Dodecahedron or was
+// chamfered from another sphere, or nil if it is neither.
+//
+// Chamfer numbers the face it adds for each edge by the edge's id, after the
+// faces it keeps. So the sphere g was chamfered from, and that sphere's edge
+// ids, can be read from g, and chamfering it again links g's edges in the
+// order Chamfer first linked them.
+func recoverEdges(g *Geodesic) map[Edge]int {
+	if len(g.Faces) == 12 {
+		d := Dodecahedron()
+		if !sameFaces(d, g) {
+			return nil
+		}
+		return d.Edges
+	}
+
+	// Chamfer keeps every face and adds one for each edge, and a sphere of
+	// n faces has 3n-6 edges.
+	if (len(g.Faces)+6)%4 != 0 {
+		return nil
+	}
+	nFaces := (len(g.Faces) + 6) / 4
+	parent := &Geodesic{
+		Centers: g.Centers[:nFaces],
+		Faces:   make([]Node, nFaces),
+		Edges:   map[Edge]int{},
+	}
+	for added := nFaces; added < len(g.Faces); added++ {
+		var kept []int
+		for _, n := range g.Faces[added].Neighbors {
+			if n < nFaces {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) != 2 {
+			return nil
+		}
+		i, j := kept[0], kept[1]
+		if _, found := parent.Edges[Edge{L: i, R: j}]; found {
+			return nil
+		}
+		parent.Edges[Edge{L: i, R: j}] = added - nFaces
+		parent.Edges[Edge{L: j, R: i}] = added - nFaces
+		parent.Faces[i].Neighbors = append(parent.Faces[i].Neighbors, j)
+		parent.Faces[j].Neighbors = append(parent.Faces[j].Neighbors, i)
+	}
+
+	chamfered := Chamfer(parent)
+	if !sameFaces(chamfered, g) {
+		return nil
+	}
+	return chamfered.Edges
+}
+
+// sameFaces returns whether a and b have the same neighbors in the same order.
+func sameFaces(a, b *Geodesic) bool {
+	if len(a.Faces) != len(b.Faces) {
+		return false
+	}
+	for i := range a.Faces {
+		if len(a.Faces[i].Neighbors) != len(b.Faces[i].Neighbors) {
+			return false
+		}
+		for k, n := range a.Faces[i].Neighbors {
+			if b.Faces[i].Neighbors[k] != n {
+				return false
+			}
+		}
+	}
+	return true
+}
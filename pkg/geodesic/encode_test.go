@@ -0,0 +1,204 @@
+package geodesic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/store"
+	"hash/crc32"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	g := Dodecahedron()
+	for i := 0; i < 3; i++ {
+		g = Chamfer(g)
+	}
+
+	buf := &bytes.Buffer{}
+	err := Encode(buf, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Decode(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(g.Centers, got.Centers); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(g.Faces, got.Faces); diff != "" {
+		t.Error(diff)
+	}
+
+	// Decoded spheres must chamfer exactly like the original.
+	want := Chamfer(g)
+	gotNext := Chamfer(got)
+	if diff := cmp.Diff(want.Centers, gotNext.Centers); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(want.Faces, gotNext.Faces); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecode_Version1(t *testing.T) {
+	g := Chamfer(Chamfer(Dodecahedron()))
+
+	// Version 1 stored neighbors in whatever order they were linked.
+	unordered := &Geodesic{
+		Centers: g.Centers,
+		Faces:   make([]Node, len(g.Faces)),
+		Edges:   g.Edges,
+	}
+	for i, face := range g.Faces {
+		for k := range face.Neighbors {
+			n := face.Neighbors[len(face.Neighbors)-1-k]
+			unordered.Faces[i].Neighbors = append(unordered.Faces[i].Neighbors, n)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := Encode(buf, unordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint32(data[4:], 1)
+	binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.ChecksumIEEE(data[:len(data)-4]))
+
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(g.Faces, got.Faces); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(Chamfer(g).Faces, Chamfer(got).Faces); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecode_Corrupt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := Encode(buf, Dodecahedron())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		name   string
+		mutate func([]byte) []byte
+	}{
+		{
+			name:   "empty",
+			mutate: func(b []byte) []byte { return nil },
+		},
+		{
+			name: "bad magic",
+			mutate: func(b []byte) []byte {
+				b[0] = 'X'
+				return b
+			},
+		},
+		{
+			name: "flipped bit",
+			mutate: func(b []byte) []byte {
+				b[100] ^= 1
+				return b
+			},
+		},
+		{
+			name:   "truncated",
+			mutate: func(b []byte) []byte { return b[:len(b)-1] },
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			data := append([]byte{}, buf.Bytes()...)
+			_, err := Decode(tc.mutate(data))
+			if !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("got Decode() error %v, want %v", err, ErrInvalidFormat)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	g := Dodecahedron()
+	for i := 0; i < 3; i++ {
+		data, err := json.Marshal(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := DecodeJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Decoded spheres must chamfer exactly like the original.
+		want := Chamfer(g)
+		next := Chamfer(got)
+		if diff := cmp.Diff(want.Centers, next.Centers); diff != "" {
+			t.Errorf("%d faces: %s", len(g.Faces), diff)
+		}
+		if diff := cmp.Diff(want.Faces, next.Faces); diff != "" {
+			t.Errorf("%d faces: %s", len(g.Faces), diff)
+		}
+		g = want
+	}
+}
+
+func TestDecodeJSON_Unchamfered(t *testing.T) {
+	// A sphere which wasn't chamfered from another still decodes, and its
+	// edges are numbered when needed.
+	g, err := Icosahedral{Frequency: 3}.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Edges != nil {
+		t.Error("got edges recovered from a sphere which wasn't chamfered")
+	}
+	if next := Chamfer(got); len(next.Faces) != 4*len(g.Faces)-6 {
+		t.Errorf("got %d faces after chamfering, want %d", len(next.Faces), 4*len(g.Faces)-6)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	s := store.NewMemory()
+
+	want, err := Load(s, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second Load reads the spheres written by the first.
+	got, err := Load(s, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, g := range want {
+		if diff := cmp.Diff(g.Faces, got[i].Faces); diff != "" {
+			t.Error(diff)
+		}
+	}
+	if len(got[3].Faces) != 642 {
+		t.Errorf("got len(Load()[3].Faces) = %d, want %d", len(got[3].Faces), 642)
+	}
+}
@@ -0,0 +1,208 @@
+package geodesic
+
+import (
+	"fmt"
+)
+
+// Generator creates a geodesic sphere.
+type Generator interface {
+	// Name identifies the sphere Generate creates, and is used as the key to
+	// cache it under.
+	Name() string
+	// Generate creates the sphere, or returns an error if the Generator can't
+	// make one.
+	Generate() (*Geodesic, error)
+}
+
+// Chamfered generates the dodecahedron chamfered Size times, which has
+// 10*4^Size+2 faces. This is the sequence of spheres New and Load return, and
+// the only one that planets can be resized between.
+type Chamfered struct {
+	Size int
+}
+
+var _ Generator = Chamfered{}
+
+func (c Chamfered) Name() string {
+	return fmt.Sprintf("sphere-%02d", c.Size)
+}
+
+func (c Chamfered) Generate() (*Geodesic, error) {
+	if c.Size < 0 {
+		return nil, fmt.Errorf("chamfered sphere size must be at least 0, got %d", c.Size)
+	}
+	g := Dodecahedron()
+	for i := 0; i < c.Size; i++ {
+		g = Chamfer(g)
+	}
+	return g, nil
+}
+
+// Icosahedral generates the Goldberg polyhedron whose faces are centered on
+// the vertices of an icosahedron with each triangle divided into Frequency^2
+// smaller triangles. It has 10*Frequency^2+2 faces, so any frequency gives a
+// sphere between the sizes Chamfered can make. For example, frequency 300 has
+// 900,002 faces.
+//
+// The first 12 faces are the faces of the dodecahedron, in the same order.
+type Icosahedral struct {
+	Frequency int
+}
+
+var _ Generator = Icosahedral{}
+
+func (ico Icosahedral) Name() string {
+	return fmt.Sprintf("icosahedral-%d", ico.Frequency)
+}
+
+func (ico Icosahedral) Generate() (*Geodesic, error) {
+	n := ico.Frequency
+	if n < 1 {
+		return nil, fmt.Errorf("icosahedral sphere frequency must be at least 1, got %d", n)
+	}
+	base := Dodecahedron()
+	base.ensureEdges()
+	nBase := len(base.Faces)
+	nEdges := len(base.Edges) / 2
+	triangles := base.Triangles()
+
+	nFaces := 10*n*n + 2
+	g := &Geodesic{
+		Centers: make([]Vector, nFaces),
+		Faces:   make([]Node, nFaces),
+	}
+	set := make([]bool, nFaces)
+	for i, c := range base.Centers {
+		g.Centers[i] = c
+		set[i] = true
+	}
+
+	// Faces are numbered with the icosahedron's vertices first, then the
+	// points along each of its edges, then the points inside each triangle.
+	interiorPerTriangle := (n - 1) * (n - 2) / 2
+	edgePoint := func(p, q, t int) int {
+		// The point t steps from p toward q.
+		if q < p {
+			p, q, t = q, p, n-t
+		}
+		return nBase + base.Edges[Edge{L: p, R: q}]*(n-1) + t - 1
+	}
+
+	for tIdx, tri := range triangles {
+		a, b, c := tri[0], tri[1], tri[2]
+		interior := nBase + nEdges*(n-1) + tIdx*interiorPerTriangle
+
+		// index returns the face at the lattice point i steps toward b and j
+		// steps toward c from a.
+		index := func(i, j int) int {
+			switch {
+			case i == 0 && j == 0:
+				return a
+			case i == n:
+				return b
+			case j == n:
+				return c
+			case j == 0:
+				return edgePoint(a, b, i)
+			case i == 0:
+				return edgePoint(a, c, j)
+			case i+j == n:
+				return edgePoint(b, c, j)
+			}
+			// Interior points in rows of increasing j.
+			row := j - 1
+			before := row*(n-2) - row*(row-1)/2
+			return interior + before + i - 1
+		}
+
+		for j := 0; j <= n; j++ {
+			for i := 0; i+j <= n; i++ {
+				f := index(i, j)
+				if !set[f] {
+					w := 1.0 / float64(n)
+					g.Centers[f] = base.Centers[a].Scale(float64(n-i-j) * w).
+						Add(base.Centers[b].Scale(float64(i) * w)).
+						Add(base.Centers[c].Scale(float64(j) * w)).
+						Normalize()
+					set[f] = true
+				}
+
+				// Each lattice point starts up to two small triangles.
+				if i+j < n {
+					g.addTriangle(f, index(i+1, j), index(i, j+1))
+				}
+				if i+j < n-1 {
+					g.addTriangle(index(i+1, j), index(i+1, j+1), index(i, j+1))
+				}
+			}
+		}
+	}
+
+	g.orderNeighbors()
+	return g, nil
+}
+
+// addTriangle makes each of the three faces neighbors of the other two.
+func (g *Geodesic) addTriangle(a, b, c int) {
+	g.addNeighbor(a, b)
+	g.addNeighbor(a, c)
+	g.addNeighbor(b, c)
+}
+
+// addNeighbor makes a and b neighbors if they are not already. Unlike Link, it
+// does not number the edge between them.
+func (g *Geodesic) addNeighbor(a, b int) {
+	for _, n := range g.Faces[a].Neighbors {
+		if n == b {
+			return
+		}
+	}
+	g.Faces[a].Neighbors = append(g.Faces[a].Neighbors, b)
+	g.Faces[b].Neighbors = append(g.Faces[b].Neighbors, a)
+}
+
+// Centroidal relaxes the sphere Generator generates towards a centroidal
+// Voronoi tessellation, where each face's center is also its center of mass.
+// This makes faces more regular and closer in area.
+//
+// Each iteration moves every face's center to its center of mass, keeping
+// the faces' neighbors and numbering.
+type Centroidal struct {
+	Generator  Generator
+	Iterations int
+}
+
+var _ Generator = Centroidal{}
+
+func (c Centroidal) Name() string {
+	return fmt.Sprintf("%s-centroidal-%d", c.Generator.Name(), c.Iterations)
+}
+
+func (c Centroidal) Generate() (*Geodesic, error) {
+	g, err := c.Generator.Generate()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < c.Iterations; i++ {
+		geometry := newGeometry(g)
+
+		centers := make([]Vector, len(g.Centers))
+		for f, center := range g.Centers {
+			corners := geometry.Corners[f]
+			sum := Vector{}
+			for j, corner := range corners {
+				next := corners[(j+1)%len(corners)]
+				area := TriangleArea(center, corner, next)
+				sum = sum.Add(center.Add(corner).Add(next).Scale(area))
+			}
+			centers[f] = sum.Normalize()
+		}
+
+		g = &Geodesic{
+			Centers: centers,
+			Faces:   g.Faces,
+			Edges:   g.Edges,
+		}
+	}
+	return g, nil
+}
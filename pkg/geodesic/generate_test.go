@@ -0,0 +1,184 @@
+package geodesic
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/willbeason/worldproc/pkg/store"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestIcosahedral(t *testing.T) {
+	for _, frequency := range []int{1, 2, 3, 4, 5, 7, 16} {
+		t.Run(fmt.Sprintf("n = %d", frequency), func(t *testing.T) {
+			g, err := Icosahedral{Frequency: frequency}.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := 10*frequency*frequency + 2; len(g.Faces) != want {
+				t.Fatalf("got %d faces, want %d", len(g.Faces), want)
+			}
+
+			pentagons := 0
+			for i, face := range g.Faces {
+				switch len(face.Neighbors) {
+				case 5:
+					pentagons++
+				case 6:
+				default:
+					t.Fatalf("face %d: got %d neighbors, want 5 or 6", i, len(face.Neighbors))
+				}
+			}
+			if pentagons != 12 {
+				t.Errorf("got %d pentagons, want 12", pentagons)
+			}
+
+			testNeighbors(t, g)
+
+			total := 0.0
+			for _, a := range g.Geometry().Areas {
+				total += a
+			}
+			if diff := cmp.Diff(4*math.Pi, total, cmpopts.EquateApprox(1e-9, 0)); diff != "" {
+				t.Error(diff)
+			}
+
+			// Faces may be found and chamfered like any other sphere.
+			idx := NewIndex(g)
+			r := rand.New(rand.NewSource(int64(frequency)))
+			for i := 0; i < 1000; i++ {
+				v := randomVector(r)
+				if got, want := idx.Find(v), NaiveFind(g, v); got != want {
+					t.Fatalf("got Find(%v) = %d, want %d", v, got, want)
+				}
+			}
+			if want := 4*len(g.Faces) - 6; len(Chamfer(g).Faces) != want {
+				t.Errorf("got len(Chamfer().Faces) = %d, want %d", len(Chamfer(g).Faces), want)
+			}
+		})
+	}
+}
+
+func TestIcosahedral_Dodecahedron(t *testing.T) {
+	got, err := Icosahedral{Frequency: 1}.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Dodecahedron()
+
+	if diff := cmp.Diff(want.Centers, got.Centers); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(want.Faces, got.Faces); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGenerate_Invalid(t *testing.T) {
+	for _, gen := range []Generator{
+		Chamfered{Size: -1},
+		Icosahedral{Frequency: 0},
+		Icosahedral{Frequency: -3},
+		Centroidal{Generator: Icosahedral{Frequency: 0}, Iterations: 1},
+	} {
+		t.Run(gen.Name(), func(t *testing.T) {
+			if _, err := gen.Generate(); err == nil {
+				t.Error("got no error")
+			}
+			if _, err := LoadGenerated(store.NewMemory(), gen); err == nil {
+				t.Error("got no error loading")
+			}
+		})
+	}
+}
+
+// offset returns the largest distance from a face's center to its center of
+// mass, relative to the size of the face.
+func offset(g *Geodesic) float64 {
+	geometry := g.Geometry()
+	result := 0.0
+	for i, c := range g.Centers {
+		corners := geometry.Corners[i]
+		sum := Vector{}
+		for j, corner := range corners {
+			next := corners[(j+1)%len(corners)]
+			sum = sum.Add(c.Add(corner).Add(next).Scale(TriangleArea(c, corner, next)))
+		}
+		result = math.Max(result, Arc(sum.Normalize(), c)/math.Sqrt(geometry.Areas[i]))
+	}
+	return result
+}
+
+func TestCentroidal(t *testing.T) {
+	spread := func(g *Geodesic) float64 {
+		min, max := math.Inf(1), 0.0
+		for _, a := range g.Geometry().Areas {
+			min = math.Min(min, a)
+			max = math.Max(max, a)
+		}
+		return max / min
+	}
+
+	for _, base := range []Generator{Chamfered{Size: 3}, Icosahedral{Frequency: 10}} {
+		t.Run(base.Name(), func(t *testing.T) {
+			g, err := base.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			relaxed, err := Centroidal{Generator: base, Iterations: 20}.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, before := spread(relaxed), spread(g); got >= before {
+				t.Errorf("got largest area %.3f times smallest, want less than %.3f", got, before)
+			}
+			testNeighbors(t, relaxed)
+
+			// Centers move toward their faces' centers of mass.
+			if got, before := offset(relaxed), offset(g); got > before/10 {
+				t.Errorf("got centers up to %.3g from center of mass, want at most %.3g", got, before/10)
+			}
+		})
+	}
+}
+
+func TestLoadGenerated(t *testing.T) {
+	s := store.NewMemory()
+	gen := Icosahedral{Frequency: 6}
+
+	want, err := LoadGenerated(s, gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(gen.Name() + ".bin"); err != nil {
+		t.Fatalf("sphere not cached: %v", err)
+	}
+
+	got, err := LoadGenerated(s, gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want.Centers, got.Centers); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(want.Faces, got.Faces); diff != "" {
+		t.Error(diff)
+	}
+
+	// Chamfered spheres share the cache Load uses.
+	spheres, err := Load(s, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chamfered, err := LoadGenerated(s, Chamfered{Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(spheres[2].Faces, chamfered.Faces); diff != "" {
+		t.Error(diff)
+	}
+}
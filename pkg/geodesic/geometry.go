@@ -0,0 +1,128 @@
+package geodesic
+
+import (
+	"math"
+)
+
+// Geometry describes the shape of each face of a Geodesic on the unit sphere.
+//
+// Faces are the spherical polygons of points closer to their center than to
+// any neighbor's, so each corner is equidistant from a face and two of its
+// neighbors.
+type Geometry struct {
+	// Corners lists the corners of each face, in the same order as Faces.
+	// Corner j is shared with neighbors j and j+1, and is the circumcenter of
+	// the triangle of their centers.
+	Corners [][]Vector
+	// Areas is the area of each face. The areas sum to 4*Pi.
+	Areas []float64
+	// EdgeLengths is the length of the edge each face shares with each of its
+	// neighbors, in the same order as Faces.
+	EdgeLengths [][]float64
+	// Distances is the great-circle distance from each face's center to each
+	// of its neighbors' centers, in the same order as Faces.
+	Distances [][]float64
+}
+
+// Geometry returns the shape of g's faces, computing it the first time it is
+// needed. g must not be modified afterward.
+func (g *Geodesic) Geometry() *Geometry {
+	g.geometryOnce.Do(func() {
+		g.geometry = newGeometry(g)
+	})
+	return g.geometry
+}
+
+func newGeometry(g *Geodesic) *Geometry {
+	n := len(g.Faces)
+	result := &Geometry{
+		Corners:     make([][]Vector, n),
+		Areas:       make([]float64, n),
+		EdgeLengths: make([][]float64, n),
+		Distances:   make([][]float64, n),
+	}
+
+	for i, face := range g.Faces {
+		c := g.Centers[i]
+		k := len(face.Neighbors)
+
+		corners := make([]Vector, k)
+		for j, n := range face.Neighbors {
+			a := g.Centers[n]
+			b := g.Centers[face.Neighbors[(j+1)%k]]
+			corners[j] = Circumcenter(c, a, b)
+		}
+		result.Corners[i] = corners
+
+		area := 0.0
+		for j := range corners {
+			area += TriangleArea(c, corners[j], corners[(j+1)%k])
+		}
+		result.Areas[i] = area
+
+		lengths := make([]float64, k)
+		distances := make([]float64, k)
+		for j, n := range face.Neighbors {
+			// The edge with neighbor j runs between the corners on either side
+			// of it.
+			lengths[j] = Arc(corners[(j+k-1)%k], corners[j])
+			distances[j] = Arc(c, g.Centers[n])
+		}
+		result.EdgeLengths[i] = lengths
+		result.Distances[i] = distances
+	}
+
+	return result
+}
+
+// Circumcenter returns the point on the unit sphere equidistant from a, b and
+// c, on the same side of the sphere as them.
+func Circumcenter(a, b, c Vector) Vector {
+	result := b.Sub(a).Cross(c.Sub(a)).Normalize()
+	if result.Dot(a) < 0 {
+		return result.Scale(-1)
+	}
+	return result
+}
+
+// TriangleArea returns the area of the spherical triangle with corners at the
+// unit vectors a, b and c.
+func TriangleArea(a, b, c Vector) float64 {
+	// Van Oosterom and Strackee's formula for the solid angle.
+	numerator := math.Abs(a.Dot(b.Cross(c)))
+	denominator := 1 + a.Dot(b) + b.Dot(c) + c.Dot(a)
+	return 2 * math.Atan2(numerator, denominator)
+}
+
+// Arc returns the great-circle distance between the unit vectors a and b.
+func Arc(a, b Vector) float64 {
+	return math.Atan2(a.Cross(b).Length(), a.Dot(b))
+}
+
+// SurfaceNormals returns the unit normal at the center of each face of the
+// surface made by moving every center away from the origin by exaggeration
+// times its height.
+//
+// Each normal averages the normals of the triangles of centers around the
+// face, weighted by their areas.
+func SurfaceNormals(g *Geodesic, heights []float64, exaggeration float64) []Vector {
+	positions := make([]Vector, len(g.Centers))
+	for i, c := range g.Centers {
+		positions[i] = c.Scale(1 + exaggeration*heights[i])
+	}
+
+	// The cross product of two sides of a triangle is twice its area, so
+	// summing them weights each triangle by its area.
+	result := make([]Vector, len(g.Centers))
+	for _, tri := range g.Triangles() {
+		a, b, c := positions[tri[0]], positions[tri[1]], positions[tri[2]]
+		n := b.Sub(a).Cross(c.Sub(a))
+		for _, v := range tri {
+			result[v] = result[v].Add(n)
+		}
+	}
+	for i, n := range result {
+		result[i] = n.Normalize()
+	}
+	return result
+}
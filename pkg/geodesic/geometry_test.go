@@ -0,0 +1,70 @@
+package geodesic
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"math"
+	"testing"
+)
+
+func TestGeodesic_Geometry(t *testing.T) {
+	g := Dodecahedron()
+	for iterations := 0; iterations < 5; iterations++ {
+		t.Run(fmt.Sprintf("m = %d", 1<<iterations), func(t *testing.T) {
+			geometry := g.Geometry()
+
+			total := 0.0
+			for _, a := range geometry.Areas {
+				total += a
+			}
+			if diff := cmp.Diff(4*math.Pi, total, cmpopts.EquateApprox(1e-9, 0)); diff != "" {
+				t.Error(diff)
+			}
+
+			for i, face := range g.Faces {
+				if len(geometry.Corners[i]) != len(face.Neighbors) {
+					t.Fatalf("face %d: got %d corners, want %d", i, len(geometry.Corners[i]), len(face.Neighbors))
+				}
+
+				// Corners are counter-clockwise around the center.
+				c := g.Centers[i]
+				for j, corner := range geometry.Corners[i] {
+					next := geometry.Corners[i][(j+1)%len(face.Neighbors)]
+					if corner.Sub(c).Cross(next.Sub(c)).Dot(c) <= 0 {
+						t.Fatalf("face %d: corners not counter-clockwise", i)
+					}
+				}
+
+				for k, n := range face.Neighbors {
+					// Both faces agree on their shared edge.
+					var back int
+					for back = range g.Faces[n].Neighbors {
+						if g.Faces[n].Neighbors[back] == i {
+							break
+						}
+					}
+					if diff := cmp.Diff(geometry.EdgeLengths[i][k], geometry.EdgeLengths[n][back], cmpopts.EquateApprox(1e-9, 0)); diff != "" {
+						t.Fatalf("edge %d-%d: %s", i, n, diff)
+					}
+					if geometry.EdgeLengths[i][k] <= 0 || geometry.Distances[i][k] <= 0 {
+						t.Fatalf("edge %d-%d: got length %f and distance %f, want positive", i, n,
+							geometry.EdgeLengths[i][k], geometry.Distances[i][k])
+					}
+				}
+			}
+		})
+		g = Chamfer(g)
+	}
+}
+
+func TestGeodesic_Geometry_Dodecahedron(t *testing.T) {
+	geometry := Dodecahedron().Geometry()
+
+	// Every face of a regular dodecahedron has the same area.
+	for i, a := range geometry.Areas {
+		if diff := cmp.Diff(math.Pi/3, a, cmpopts.EquateApprox(1e-6, 0)); diff != "" {
+			t.Errorf("face %d: %s", i, diff)
+		}
+	}
+}
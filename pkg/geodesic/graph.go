@@ -2,9 +2,14 @@ package geodesic
 
 import (
 	"math"
+	"sort"
+	"sync"
 )
 
 type Node struct {
+	// Neighbors lists the adjacent faces counter-clockwise around the face's
+	// center when seen from outside the sphere, starting with the lowest
+	// index.
 	Neighbors []int `json:"Neighbors"`
 }
 
@@ -21,6 +26,14 @@ type Geodesic struct {
 	// Faces is a list of the neighboring faces of each
 	Faces []Node `json:"nodes"`
 	Edges map[Edge]int `json:"-"`
+
+	// edgeIDs records the edge between each face and its neighbors, in the same
+	// order as Faces. Decoded spheres keep edges in this form until Edges is
+	// needed since building the map is expensive for large spheres.
+	edgeIDs [][]int
+
+	geometry     *Geometry
+	geometryOnce sync.Once
 }
 
 const sin_atan0_5 = 0.447213595
@@ -96,10 +109,12 @@ func Dodecahedron() *Geodesic {
 	g.Link(11, 9)
 	g.Link(11, 10)
 
+	g.orderNeighbors()
 	return g
 }
 
 func (g *Geodesic) Link(i, j int) {
+	g.ensureEdges()
 	if _, found := g.Edges[Edge{L: i, R: j}]; found {
 		return
 	}
@@ -126,8 +141,38 @@ func bisect(n1, n2 Vector) Vector {
 	}
 }
 
+// ensureEdges populates Edges if it has not been set.
+//
+// Uses the edge ids recorded when the Geodesic was decoded if there are any.
+// Otherwise numbers edges in the order they are first seen, which may differ
+// from the order they were originally linked.
+func (g *Geodesic) ensureEdges() {
+	if g.Edges != nil {
+		return
+	}
+
+	g.Edges = make(map[Edge]int)
+	next := 0
+	for i, face := range g.Faces {
+		for k, n := range face.Neighbors {
+			if g.edgeIDs != nil {
+				g.Edges[Edge{L: i, R: n}] = g.edgeIDs[i][k]
+				continue
+			}
+			if _, found := g.Edges[Edge{L: i, R: n}]; found {
+				continue
+			}
+			g.Edges[Edge{L: i, R: n}] = next
+			g.Edges[Edge{L: n, R: i}] = next
+			next++
+		}
+	}
+	g.edgeIDs = nil
+}
+
 // Chamfer replaces all edges in the Geodesic with a hexagon.
 func Chamfer(g *Geodesic) *Geodesic {
+	g.ensureEdges()
 	nFaces := len(g.Faces)
 	nEdges := len(g.Edges)/2
 	result := &Geodesic{
@@ -138,8 +183,14 @@ func Chamfer(g *Geodesic) *Geodesic {
 	copy(result.Centers, g.Centers)
 
 	for faceIdx, face := range g.Faces {
+		// Visit neighbors in the order they were linked rather than the order
+		// they are stored in, as that determines how the new faces are
+		// numbered and spheres have always been numbered this way.
+		order := linkOrder(g, faceIdx)
+
 		// For each Node.
-		for n1id, n1 := range face.Neighbors {
+		for _, n1id := range order {
+			n1 := face.Neighbors[n1id]
 			// For each neighbor, create a new face.
 
 			// Link it to the edge separating it from its neighbor.
@@ -150,7 +201,8 @@ func Chamfer(g *Geodesic) *Geodesic {
 			result.Centers[idIJ] = bisect(g.Centers[faceIdx], g.Centers[n1])
 
 			// Link the new neighbor to its neighbors.
-			for n2id, n2 := range face.Neighbors {
+			for _, n2id := range order {
+				n2 := face.Neighbors[n2id]
 				if n1id == n2id {
 					// We don't allow faces to be self-adjacent.
 					continue
@@ -165,6 +217,98 @@ func Chamfer(g *Geodesic) *Geodesic {
 		}
 	}
 
+	result.orderNeighbors()
+	return result
+}
+
+// linkOrder returns the positions in face i's neighbor list, ordered by the
+// id of the edge to each neighbor. Since Link numbers edges as it goes, this
+// is the order the neighbors were linked in.
+func linkOrder(g *Geodesic, i int) []int {
+	neighbors := g.Faces[i].Neighbors
+	order := make([]int, len(neighbors))
+	for k := range order {
+		order[k] = k
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return g.Edges[Edge{L: i, R: neighbors[order[a]]}] < g.Edges[Edge{L: i, R: neighbors[order[b]]}]
+	})
+	return order
+}
+
+// orderNeighbors sorts the neighbors of every face counter-clockwise around
+// its center, starting with the lowest index, as Node documents.
+func (g *Geodesic) orderNeighbors() {
+	for i := range g.Faces {
+		order := counterClockwise(g, i)
+
+		// Start with the lowest index so the order doesn't depend on how the
+		// angles were measured.
+		first := 0
+		for k, pos := range order {
+			if g.Faces[i].Neighbors[pos] < g.Faces[i].Neighbors[order[first]] {
+				first = k
+			}
+		}
+		order = append(order[first:], order[:first]...)
+
+		neighbors := make([]int, len(order))
+		for k, pos := range order {
+			neighbors[k] = g.Faces[i].Neighbors[pos]
+		}
+		copy(g.Faces[i].Neighbors, neighbors)
+
+		if g.edgeIDs != nil {
+			ids := make([]int, len(order))
+			for k, pos := range order {
+				ids[k] = g.edgeIDs[i][pos]
+			}
+			copy(g.edgeIDs[i], ids)
+		}
+	}
+}
+
+// counterClockwise returns the positions in face i's neighbor list, ordered
+// counter-clockwise around its center.
+func counterClockwise(g *Geodesic, i int) []int {
+	c := g.Centers[i]
+	neighbors := g.Faces[i].Neighbors
+
+	// Measure angles in the plane tangent to c.
+	e1 := Vector{X: 1}
+	if math.Abs(c.X) > 0.9 {
+		e1 = Vector{Y: 1}
+	}
+	e1 = e1.Reject(c).Normalize()
+	e2 := c.Cross(e1)
+
+	angles := make([]float64, len(neighbors))
+	order := make([]int, len(neighbors))
+	for k, n := range neighbors {
+		d := g.Centers[n].Sub(c)
+		angles[k] = math.Atan2(d.Dot(e2), d.Dot(e1))
+		order[k] = k
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return angles[order[a]] < angles[order[b]]
+	})
+	return order
+}
+
+// Triangles returns every triangle of mutually adjacent faces once, with
+// corners counter-clockwise when seen from outside the sphere. The
+// circumcenter of each triangle is the corner its three faces share.
+func (g *Geodesic) Triangles() [][3]int {
+	var result [][3]int
+	for i, face := range g.Faces {
+		k := len(face.Neighbors)
+		for j, a := range face.Neighbors {
+			b := face.Neighbors[(j+1)%k]
+			if i < a && i < b {
+				result = append(result, [3]int{i, a, b})
+			}
+		}
+	}
 	return result
 }
 
@@ -1,6 +1,7 @@
 package geodesic
 
 import (
+	"fmt"
 	"github.com/google/go-cmp/cmp"
 	"testing"
 )
@@ -153,3 +154,74 @@ func TestGoldberg(t *testing.T) {
 		})
 	}
 }
+
+func TestChamfer_Neighbors(t *testing.T) {
+	g := Dodecahedron()
+	for iterations := 0; iterations < 5; iterations++ {
+		t.Run(fmt.Sprintf("m = %d", 1<<iterations), func(t *testing.T) {
+			testNeighbors(t, g)
+		})
+		g = Chamfer(g)
+	}
+}
+
+// testNeighbors checks that every face's neighbors are ordered as Node
+// documents.
+func testNeighbors(t *testing.T, g *Geodesic) {
+	t.Helper()
+	for i, face := range g.Faces {
+		c := g.Centers[i]
+		k := len(face.Neighbors)
+		for j, a := range face.Neighbors {
+			if a < face.Neighbors[0] {
+				t.Fatalf("face %d: got neighbors %v, want lowest first", i, face.Neighbors)
+			}
+
+			// Consecutive neighbors are adjacent, and turn counter-clockwise
+			// around the face.
+			b := face.Neighbors[(j+1)%k]
+			adjacent := false
+			for _, n := range g.Faces[a].Neighbors {
+				adjacent = adjacent || n == b
+			}
+			if !adjacent {
+				t.Fatalf("face %d: consecutive neighbors %d and %d not adjacent", i, a, b)
+			}
+			if g.Centers[a].Sub(c).Cross(g.Centers[b].Sub(c)).Dot(c) <= 0 {
+				t.Fatalf("face %d: got neighbors %v, want counter-clockwise", i, face.Neighbors)
+			}
+		}
+	}
+}
+
+func TestGeodesic_Triangles(t *testing.T) {
+	g := Chamfer(Chamfer(Dodecahedron()))
+
+	triangles := g.Triangles()
+	// Euler's formula for a triangulated sphere.
+	if want := 2*len(g.Faces) - 4; len(triangles) != want {
+		t.Errorf("got %d triangles, want %d", len(triangles), want)
+	}
+
+	geometry := g.Geometry()
+	for _, tri := range triangles {
+		a, b, c := g.Centers[tri[0]], g.Centers[tri[1]], g.Centers[tri[2]]
+		if b.Sub(a).Cross(c.Sub(a)).Dot(a) <= 0 {
+			t.Fatalf("triangle %v: want counter-clockwise", tri)
+		}
+
+		// The triangle's circumcenter is one of the corners of each face.
+		corner := Circumcenter(a, b, c)
+		for _, f := range tri {
+			found := false
+			for _, fc := range geometry.Corners[f] {
+				if fc.Sub(corner).Length() < 1e-12 {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("triangle %v: circumcenter not a corner of face %d", tri, f)
+			}
+		}
+	}
+}
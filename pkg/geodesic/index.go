@@ -0,0 +1,225 @@
+package geodesic
+
+import (
+	"math"
+	"sort"
+)
+
+// Index finds the face of a single Geodesic closest to a point, without the
+// chain of coarser spheres Find walks.
+//
+// The sphere is divided into bands of equal area, each split into equal
+// sectors of longitude. Every bucket lists the faces whose cells may reach
+// into it, so a lookup checks only a few candidates and always returns the
+// same face as NaiveFind.
+type Index struct {
+	centers []Vector
+
+	nBands, nSectors int
+
+	// Bucket b holds faces[offsets[b]:offsets[b+1]], in increasing order.
+	offsets []int32
+	faces   []int32
+}
+
+// radiusMargin pads cell radii to absorb floating point error.
+const radiusMargin = 1e-9
+
+// NewIndex builds an Index over the faces of g.
+func NewIndex(g *Geodesic) *Index {
+	nBands := int(math.Sqrt(float64(len(g.Centers)) / 2))
+	if nBands < 1 {
+		nBands = 1
+	}
+
+	idx := &Index{
+		centers:  g.Centers,
+		nBands:   nBands,
+		nSectors: 2 * nBands,
+	}
+	nBuckets := idx.nBands * idx.nSectors
+
+	radii := make([]float64, len(g.Centers))
+	for i := range radii {
+		radii[i] = cellRadius(g, i) + radiusMargin
+	}
+
+	// Count the faces in each bucket, then fill them in.
+	counts := make([]int32, nBuckets+1)
+	for i, c := range g.Centers {
+		idx.forEachBucket(c, radii[i], func(b int) {
+			counts[b+1]++
+		})
+	}
+	for b := 1; b <= nBuckets; b++ {
+		counts[b] += counts[b-1]
+	}
+	idx.offsets = counts
+	idx.faces = make([]int32, counts[nBuckets])
+
+	next := make([]int32, nBuckets)
+	copy(next, counts)
+	for i, c := range g.Centers {
+		idx.forEachBucket(c, radii[i], func(b int) {
+			idx.faces[next[b]] = int32(i)
+			next[b]++
+		})
+	}
+
+	return idx
+}
+
+// Find returns the index of the face closest to v.
+func (idx *Index) Find(v Vector) int {
+	b := idx.bucket(v.Normalize())
+
+	result := 0
+	minDistSq := math.MaxFloat64
+	for _, f := range idx.faces[idx.offsets[b]:idx.offsets[b+1]] {
+		iDistSq := DistSq(idx.centers[f], v)
+		if iDistSq < minDistSq {
+			minDistSq = iDistSq
+			result = int(f)
+		}
+	}
+	return result
+}
+
+func (idx *Index) band(z float64) int {
+	b := int((z + 1) / 2 * float64(idx.nBands))
+	if b < 0 {
+		return 0
+	} else if b >= idx.nBands {
+		return idx.nBands - 1
+	}
+	return b
+}
+
+func (idx *Index) sector(phi float64) int {
+	s := int(math.Floor((phi + math.Pi) / (2 * math.Pi) * float64(idx.nSectors)))
+	s %= idx.nSectors
+	if s < 0 {
+		s += idx.nSectors
+	}
+	return s
+}
+
+// bucket returns the bucket containing the unit vector v.
+func (idx *Index) bucket(v Vector) int {
+	return idx.band(v.Z)*idx.nSectors + idx.sector(math.Atan2(v.Y, v.X))
+}
+
+// forEachBucket calls fn for every bucket intersecting the spherical cap
+// centered on c with angular radius r.
+func (idx *Index) forEachBucket(c Vector, r float64, fn func(b int)) {
+	lat := math.Asin(math.Max(-1, math.Min(1, c.Z)))
+	latLo, latHi := lat-r, lat+r
+
+	// Caps which contain a pole span every longitude.
+	allSectors := latLo <= -math.Pi/2 || latHi >= math.Pi/2
+
+	bLo := idx.band(math.Sin(math.Max(latLo, -math.Pi/2)))
+	bHi := idx.band(math.Sin(math.Min(latHi, math.Pi/2)))
+
+	sLo, nSectors := 0, idx.nSectors
+	if !allSectors {
+		phi := math.Atan2(c.Y, c.X)
+		half := math.Asin(math.Min(1, math.Sin(r)/math.Cos(lat)))
+		sLo = idx.sector(phi - half)
+		sHi := idx.sector(phi + half)
+		nSectors = sHi - sLo + 1
+		if nSectors <= 0 {
+			// The cap crosses the antimeridian.
+			nSectors += idx.nSectors
+		}
+		if nSectors > idx.nSectors || 2*half >= math.Pi {
+			sLo, nSectors = 0, idx.nSectors
+		}
+	}
+
+	for b := bLo; b <= bHi; b++ {
+		for k := 0; k < nSectors; k++ {
+			fn(b*idx.nSectors + (sLo+k)%idx.nSectors)
+		}
+	}
+}
+
+// cellRadius returns an upper bound on the angular distance from face i's
+// center to any point closer to it than to every other center.
+//
+// The cell is contained in the region closer to i than to just its
+// neighbors, which is bounded by the bisectors between i and each neighbor.
+// The region's corners are where pairs of bisectors meet. Returns Pi if the
+// neighbors don't enclose i, as the region is then unbounded.
+func cellRadius(g *Geodesic, i int) float64 {
+	c := g.Centers[i]
+	neighbors := g.Faces[i].Neighbors
+	if !encloses(c, neighbors, g.Centers) {
+		return math.Pi
+	}
+
+	// Points p closer to c than to n satisfy p.(c-n) >= 0.
+	normals := make([]Vector, len(neighbors))
+	for k, n := range neighbors {
+		normals[k] = c.Sub(g.Centers[n])
+	}
+
+	radius := -1.0
+	for a := range normals {
+		for b := a + 1; b < len(normals); b++ {
+			corner := normals[a].Cross(normals[b])
+			if corner.Length2() < 1e-30 {
+				continue
+			}
+			corner = corner.Normalize()
+			if corner.Dot(c) < 0 {
+				corner = corner.Scale(-1)
+			}
+
+			inside := true
+			for _, normal := range normals {
+				if corner.Dot(normal) < -1e-12 {
+					inside = false
+					break
+				}
+			}
+			if inside {
+				radius = math.Max(radius, math.Acos(math.Min(1, corner.Dot(c))))
+			}
+		}
+	}
+
+	if radius < 0 || radius > math.Pi/2 {
+		return math.Pi
+	}
+	return radius
+}
+
+// encloses returns whether the directions from c to its neighbors leave no
+// gap of Pi or more, so the bisectors between them bound a cell.
+func encloses(c Vector, neighbors []int, centers []Vector) bool {
+	if len(neighbors) < 3 {
+		return false
+	}
+
+	// Measure angles in the plane tangent to c.
+	e1 := Vector{X: 1}
+	if math.Abs(c.X) > 0.9 {
+		e1 = Vector{Y: 1}
+	}
+	e1 = e1.Reject(c).Normalize()
+	e2 := c.Cross(e1)
+
+	angles := make([]float64, len(neighbors))
+	for k, n := range neighbors {
+		d := centers[n].Sub(c)
+		angles[k] = math.Atan2(d.Dot(e2), d.Dot(e1))
+	}
+	sort.Float64s(angles)
+
+	maxGap := angles[0] + 2*math.Pi - angles[len(angles)-1]
+	for k := 1; k < len(angles); k++ {
+		maxGap = math.Max(maxGap, angles[k]-angles[k-1])
+	}
+	return maxGap < math.Pi
+}
@@ -0,0 +1,71 @@
+package geodesic
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func randomVector(r *rand.Rand) Vector {
+	return Vector{X: r.NormFloat64(), Y: r.NormFloat64(), Z: r.NormFloat64()}.Normalize()
+}
+
+func TestIndex_Find(t *testing.T) {
+	tcs := []struct {
+		iterations int
+		points     int
+	}{
+		{iterations: 0, points: 100000},
+		{iterations: 1, points: 1000000},
+		{iterations: 3, points: 1000000},
+		{iterations: 5, points: 200000},
+	}
+
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("m = %d", 1<<tc.iterations), func(t *testing.T) {
+			points := tc.points
+			if testing.Short() {
+				points /= 100
+			}
+
+			g := Dodecahedron()
+			for i := 0; i < tc.iterations; i++ {
+				g = Chamfer(g)
+			}
+			idx := NewIndex(g)
+
+			r := rand.New(rand.NewSource(int64(tc.iterations)))
+			for i := 0; i < points; i++ {
+				v := randomVector(r)
+				if i%10 == 0 {
+					// Points exactly between two centers have the most
+					// ambiguity.
+					face := r.Intn(len(g.Faces))
+					neighbors := g.Faces[face].Neighbors
+					n := neighbors[r.Intn(len(neighbors))]
+					v = bisect(g.Centers[face], g.Centers[n])
+				}
+
+				want := NaiveFind(g, v)
+				got := idx.Find(v)
+				if got != want {
+					t.Fatalf("got Find(%v) = %d, want %d", v, got, want)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkIndex_Find(b *testing.B) {
+	g := Dodecahedron()
+	for i := 0; i < 7; i++ {
+		g = Chamfer(g)
+	}
+	idx := NewIndex(g)
+	r := rand.New(rand.NewSource(0))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Find(randomVector(r))
+	}
+}
@@ -1,71 +1,120 @@
 package geodesic
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"io/ioutil"
+	"github.com/willbeason/worldproc/pkg/store"
 	"os"
-	"path/filepath"
 )
 
 const spheresDir = "spheres"
 
-func filename(size int) string {
-	return filepath.Join(spheresDir, fmt.Sprintf("sphere-%02d.json", size))
+func key(size int) string {
+	return fmt.Sprintf("sphere-%02d.bin", size)
 }
 
-func read(size int) *Geodesic {
-	file := filename(size)
+// jsonKey is where older versions cached spheres.
+func jsonKey(size int) string {
+	return fmt.Sprintf("sphere-%02d.json", size)
+}
 
-	bytes, err := ioutil.ReadFile(file)
+// read returns the sphere of the given size from s, or nil if s does not
+// have it.
+func read(s store.Store, size int) (*Geodesic, error) {
+	result, err := readKey(s, key(size))
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		return nil, fmt.Errorf("reading sphere %d: %w", size, err)
+	}
+	if result == nil {
+		return readJSON(s, size)
+	}
+	return result, nil
+}
 
-		}
-		panic(err)
+// readKey returns the sphere at k in s, or nil if s does not have it.
+func readKey(s store.Store, k string) (*Geodesic, error) {
+	data, release, err := store.Map(s, k)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	result := &Geodesic{}
-	err = json.Unmarshal(bytes, result)
+	result, err := Decode(data)
 	if err != nil {
-		panic(err)
+		_ = release()
+		return nil, err
 	}
-	return result
+	return result, release()
 }
 
-func write(size int, sphere *Geodesic) {
-	bytes, err := json.Marshal(sphere)
+// readJSON imports a sphere from the old JSON cache, converting it to the
+// binary format.
+func readJSON(s store.Store, size int) (*Geodesic, error) {
+	data, err := s.Read(jsonKey(size))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	err = ioutil.WriteFile(filename(size), bytes, os.ModePerm)
+
+	result, err := DecodeJSON(data)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("reading sphere %d: %w", size, err)
 	}
+	return result, write(s, size, result)
 }
 
-// New returns the generated sequence of geodesic spheres.
-//
-// fromScratch is whether to generate these manually.
-// If false, attempts to read from disk.
-func New(size int, fromScratch bool) []*Geodesic {
+func write(s store.Store, size int, sphere *Geodesic) error {
+	return writeKey(s, key(size), sphere)
+}
+
+func writeKey(s store.Store, k string, sphere *Geodesic) error {
+	buf := &bytes.Buffer{}
+	err := Encode(buf, sphere)
+	if err != nil {
+		return err
+	}
+	return s.Write(k, buf.Bytes())
+}
+
+// LoadGenerated returns the sphere gen generates, reading it from s if
+// present and writing it to s otherwise.
+func LoadGenerated(s store.Store, gen Generator) (*Geodesic, error) {
+	k := gen.Name() + ".bin"
+	result, err := readKey(s, k)
+	if err != nil {
+		return nil, fmt.Errorf("reading sphere %s: %w", gen.Name(), err)
+	}
+	if result != nil {
+		return result, nil
+	}
+
+	result, err = gen.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("generating sphere %s: %w", gen.Name(), err)
+	}
+	return result, writeKey(s, k, result)
+}
+
+// Load returns the sequence of geodesic spheres up to size, reading them
+// from s if present and writing any it has to generate.
+func Load(s store.Store, size int) ([]*Geodesic, error) {
 	result := make([]*Geodesic, size+1)
 
 	for i := 0; i < size+1; i++ {
-		var sphereI *Geodesic
-		if !fromScratch {
-			sphereI = read(i)
+		sphereI, err := read(s, i)
+		if err != nil {
+			return nil, err
 		}
 		if sphereI == nil {
 			fmt.Println("Generating Sphere", i)
-			if i == 0 {
-				sphereI = Dodecahedron()
-			} else {
-				sphereI = Chamfer(result[i-1])
-			}
-			if !fromScratch {
-				write(i, sphereI)
+			sphereI = generate(result, i)
+			err = write(s, i, sphereI)
+			if err != nil {
+				return nil, err
 			}
 		} else {
 			fmt.Println("Read Sphere", i)
@@ -73,5 +122,33 @@ func New(size int, fromScratch bool) []*Geodesic {
 		result[i] = sphereI
 	}
 
+	return result, nil
+}
+
+func generate(previous []*Geodesic, i int) *Geodesic {
+	if i == 0 {
+		return Dodecahedron()
+	}
+	return Chamfer(previous[i-1])
+}
+
+// New returns the generated sequence of geodesic spheres.
+//
+// fromScratch is whether to generate these manually.
+// If false, attempts to read from the spheres directory.
+func New(size int, fromScratch bool) []*Geodesic {
+	if !fromScratch {
+		result, err := Load(store.Dir(spheresDir), size)
+		if err != nil {
+			panic(err)
+		}
+		return result
+	}
+
+	result := make([]*Geodesic, size+1)
+	for i := 0; i < size+1; i++ {
+		fmt.Println("Generating Sphere", i)
+		result[i] = generate(result, i)
+	}
 	return result
 }
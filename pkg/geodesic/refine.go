@@ -0,0 +1,335 @@
+package geodesic
+
+import "sort"
+
+// Refined is a geodesic sphere divided more finely in some regions than
+// others.
+//
+// It is an ordinary Geodesic, so its faces may be found with an Index and
+// their neighbors are ordered as usual, including where regions of different
+// resolution meet.
+type Refined struct {
+	*Geodesic
+
+	// Levels is how many times the region around each face was divided, in
+	// the same order as Faces: the level of the finest triangle the face is a
+	// corner of. Faces of the sphere Refine started from keep their indices.
+	Levels []int
+}
+
+// Refine divides the triangles of mutually adjacent faces of base as Chamfer
+// does, but only where depth asks for more detail. A triangle at level L is
+// divided if depth returns more than L at any of its corners or its center.
+//
+// Neighboring triangles differ by at most one level. Triangles next to finer
+// ones are split in two, or divided if they border finer triangles on more
+// than one side, so there are no gaps between regions. Where regions meet,
+// edges are then flipped as needed so every face is exactly the part of the
+// sphere closer to its center than to any other. Regions where depth is
+// constant are identical to base chamfered that many times, apart from the
+// order of their faces.
+func Refine(base *Geodesic, depth func(v Vector) int) *Refined {
+	r := &refinement{
+		centers: append([]Vector{}, base.Centers...),
+		mids:    make(map[Edge]int),
+		divides: make([]Edge, len(base.Centers)),
+		edges:   make(map[Edge][2]int),
+	}
+
+	for _, tri := range base.Triangles() {
+		r.add(tri, 0, -1)
+	}
+
+	// Divide triangles until every one is as fine as depth asks. Dividing a
+	// triangle appends its children, so they are checked in turn.
+	for t := 0; t < len(r.triangles); t++ {
+		if r.triangles[t].leaf && r.wanted(t, depth) {
+			r.divide(t)
+		}
+	}
+
+	// Divide triangles with more than one finer neighbor until there are
+	// none, as splitting those would make thin triangles.
+	for changed := true; changed; {
+		changed = false
+		for t := range r.triangles {
+			if r.triangles[t].leaf && r.hanging(t) > 1 {
+				r.divide(t)
+				changed = true
+			}
+		}
+	}
+
+	levels := make([]int, len(r.centers))
+	var triangles [][3]int
+	for _, tri := range r.triangles {
+		if !tri.leaf {
+			continue
+		}
+		v := tri.v
+		for _, f := range v {
+			if tri.level > levels[f] {
+				levels[f] = tri.level
+			}
+		}
+
+		split := -1
+		for k := 0; k < 3; k++ {
+			if _, found := r.mids[edgeOf(v[k], v[(k+1)%3])]; found {
+				split = k
+			}
+		}
+		if split == -1 {
+			triangles = append(triangles, v)
+			continue
+		}
+
+		// Split the triangle in two through the midpoint of its edge with
+		// the finer neighbor.
+		a, b, c := v[split], v[(split+1)%3], v[(split+2)%3]
+		m := r.mids[edgeOf(a, b)]
+		triangles = append(triangles, [3]int{a, m, c}, [3]int{m, b, c})
+	}
+	delaunay(r.centers, triangles)
+
+	g := &Geodesic{
+		Centers: r.centers,
+		Faces:   make([]Node, len(r.centers)),
+	}
+	for _, tri := range triangles {
+		g.addTriangle(tri[0], tri[1], tri[2])
+	}
+	g.orderNeighbors()
+
+	return &Refined{Geodesic: g, Levels: levels}
+}
+
+// refinement is the hierarchy of triangles Refine divides.
+type refinement struct {
+	centers []Vector
+
+	triangles []refinedTriangle
+	// mids is the face at the midpoint of each divided edge.
+	mids map[Edge]int
+	// divides is the edge each face is the midpoint of, in the same order as
+	// centers. Faces of the original sphere aren't the midpoint of any edge.
+	divides []Edge
+	// edges lists the triangles on either side of each edge, or -1 if there
+	// is not one yet.
+	edges map[Edge][2]int
+}
+
+type refinedTriangle struct {
+	// v lists the corners counter-clockwise.
+	v      [3]int
+	level  int
+	parent int
+	leaf   bool
+}
+
+// edgeOf returns the Edge between a and b, with the lower index first.
+func edgeOf(a, b int) Edge {
+	if b < a {
+		a, b = b, a
+	}
+	return Edge{L: a, R: b}
+}
+
+func (r *refinement) add(v [3]int, level, parent int) {
+	t := len(r.triangles)
+	r.triangles = append(r.triangles, refinedTriangle{v: v, level: level, parent: parent, leaf: true})
+	for k := 0; k < 3; k++ {
+		e := edgeOf(v[k], v[(k+1)%3])
+		sides, found := r.edges[e]
+		if !found {
+			sides = [2]int{-1, -1}
+		}
+		if sides[0] == -1 {
+			sides[0] = t
+		} else {
+			sides[1] = t
+		}
+		r.edges[e] = sides
+	}
+}
+
+// wanted returns whether depth asks for triangle t to be divided.
+func (r *refinement) wanted(t int, depth func(v Vector) int) bool {
+	tri := r.triangles[t]
+	center := Vector{}
+	for _, v := range tri.v {
+		if depth(r.centers[v]) > tri.level {
+			return true
+		}
+		center = center.Add(r.centers[v])
+	}
+	return depth(center.Normalize()) > tri.level
+}
+
+// hanging returns how many edges of triangle t have been divided by the
+// triangles on their other side.
+func (r *refinement) hanging(t int) int {
+	v := r.triangles[t].v
+	result := 0
+	for k := 0; k < 3; k++ {
+		if _, found := r.mids[edgeOf(v[k], v[(k+1)%3])]; found {
+			result++
+		}
+	}
+	return result
+}
+
+// divide replaces triangle t with four triangles between its corners and the
+// midpoints of its edges, first dividing any coarser neighbors so the levels
+// of neighboring triangles never differ by more than one.
+func (r *refinement) divide(t int) {
+	tri := r.triangles[t]
+	if !tri.leaf {
+		return
+	}
+
+	if tri.level > 0 {
+		for k := 0; k < 3; k++ {
+			e := edgeOf(tri.v[k], tri.v[(k+1)%3])
+			if r.edges[e][1] != -1 {
+				continue
+			}
+			// Nothing is on the other side of this edge at this level, so it
+			// is half of an edge of t's parent, whose midpoint is the later of
+			// its ends. The triangle across that edge is coarser than t.
+			parentEdge := r.parentEdge(e)
+			sides := r.edges[parentEdge]
+			neighbor := sides[0]
+			if neighbor == tri.parent {
+				neighbor = sides[1]
+			}
+			r.divide(neighbor)
+		}
+	}
+
+	var mids [3]int
+	for k := 0; k < 3; k++ {
+		mids[k] = r.midpoint(tri.v[k], tri.v[(k+1)%3])
+	}
+	a, b, c := tri.v[0], tri.v[1], tri.v[2]
+	ab, bc, ca := mids[0], mids[1], mids[2]
+
+	r.triangles[t].leaf = false
+	r.add([3]int{a, ab, ca}, tri.level+1, t)
+	r.add([3]int{ab, b, bc}, tri.level+1, t)
+	r.add([3]int{ca, bc, c}, tri.level+1, t)
+	r.add([3]int{ab, bc, ca}, tri.level+1, t)
+}
+
+// parentEdge returns the edge e is half of.
+func (r *refinement) parentEdge(e Edge) Edge {
+	// The midpoint is always created after both ends of the edge it divides.
+	return r.divides[e.R]
+}
+
+// midpoint returns the face at the midpoint of a and b, creating it if there
+// is not one yet.
+func (r *refinement) midpoint(a, b int) int {
+	e := edgeOf(a, b)
+	if m, found := r.mids[e]; found {
+		return m
+	}
+	m := len(r.centers)
+	r.centers = append(r.centers, bisect(r.centers[a], r.centers[b]))
+	r.divides = append(r.divides, e)
+	r.mids[e] = m
+	return m
+}
+
+// delaunay flips edges between triangles until no triangle's circumcircle
+// contains a corner of its neighbors, so each face is exactly the region
+// closer to its center than any other. Triangles with the same circumcircle
+// are left alone.
+func delaunay(centers []Vector, triangles [][3]int) {
+	// The triangles on either side of each edge.
+	edges := make(map[Edge][2]int, 3*len(triangles)/2)
+	set := func(e Edge, old, t int) {
+		sides, found := edges[e]
+		if !found {
+			sides = [2]int{-1, -1}
+		}
+		if sides[0] == old {
+			sides[0] = t
+		} else {
+			sides[1] = t
+		}
+		edges[e] = sides
+	}
+	for t, tri := range triangles {
+		for k := 0; k < 3; k++ {
+			set(edgeOf(tri[k], tri[(k+1)%3]), -1, t)
+		}
+	}
+
+	queue := make([]Edge, 0, len(edges))
+	for e := range edges {
+		queue = append(queue, e)
+	}
+	// Flip in a fixed order so the result doesn't depend on map iteration.
+	sort.Slice(queue, func(i, j int) bool {
+		if queue[i].L != queue[j].L {
+			return queue[i].L < queue[j].L
+		}
+		return queue[i].R < queue[j].R
+	})
+
+	for len(queue) > 0 {
+		e := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		sides, found := edges[e]
+		if !found {
+			// Already flipped away.
+			continue
+		}
+		t1, t2 := sides[0], sides[1]
+
+		// Rotate t1 to (a, b, c) and t2 to (b, a, d).
+		a, b, c := rotateTo(triangles[t1], e)
+		d := opposite(triangles[t2], a, b)
+
+		normal := centers[b].Sub(centers[a]).Cross(centers[c].Sub(centers[a]))
+		if normal.Dot(centers[d])-normal.Dot(centers[a]) <= 1e-12*normal.Length() {
+			continue
+		}
+		if _, found := edges[edgeOf(c, d)]; found {
+			continue
+		}
+
+		triangles[t1] = [3]int{a, d, c}
+		triangles[t2] = [3]int{d, b, c}
+		delete(edges, e)
+		edges[edgeOf(c, d)] = [2]int{t1, t2}
+		set(edgeOf(a, d), t2, t1)
+		set(edgeOf(b, c), t1, t2)
+
+		queue = append(queue, edgeOf(a, d), edgeOf(d, b), edgeOf(b, c), edgeOf(c, a))
+	}
+}
+
+// rotateTo returns the corners of tri counter-clockwise, starting with the
+// ends of e in the order tri has them.
+func rotateTo(tri [3]int, e Edge) (int, int, int) {
+	for k := 0; k < 3; k++ {
+		a, b := tri[k], tri[(k+1)%3]
+		if edgeOf(a, b) == e {
+			return a, b, tri[(k+2)%3]
+		}
+	}
+	panic("geodesic: triangle does not have edge")
+}
+
+// opposite returns the corner of tri which is neither a nor b.
+func opposite(tri [3]int, a, b int) int {
+	for _, v := range tri {
+		if v != a && v != b {
+			return v
+		}
+	}
+	panic("geodesic: degenerate triangle")
+}
@@ -0,0 +1,115 @@
+package geodesic
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRefine_Uniform(t *testing.T) {
+	base := Chamfer(Dodecahedron())
+	got := Refine(base, func(Vector) int { return 2 })
+	want := Chamfer(Chamfer(base))
+
+	if len(got.Faces) != len(want.Faces) {
+		t.Fatalf("got %d faces, want %d", len(got.Faces), len(want.Faces))
+	}
+
+	// The same faces, apart from their order.
+	byPosition := func(centers []Vector) []Vector {
+		result := append([]Vector{}, centers...)
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].X != result[j].X {
+				return result[i].X < result[j].X
+			}
+			if result[i].Y != result[j].Y {
+				return result[i].Y < result[j].Y
+			}
+			return result[i].Z < result[j].Z
+		})
+		return result
+	}
+	if diff := cmp.Diff(byPosition(want.Centers), byPosition(got.Centers), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Error(diff)
+	}
+
+	for i := range base.Faces {
+		if got.Centers[i] != base.Centers[i] {
+			t.Errorf("face %d: got %v, want %v", i, got.Centers[i], base.Centers[i])
+		}
+	}
+	for i, level := range got.Levels {
+		if level != 2 {
+			t.Errorf("face %d: got level %d, want 2", i, level)
+		}
+	}
+}
+
+func TestRefine_Region(t *testing.T) {
+	north := Vector{Z: 1}
+	depth := func(v Vector) int {
+		if Arc(north, v) < 0.5 {
+			return 4
+		}
+		return 0
+	}
+
+	base := Chamfer(Dodecahedron())
+	got := Refine(base, depth)
+
+	if len(got.Faces) >= len(Chamfer(Chamfer(Chamfer(Chamfer(base)))).Faces)/4 {
+		t.Errorf("got %d faces, want far fewer than refining everywhere", len(got.Faces))
+	}
+	for i, c := range got.Centers {
+		if got.Levels[i] > 4 {
+			t.Errorf("face %d: got level %d, want at most 4", i, got.Levels[i])
+		}
+		if Arc(north, c) > 1.5 && got.Levels[i] != 0 {
+			t.Errorf("face %d: got level %d far from the region, want 0", i, got.Levels[i])
+		}
+	}
+
+	testNeighbors(t, got.Geodesic)
+	if want := 2*len(got.Faces) - 4; len(got.Triangles()) != want {
+		t.Errorf("got %d triangles, want %d", len(got.Triangles()), want)
+	}
+
+	geometry := got.Geometry()
+	total := 0.0
+	for i, a := range geometry.Areas {
+		if a <= 0 {
+			t.Errorf("face %d: got area %g, want positive", i, a)
+		}
+		total += a
+	}
+	if diff := cmp.Diff(4*math.Pi, total, cmpopts.EquateApprox(1e-9, 0)); diff != "" {
+		t.Error(diff)
+	}
+
+	// Faces inside the region are as small as those of base chamfered four
+	// times.
+	want := 4 * math.Pi / float64(len(Chamfer(Chamfer(Chamfer(Chamfer(base)))).Faces))
+	for i, c := range got.Centers {
+		if Arc(north, c) < 0.4 {
+			if got.Levels[i] != 4 {
+				t.Errorf("face %d: got level %d inside the region, want 4", i, got.Levels[i])
+			}
+			if geometry.Areas[i] > 2*want {
+				t.Errorf("face %d: got area %g inside the region, want about %g", i, geometry.Areas[i], want)
+			}
+		}
+	}
+
+	// Finding faces works across the boundaries between levels.
+	idx := NewIndex(got.Geodesic)
+	r := rand.New(rand.NewSource(12))
+	for i := 0; i < 20000; i++ {
+		v := randomVector(r)
+		if got, want := idx.Find(v), NaiveFind(got.Geodesic, v); got != want {
+			t.Fatalf("got Find(%v) = %d, want %d", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package geodesic
+
+// Chamfer keeps every face of the sphere it refines at the same index, and
+// adds one face for each edge. Each added face lies halfway between the two
+// faces it separated, which are the only neighbors it has from the coarser
+// sphere.
+
+// parents returns the two faces of the coarser sphere that face e of fine
+// was created between. e must not be a face of the coarser sphere.
+func parents(fine *Geodesic, nCoarse, e int) (int, int) {
+	p0, p1 := -1, -1
+	for _, n := range fine.Faces[e].Neighbors {
+		if n >= nCoarse {
+			continue
+		}
+		if p0 == -1 {
+			p0 = n
+		} else {
+			p1 = n
+		}
+	}
+	return p0, p1
+}
+
+// restrictWeights returns how much each face of fine contributes to each of
+// its parents in coarse: all of its area for faces in both, and half of its
+// area to each parent for faces added by Chamfer.
+func restrictWeights(fine, coarse *Geodesic, fn func(parent, child int, w float64)) {
+	nCoarse := len(coarse.Faces)
+	areas := fine.Geometry().Areas
+	for i := range fine.Faces {
+		if i < nCoarse {
+			fn(i, i, areas[i])
+			continue
+		}
+		p0, p1 := parents(fine, nCoarse, i)
+		fn(p0, i, 0.5*areas[i])
+		fn(p1, i, 0.5*areas[i])
+	}
+}
+
+// Restrict averages field, defined on the faces of fine, into the faces of
+// coarse. fine must be Chamfer(coarse).
+//
+// Each face of coarse takes the average of the faces of fine which overlap
+// it, weighted by area.
+func Restrict(field []float64, fine, coarse *Geodesic) []float64 {
+	result := make([]float64, len(coarse.Faces))
+	totals := make([]float64, len(coarse.Faces))
+	restrictWeights(fine, coarse, func(parent, child int, w float64) {
+		result[parent] += w * field[child]
+		totals[parent] += w
+	})
+	for i := range result {
+		result[i] /= totals[i]
+	}
+	return result
+}
+
+// RestrictVectors is Restrict for vector fields.
+func RestrictVectors(field []Vector, fine, coarse *Geodesic) []Vector {
+	result := make([]Vector, len(coarse.Faces))
+	totals := make([]float64, len(coarse.Faces))
+	restrictWeights(fine, coarse, func(parent, child int, w float64) {
+		result[parent] = result[parent].Add(field[child].Scale(w))
+		totals[parent] += w
+	})
+	for i := range result {
+		result[i] = result[i].Scale(1.0 / totals[i])
+	}
+	return result
+}
+
+// RestrictCategories is Restrict for categorical fields, which can't be
+// averaged. Faces of coarse keep the category they have in fine.
+func RestrictCategories(field []int, coarse *Geodesic) []int {
+	result := make([]int, len(coarse.Faces))
+	copy(result, field)
+	return result
+}
+
+// Prolong interpolates field, defined on the faces of coarse, onto the faces
+// of fine. fine must be Chamfer(coarse).
+//
+// Faces of fine which were in coarse keep their value, and the rest take the
+// average of their parents.
+func Prolong(field []float64, coarse, fine *Geodesic) []float64 {
+	nCoarse := len(coarse.Faces)
+	result := make([]float64, len(fine.Faces))
+	copy(result, field)
+	for i := nCoarse; i < len(result); i++ {
+		p0, p1 := parents(fine, nCoarse, i)
+		result[i] = (field[p0] + field[p1]) / 2
+	}
+	return result
+}
+
+// ProlongVectors is Prolong for vector fields.
+func ProlongVectors(field []Vector, coarse, fine *Geodesic) []Vector {
+	nCoarse := len(coarse.Faces)
+	result := make([]Vector, len(fine.Faces))
+	copy(result, field)
+	for i := nCoarse; i < len(result); i++ {
+		p0, p1 := parents(fine, nCoarse, i)
+		result[i] = field[p0].Add(field[p1]).Scale(0.5)
+	}
+	return result
+}
+
+// ProlongCategories is Prolong for categorical fields. New faces take the
+// category of their lower-indexed parent.
+func ProlongCategories(field []int, coarse, fine *Geodesic) []int {
+	nCoarse := len(coarse.Faces)
+	result := make([]int, len(fine.Faces))
+	copy(result, field)
+	for i := nCoarse; i < len(result); i++ {
+		p0, p1 := parents(fine, nCoarse, i)
+		if p1 < p0 {
+			p0 = p1
+		}
+		result[i] = field[p0]
+	}
+	return result
+}
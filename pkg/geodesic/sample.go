@@ -0,0 +1,135 @@
+package geodesic
+
+import "math"
+
+// Barycentric is a point on the sphere expressed as a weighted combination of
+// the three face centers around it.
+type Barycentric struct {
+	Faces   [3]int
+	Weights [3]float64
+}
+
+// Scalar interpolates the per-face field at the point.
+func (b Barycentric) Scalar(field []float64) float64 {
+	return b.Weights[0]*field[b.Faces[0]] +
+		b.Weights[1]*field[b.Faces[1]] +
+		b.Weights[2]*field[b.Faces[2]]
+}
+
+// Vector interpolates the per-face field at the point.
+func (b Barycentric) Vector(field []Vector) Vector {
+	return field[b.Faces[0]].Scale(b.Weights[0]).
+		Add(field[b.Faces[1]].Scale(b.Weights[1])).
+		Add(field[b.Faces[2]].Scale(b.Weights[2]))
+}
+
+// Sampler interpolates per-face fields of a Geodesic at arbitrary points.
+//
+// Faces are treated as the corners of the triangles formed by each face and
+// two adjacent neighbors. Points are interpolated linearly within the
+// triangle containing them, so fields vary smoothly across face boundaries.
+type Sampler struct {
+	*Index
+	g *Geodesic
+}
+
+func NewSampler(g *Geodesic) *Sampler {
+	return &Sampler{Index: NewIndex(g), g: g}
+}
+
+// Scalar returns the interpolated value of field at v.
+func (s *Sampler) Scalar(field []float64, v Vector) float64 {
+	return s.Locate(v).Scalar(field)
+}
+
+// Vector returns the interpolated value of field at v.
+func (s *Sampler) Vector(field []Vector, v Vector) Vector {
+	return s.Locate(v).Vector(field)
+}
+
+// Locate returns the triangle of face centers containing v.
+func (s *Sampler) Locate(v Vector) Barycentric {
+	return s.LocateFrom(s.Find(v), v)
+}
+
+// LocateFrom is Locate for when the face closest to v is already known.
+func (s *Sampler) LocateFrom(nearest int, v Vector) Barycentric {
+	best, bestMin := Barycentric{}, math.Inf(-1)
+
+	// The containing triangle almost always includes the closest face, but
+	// may instead be around one of its neighbors.
+	if b, minW := s.around(nearest, v); minW >= 0 {
+		return b
+	} else if minW > bestMin {
+		best, bestMin = b, minW
+	}
+	for _, n := range s.g.Faces[nearest].Neighbors {
+		if b, minW := s.around(n, v); minW >= 0 {
+			return b
+		} else if minW > bestMin {
+			best, bestMin = b, minW
+		}
+	}
+
+	if math.IsInf(bestMin, -1) {
+		// There are no triangles, so the closest face is all we have.
+		return Barycentric{Faces: [3]int{nearest, nearest, nearest}, Weights: [3]float64{1, 0, 0}}
+	}
+
+	// Numerical error left v just outside every triangle, so clamp to the
+	// closest one.
+	sum := 0.0
+	for k, w := range best.Weights {
+		best.Weights[k] = math.Max(0, w)
+		sum += best.Weights[k]
+	}
+	for k := range best.Weights {
+		best.Weights[k] /= sum
+	}
+	return best
+}
+
+// around returns the triangle with a corner at face which best contains v,
+// and the smallest of its weights. The smallest weight is negative if no
+// triangle contains v.
+func (s *Sampler) around(face int, v Vector) (Barycentric, float64) {
+	best, bestMin := Barycentric{}, math.Inf(-1)
+
+	// Neighbors are ordered around face, so each consecutive pair forms a
+	// triangle with it.
+	neighbors := s.g.Faces[face].Neighbors
+	for a, na := range neighbors {
+		nb := neighbors[(a+1)%len(neighbors)]
+
+		weights, ok := barycentric(s.g.Centers[face], s.g.Centers[na], s.g.Centers[nb], v)
+		if !ok {
+			continue
+		}
+		minW := math.Min(weights[0], math.Min(weights[1], weights[2]))
+		if minW > bestMin {
+			best = Barycentric{Faces: [3]int{face, na, nb}, Weights: weights}
+			bestMin = minW
+		}
+	}
+
+	return best, bestMin
+}
+
+// barycentric returns the weights of the corners of triangle p0, p1, p2 which
+// sum to one and combine to the point where the ray through v meets the
+// triangle. Returns false if the triangle is degenerate or faces away from v.
+func barycentric(p0, p1, p2, v Vector) ([3]float64, bool) {
+	w0 := v.Dot(p1.Cross(p2))
+	w1 := p0.Dot(v.Cross(p2))
+	w2 := p0.Dot(p1.Cross(v))
+
+	sum := w0 + w1 + w2
+	if math.Abs(sum) < 1e-15 {
+		return [3]float64{}, false
+	}
+	if p0.Dot(p1.Cross(p2))*sum < 0 {
+		// v is on the opposite side of the sphere.
+		return [3]float64{}, false
+	}
+	return [3]float64{w0 / sum, w1 / sum, w2 / sum}, true
+}
@@ -0,0 +1,49 @@
+package geodesic
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSampler_Locate(t *testing.T) {
+	g := Chamfer(Chamfer(Dodecahedron()))
+	s := NewSampler(g)
+
+	zs := make([]float64, len(g.Centers))
+	for i, c := range g.Centers {
+		zs[i] = c.Z
+	}
+
+	// Centers take their own value.
+	for i, c := range g.Centers {
+		if diff := cmp.Diff(c.Z, s.Scalar(zs, c), cmpopts.EquateApprox(0.0, 1e-9)); diff != "" {
+			t.Fatalf("face %d: %s", i, diff)
+		}
+	}
+
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100000; i++ {
+		v := randomVector(r)
+		b := s.Locate(v)
+
+		sum := 0.0
+		for _, w := range b.Weights {
+			if w < 0 {
+				t.Fatalf("got Locate(%v) = %v, want non-negative weights", v, b)
+			}
+			sum += w
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Fatalf("got Locate(%v) weights summing to %f, want 1", v, sum)
+		}
+
+		// Interpolating a smooth field is much closer than the distance
+		// between centers.
+		if got := s.Scalar(zs, v); math.Abs(got-v.Z) > 0.02 {
+			t.Fatalf("got Scalar(z, %v) = %f, want %f", v, got, v.Z)
+		}
+	}
+}
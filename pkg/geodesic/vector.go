@@ -75,3 +75,11 @@ func (a Angle) Vector() Vector {
 		Z: math.Sin(a.Theta),
 	}
 }
+
+// Angle returns the direction of v.
+func (v Vector) Angle() Angle {
+	return Angle{
+		Theta: math.Asin(math.Max(-1, math.Min(1, v.Z/v.Length()))),
+		Phi:   math.Atan2(v.Y, v.X),
+	}
+}
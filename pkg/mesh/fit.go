@@ -0,0 +1,190 @@
+package mesh
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+)
+
+// terms is the number of terms of a cubic in two variables.
+const terms = 10
+
+// fit is a cubic fit by least squares to the values of the faces within two
+// steps of a face. It is in the stereographic coordinates of the plane
+// tangent to the face's center, which are smooth everywhere but the far side
+// of the sphere.
+type fit struct {
+	face   int
+	center geodesic.Vector
+	e1, e2 geodesic.Vector
+	// scale is the size of the face, which coordinates are divided by so the
+	// fit is as well conditioned on every sphere.
+	scale float64
+
+	faces []int
+	// coefficients holds the contribution of the value of each face to each
+	// term of the cubic.
+	coefficients [terms][]float64
+}
+
+func (m *Mesh) newFit(i int) *fit {
+	c := m.g.Centers[i]
+	e1, e2 := basis(c)
+	f := &fit{
+		face:   i,
+		center: c,
+		e1:     e1,
+		e2:     e2,
+		scale:  math.Sqrt(m.geometry.Areas[i]),
+		faces:  m.ring(i, 2),
+	}
+
+	rows := make([][terms]float64, len(f.faces))
+	normal := make([][]float64, terms)
+	for a := range normal {
+		normal[a] = make([]float64, terms)
+	}
+	for j, face := range f.faces {
+		x, y := f.coordinates(m.g.Centers[face])
+		rows[j] = monomials(x, y)
+		for a := range rows[j] {
+			for b := range rows[j] {
+				normal[a][b] += rows[j][a] * rows[j][b]
+			}
+		}
+	}
+
+	// Column j of the right side is row j of the design matrix, so solving
+	// gives each face's contribution to each coefficient.
+	rhs := make([][]float64, terms)
+	for a := range rhs {
+		rhs[a] = make([]float64, len(f.faces))
+		for j := range f.faces {
+			rhs[a][j] = rows[j][a]
+		}
+	}
+	solve(normal, rhs)
+	copy(f.coefficients[:], rhs)
+	return f
+}
+
+// ring returns face i and the faces within steps of it.
+func (m *Mesh) ring(i, steps int) []int {
+	result := []int{i}
+	seen := map[int]bool{i: true}
+	start := 0
+	for step := 0; step < steps; step++ {
+		end := len(result)
+		for _, face := range result[start:end] {
+			for _, n := range m.g.Faces[face].Neighbors {
+				if !seen[n] {
+					seen[n] = true
+					result = append(result, n)
+				}
+			}
+		}
+		start = end
+	}
+	return result
+}
+
+// coordinates returns the stereographic coordinates of p.
+func (f *fit) coordinates(p geodesic.Vector) (float64, float64) {
+	d := f.scale * (1 + p.Dot(f.center)) / 2
+	return p.Dot(f.e1) / d, p.Dot(f.e2) / d
+}
+
+// at returns the contribution of the value of each face to the fit at p, and
+// to its derivative along the tangent direction t.
+func (f *fit) at(p, t geodesic.Vector) ([]float64, []float64) {
+	x, y := f.coordinates(p)
+	powers := monomials(x, y)
+	dx, dy := derivatives(x, y)
+
+	// By the chain rule, the derivative along t is that along each coordinate
+	// times the derivative of the coordinate along t.
+	d := 1 + p.Dot(f.center)
+	xt := 2 * (t.Dot(f.e1) - p.Dot(f.e1)*t.Dot(f.center)/d) / (f.scale * d)
+	yt := 2 * (t.Dot(f.e2) - p.Dot(f.e2)*t.Dot(f.center)/d) / (f.scale * d)
+
+	values := make([]float64, len(f.faces))
+	slopes := make([]float64, len(f.faces))
+	for a, coefficients := range f.coefficients {
+		slope := dx[a]*xt + dy[a]*yt
+		for j, c := range coefficients {
+			values[j] += powers[a] * c
+			slopes[j] += slope * c
+		}
+	}
+	return values, slopes
+}
+
+// gradient returns the contribution of the value of each face to the
+// gradient of the fit at the center.
+func (f *fit) gradient() vectorStencil {
+	// The coordinates are those of the tangent plane near the center, so the
+	// gradient there is the linear terms.
+	result := vectorStencil{faces: f.faces, weights: make([]geodesic.Vector, len(f.faces))}
+	for j := range f.faces {
+		result.weights[j] = f.e1.Scale(f.coefficients[1][j] / f.scale).
+			Add(f.e2.Scale(f.coefficients[2][j] / f.scale))
+	}
+	return result
+}
+
+// monomials returns the terms of a cubic at x, y.
+func monomials(x, y float64) [terms]float64 {
+	return [terms]float64{1, x, y, x * x, x * y, y * y, x * x * x, x * x * y, x * y * y, y * y * y}
+}
+
+// derivatives returns the derivatives of the terms of a cubic along x and y.
+func derivatives(x, y float64) ([terms]float64, [terms]float64) {
+	return [terms]float64{0, 1, 0, 2 * x, y, 0, 3 * x * x, 2 * x * y, y * y, 0},
+		[terms]float64{0, 0, 1, 0, x, 2 * y, 0, x * x, 2 * x * y, 3 * y * y}
+}
+
+// basis returns two orthogonal unit vectors tangent to the sphere at c, which
+// with c form a right-handed coordinate system.
+func basis(c geodesic.Vector) (geodesic.Vector, geodesic.Vector) {
+	e1 := geodesic.Vector{X: 1}
+	if math.Abs(c.X) > 0.9 {
+		e1 = geodesic.Vector{Y: 1}
+	}
+	e1 = e1.Reject(c).Normalize()
+	return e1, c.Cross(e1)
+}
+
+// solve replaces rhs with the solution x of a x = rhs, destroying a. Uses
+// Gaussian elimination with partial pivoting.
+func solve(a, rhs [][]float64) {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		rhs[col], rhs[pivot] = rhs[pivot], rhs[col]
+
+		for row := col + 1; row < n; row++ {
+			scale := a[row][col] / a[col][col]
+			for j := col; j < n; j++ {
+				a[row][j] -= scale * a[col][j]
+			}
+			for j := range rhs[row] {
+				rhs[row][j] -= scale * rhs[col][j]
+			}
+		}
+	}
+
+	for row := n - 1; row >= 0; row-- {
+		for j := range rhs[row] {
+			sum := rhs[row][j]
+			for k := row + 1; k < n; k++ {
+				sum -= a[row][k] * rhs[k][j]
+			}
+			rhs[row][j] = sum / a[row][row]
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Package mesh implements differential operators on the faces of a geodesic
+// sphere.
+//
+// Fields hold one value per face, located at the face's center, and vector
+// fields are tangent to the sphere. Everything is on the unit sphere; scale
+// results by the planet's radius as needed.
+//
+// Every face fits a cubic to the values of the faces within two steps of it by
+// least squares. Gradient is the slope of the fit at the face's center.
+// Divergence, Curl and Laplacian are finite-volume: each integrates a flux
+// around the edges of the face by Green's theorem and divides by its area,
+// giving the mean over the face. Neighboring faces agree on the flux across
+// the edge between them, taking the mean of their fits along it, so the
+// operators conserve their field. The mean over a face is, to second order,
+// its value at the face's centroid, which on a chamfered sphere isn't its
+// center, so each mean is moved to the center along its gradient. All of the
+// operators converge at second order.
+package mesh
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+)
+
+// Mesh computes differential operators on the faces of a Geodesic.
+type Mesh struct {
+	g        *geodesic.Geodesic
+	geometry *geodesic.Geometry
+
+	// gradients, divergences, curls and laplacians hold how each operator at
+	// each face depends on the faces near it.
+	gradients   []vectorStencil
+	divergences []vectorStencil
+	curls       []vectorStencil
+	laplacians  []stencil
+	// offsets holds the centroid of each face less its center, tangent to the
+	// sphere.
+	offsets []geodesic.Vector
+}
+
+// stencil holds how a value at a face depends on the values of faces near it.
+type stencil struct {
+	faces   []int
+	weights []float64
+}
+
+// apply returns the sum of the weights times f less base. The weights of the
+// derivatives of scalar fields sum to zero, so base changes nothing but
+// rounding, and taking the value at the face makes constants exactly flat.
+func (s *stencil) apply(f []float64, base float64) float64 {
+	sum := 0.0
+	for j, face := range s.faces {
+		sum += s.weights[j] * (f[face] - base)
+	}
+	return sum
+}
+
+// vectorStencil is a stencil with a vector of weights for each face.
+type vectorStencil struct {
+	faces   []int
+	weights []geodesic.Vector
+}
+
+// apply is stencil.apply with vector weights.
+func (s *vectorStencil) apply(f []float64, base float64) geodesic.Vector {
+	sum := geodesic.Vector{}
+	for j, face := range s.faces {
+		sum = sum.Add(s.weights[j].Scale(f[face] - base))
+	}
+	return sum
+}
+
+// dot returns the sum of the dot products of the weights with u.
+func (s *vectorStencil) dot(u []geodesic.Vector) float64 {
+	sum := 0.0
+	for j, face := range s.faces {
+		sum += s.weights[j].Dot(u[face])
+	}
+	return sum
+}
+
+// New returns the operators for g. g must not be modified afterward.
+func New(g *geodesic.Geodesic) *Mesh {
+	m := &Mesh{
+		g:           g,
+		geometry:    g.Geometry(),
+		gradients:   make([]vectorStencil, len(g.Faces)),
+		divergences: make([]vectorStencil, len(g.Faces)),
+		curls:       make([]vectorStencil, len(g.Faces)),
+		laplacians:  make([]stencil, len(g.Faces)),
+		offsets:     make([]geodesic.Vector, len(g.Faces)),
+	}
+
+	fits := make([]*fit, len(g.Faces))
+	for i := range g.Faces {
+		fits[i] = m.newFit(i)
+		m.gradients[i] = fits[i].gradient()
+	}
+
+	slots := make([]int, len(g.Faces))
+	for i := range slots {
+		slots[i] = -1
+	}
+	for i, face := range g.Faces {
+		f := &fluxes{slots: slots}
+		for k, n := range face.Neighbors {
+			// Both faces find the flux across the edge between them from the
+			// face with the lesser index, so they agree on it exactly.
+			lesser, greater, edge, sign := i, n, k, 1.0
+			if n < i {
+				lesser, greater, sign = n, i, -1
+				for k2, n2 := range g.Faces[n].Neighbors {
+					if n2 == i {
+						edge = k2
+					}
+				}
+			}
+			m.addFluxes(f, fits[lesser], fits[greater], edge, sign)
+		}
+
+		// The integral of the position over a face is half the integral of its
+		// inward normal around its edges.
+		area := m.geometry.Areas[i]
+		m.offsets[i] = f.normals.Scale(-0.5 / area).Reject(g.Centers[i])
+
+		m.divergences[i] = vectorStencil{faces: f.faces, weights: make([]geodesic.Vector, len(f.faces))}
+		m.curls[i] = vectorStencil{faces: f.faces, weights: make([]geodesic.Vector, len(f.faces))}
+		m.laplacians[i] = stencil{faces: f.faces, weights: make([]float64, len(f.faces))}
+		for j, near := range f.faces {
+			m.divergences[i].weights[j] = f.divergences[j].Scale(1 / area)
+			m.curls[i].weights[j] = f.curls[j].Scale(1 / area)
+			m.laplacians[i].weights[j] = f.laplacians[j] / area
+			slots[near] = -1
+		}
+	}
+	return m
+}
+
+// addFluxes adds the fluxes across the edge between the faces lesser and
+// greater fit to f, times sign. edge is the index of greater among the
+// neighbors of lesser.
+func (m *Mesh) addFluxes(f *fluxes, lesser, greater *fit, edge int, sign float64) {
+	corners := m.geometry.Corners[lesser.face]
+	// The edge with neighbor k runs between the corners on either side of it.
+	a := corners[(edge+len(corners)-1)%len(corners)]
+	b := corners[edge]
+
+	// Every point of a great circle has the same normal.
+	normal := a.Cross(b).Normalize()
+	if normal.Dot(greater.center.Sub(lesser.center)) < 0 {
+		normal = normal.Scale(-1)
+	}
+	length := m.geometry.EdgeLengths[lesser.face][edge]
+	f.normals = f.normals.Add(normal.Scale(sign * length))
+
+	// Integrate along the edge by Simpson's rule, taking the mean of the fits
+	// on either side.
+	points := [3]geodesic.Vector{a, a.Add(b).Normalize(), b}
+	shares := [3]float64{length / 6, 4 * length / 6, length / 6}
+	for q, p := range points {
+		// Counter-clockwise around lesser along the edge is the normal turned
+		// a quarter turn about the point.
+		along := p.Cross(normal)
+		for _, fit := range []*fit{lesser, greater} {
+			values, slopes := fit.at(p, normal)
+			for j, near := range fit.faces {
+				value := sign * shares[q] * values[j] / 2
+				f.add(near, normal.Scale(value), along.Scale(value), sign*shares[q]*slopes[j]/2)
+			}
+		}
+	}
+}
+
+// fluxes accumulates how the fluxes out of a face depend on the faces near it.
+type fluxes struct {
+	faces []int
+	// slots holds the index in faces of each face of the sphere, or -1.
+	slots []int
+
+	divergences []geodesic.Vector
+	curls       []geodesic.Vector
+	laplacians  []float64
+	// normals is the sum of the outward normals of the edges times their
+	// lengths.
+	normals geodesic.Vector
+}
+
+func (f *fluxes) add(face int, divergence, curl geodesic.Vector, laplacian float64) {
+	j := f.slots[face]
+	if j < 0 {
+		j = len(f.faces)
+		f.slots[face] = j
+		f.faces = append(f.faces, face)
+		f.divergences = append(f.divergences, geodesic.Vector{})
+		f.curls = append(f.curls, geodesic.Vector{})
+		f.laplacians = append(f.laplacians, 0)
+	}
+	f.divergences[j] = f.divergences[j].Add(divergence)
+	f.curls[j] = f.curls[j].Add(curl)
+	f.laplacians[j] += laplacian
+}
+
+// Gradient returns the gradient of the scalar field f at every face.
+func (m *Mesh) Gradient(f []float64) []geodesic.Vector {
+	result := make([]geodesic.Vector, len(f))
+	for i := range m.gradients {
+		result[i] = m.gradients[i].apply(f, f[i])
+	}
+	return result
+}
+
+// Divergence returns the divergence of the vector field u at every face.
+//
+// The mean divergence over a face is the flux of u out through its edges,
+// over its area. The divergence of any field sums to zero when weighted by
+// area.
+func (m *Mesh) Divergence(u []geodesic.Vector) []float64 {
+	means := make([]float64, len(u))
+	for i := range m.divergences {
+		means[i] = m.divergences[i].dot(u)
+	}
+	return m.centered(means)
+}
+
+// Curl returns the radial component of the curl of the vector field u at every
+// face. Positive values are counter-clockwise rotation when seen from outside
+// the sphere.
+//
+// The mean curl over a face is the circulation of u around its edges, over
+// its area. The curl of any field sums to zero when weighted by area.
+func (m *Mesh) Curl(u []geodesic.Vector) []float64 {
+	means := make([]float64, len(u))
+	for i := range m.curls {
+		means[i] = m.curls[i].dot(u)
+	}
+	return m.centered(means)
+}
+
+// Laplacian returns the Laplacian of the scalar field f at every face.
+//
+// The mean Laplacian over a face is the flux of the gradient of f out through
+// its edges, over its area. The Laplacian of any field sums to zero when
+// weighted by area.
+func (m *Mesh) Laplacian(f []float64) []float64 {
+	means := make([]float64, len(f))
+	for i := range m.laplacians {
+		means[i] = m.laplacians[i].apply(f, f[i])
+	}
+	return m.centered(means)
+}
+
+// VectorLaplacian returns the Laplacian of the vector field u at every face:
+// the gradient of its divergence minus the curl of its curl. On the unit
+// sphere this is the Laplacian of each Cartesian component of u, less the
+// part normal to the sphere, which is how it is computed.
+func (m *Mesh) VectorLaplacian(u []geodesic.Vector) []geodesic.Vector {
+	xs := make([]float64, len(u))
+	ys := make([]float64, len(u))
+	zs := make([]float64, len(u))
+	for i, v := range u {
+		xs[i], ys[i], zs[i] = v.X, v.Y, v.Z
+	}
+	xs, ys, zs = m.Laplacian(xs), m.Laplacian(ys), m.Laplacian(zs)
+
+	result := make([]geodesic.Vector, len(u))
+	for i := range result {
+		result[i] = geodesic.Vector{X: xs[i], Y: ys[i], Z: zs[i]}
+	}
+	return m.Tangent(result)
+}
+
+// Tangent returns u with the component normal to the sphere removed at every
+// face.
+func (m *Mesh) Tangent(u []geodesic.Vector) []geodesic.Vector {
+	result := make([]geodesic.Vector, len(u))
+	for i, v := range u {
+		result[i] = v.Reject(m.g.Centers[i])
+	}
+	return result
+}
+
+// centered returns the values at the centers of faces of the field whose mean
+// over each face is means.
+//
+// The mean over a face differs from the value at its center by the gradient
+// times the offset of its centroid. The corrections are shifted so they sum to
+// zero when weighted by area, keeping whatever means conserve.
+func (m *Mesh) centered(means []float64) []float64 {
+	gradients := m.Gradient(means)
+	corrections := make([]float64, len(means))
+	total := 0.0
+	for i, g := range gradients {
+		corrections[i] = g.Dot(m.offsets[i])
+		total += m.geometry.Areas[i] * corrections[i]
+	}
+
+	result := make([]float64, len(means))
+	for i, mean := range means {
+		result[i] = mean - corrections[i] + total/(4*math.Pi)
+	}
+	return result
+}
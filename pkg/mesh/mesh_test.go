@@ -0,0 +1,279 @@
+package mesh
+
+import (
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+	"sync"
+	"testing"
+)
+
+// Each chamfer halves the distance between faces, so errors which are second
+// order should shrink by about four times.
+const secondOrder = 3.5
+
+// harmonic is proportional to the spherical harmonic Y_3^1, so its Laplacian
+// is -12 times itself.
+func harmonic(v geodesic.Vector) float64 {
+	return v.X * (5*v.Z*v.Z - 1)
+}
+
+func harmonicGradient(v geodesic.Vector) geodesic.Vector {
+	return geodesic.Vector{X: 5*v.Z*v.Z - 1, Z: 10 * v.X * v.Z}.Reject(v)
+}
+
+// harmonicRotation flows along the contours of harmonic, so its divergence is
+// zero and its curl is the Laplacian of harmonic.
+func harmonicRotation(v geodesic.Vector) geodesic.Vector {
+	return v.Cross(harmonicGradient(v))
+}
+
+func scalarField(g *geodesic.Geodesic, fn func(geodesic.Vector) float64) []float64 {
+	result := make([]float64, len(g.Centers))
+	for i, c := range g.Centers {
+		result[i] = fn(c)
+	}
+	return result
+}
+
+func vectorField(g *geodesic.Geodesic, fn func(geodesic.Vector) geodesic.Vector) []geodesic.Vector {
+	result := make([]geodesic.Vector, len(g.Centers))
+	for i, c := range g.Centers {
+		result[i] = fn(c)
+	}
+	return result
+}
+
+// errors returns the root mean square and maximum of errs over g, weighting
+// each face by its area.
+func errors(g *geodesic.Geodesic, errs []float64) (float64, float64) {
+	areas := g.Geometry().Areas
+	sum, max := 0.0, 0.0
+	for i, e := range errs {
+		sum += areas[i] * e * e
+		max = math.Max(max, math.Abs(e))
+	}
+	return math.Sqrt(sum / (4 * math.Pi)), max
+}
+
+var (
+	meshesOnce sync.Once
+	meshes     []*Mesh
+)
+
+// testMeshes returns the meshes of the spheres chamfered three to six times,
+// or five when testing briefly. Coarser spheres are too irregular for errors
+// to shrink steadily.
+func testMeshes() []*Mesh {
+	meshesOnce.Do(func() {
+		g := geodesic.Dodecahedron()
+		for i := 0; i < 3; i++ {
+			g = geodesic.Chamfer(g)
+		}
+
+		maxLevel := 6
+		if testing.Short() {
+			maxLevel = 5
+		}
+		for level := 3; level <= maxLevel; level++ {
+			meshes = append(meshes, New(g))
+			g = geodesic.Chamfer(g)
+		}
+	})
+	return meshes
+}
+
+// testConvergence checks that the errors returned by fn shrink by at least
+// order with each chamfer of the sphere.
+func testConvergence(t *testing.T, order float64, fn func(m *Mesh) []float64) {
+	t.Helper()
+
+	var lastRMS, lastMax float64
+	for level, m := range testMeshes() {
+		g := m.g
+		rms, max := errors(g, fn(m))
+		t.Logf("%d faces: rms error %.3g, max error %.3g", len(g.Faces), rms, max)
+		if level > 0 {
+			if lastRMS/rms < order {
+				t.Errorf("%d faces: got rms error %.3g, want at most %.3g", len(g.Faces), rms, lastRMS/order)
+			}
+			if lastMax/max < order {
+				t.Errorf("%d faces: got max error %.3g, want at most %.3g", len(g.Faces), max, lastMax/order)
+			}
+		}
+		lastRMS, lastMax = rms, max
+	}
+}
+
+func TestMesh_Gradient(t *testing.T) {
+	testConvergence(t, secondOrder, func(m *Mesh) []float64 {
+		got := m.Gradient(scalarField(m.g, harmonic))
+		errs := make([]float64, len(got))
+		for i, c := range m.g.Centers {
+			errs[i] = got[i].Sub(harmonicGradient(c)).Length()
+		}
+		return errs
+	})
+}
+
+func TestMesh_Divergence(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		field func(geodesic.Vector) geodesic.Vector
+		want  func(geodesic.Vector) float64
+	}{{
+		name:  "gradient",
+		field: harmonicGradient,
+		want:  func(v geodesic.Vector) float64 { return -12 * harmonic(v) },
+	}, {
+		name:  "rotation",
+		field: harmonicRotation,
+		want:  func(v geodesic.Vector) float64 { return 0 },
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			testConvergence(t, secondOrder, func(m *Mesh) []float64 {
+				got := m.Divergence(vectorField(m.g, tc.field))
+				errs := make([]float64, len(got))
+				for i, c := range m.g.Centers {
+					errs[i] = got[i] - tc.want(c)
+				}
+				return errs
+			})
+		})
+	}
+}
+
+func TestMesh_Curl(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		field func(geodesic.Vector) geodesic.Vector
+		want  func(geodesic.Vector) float64
+	}{{
+		name:  "gradient",
+		field: harmonicGradient,
+		want:  func(v geodesic.Vector) float64 { return 0 },
+	}, {
+		name:  "rotation",
+		field: harmonicRotation,
+		want:  func(v geodesic.Vector) float64 { return -12 * harmonic(v) },
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			testConvergence(t, secondOrder, func(m *Mesh) []float64 {
+				got := m.Curl(vectorField(m.g, tc.field))
+				errs := make([]float64, len(got))
+				for i, c := range m.g.Centers {
+					errs[i] = got[i] - tc.want(c)
+				}
+				return errs
+			})
+		})
+	}
+}
+
+func TestMesh_Laplacian(t *testing.T) {
+	testConvergence(t, secondOrder, func(m *Mesh) []float64 {
+		got := m.Laplacian(scalarField(m.g, harmonic))
+		errs := make([]float64, len(got))
+		for i, c := range m.g.Centers {
+			errs[i] = got[i] + 12*harmonic(c)
+		}
+		return errs
+	})
+}
+
+// The gradient of the divergence of harmonicGradient is -12 times itself, as
+// is the curl of the curl of harmonicRotation, so the Laplacian of both is -12
+// times themselves.
+func TestMesh_VectorLaplacian(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		field func(geodesic.Vector) geodesic.Vector
+	}{{
+		name:  "gradient",
+		field: harmonicGradient,
+	}, {
+		name:  "rotation",
+		field: harmonicRotation,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			testConvergence(t, secondOrder, func(m *Mesh) []float64 {
+				got := m.VectorLaplacian(vectorField(m.g, tc.field))
+				errs := make([]float64, len(got))
+				for i, c := range m.g.Centers {
+					errs[i] = got[i].Sub(tc.field(c).Scale(-12)).Length()
+				}
+				return errs
+			})
+		})
+	}
+}
+
+func TestMesh_Conservation(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Chamfer(geodesic.Dodecahedron()))
+	m := New(g)
+
+	// Any field conserves, so take one with nothing in particular to cancel.
+	u := m.Tangent(vectorField(g, func(v geodesic.Vector) geodesic.Vector {
+		return geodesic.Vector{X: math.Sin(3 * v.Y), Y: v.Z * v.X, Z: math.Exp(v.X)}
+	}))
+	areas := g.Geometry().Areas
+
+	for _, tc := range []struct {
+		name string
+		op   func([]geodesic.Vector) []float64
+	}{{
+		name: "divergence",
+		op:   m.Divergence,
+	}, {
+		name: "curl",
+		op:   m.Curl,
+	}, {
+		name: "laplacian",
+		op: func(u []geodesic.Vector) []float64 {
+			f := make([]float64, len(u))
+			for i, v := range u {
+				f[i] = v.X
+			}
+			return m.Laplacian(f)
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			total, scale := 0.0, 0.0
+			for i, d := range tc.op(u) {
+				total += areas[i] * d
+				scale += areas[i] * math.Abs(d)
+			}
+			if math.Abs(total) > 1e-12*scale {
+				t.Errorf("got total %g weighted by area, want 0", total)
+			}
+		})
+	}
+}
+
+func TestMesh_Tangent(t *testing.T) {
+	g := geodesic.Chamfer(geodesic.Dodecahedron())
+	m := New(g)
+
+	u := make([]geodesic.Vector, len(g.Centers))
+	for i, c := range g.Centers {
+		u[i] = c.Scale(2).Add(geodesic.Vector{X: 1, Y: 2, Z: 3})
+	}
+
+	for i, v := range m.Tangent(u) {
+		if d := v.Dot(g.Centers[i]); math.Abs(d) > 1e-12 {
+			t.Errorf("face %d: got normal component %g, want 0", i, d)
+		}
+	}
+}
+
+func ExampleMesh_Laplacian() {
+	g := geodesic.Dodecahedron()
+	m := New(g)
+
+	constant := make([]float64, len(g.Faces))
+	for i := range constant {
+		constant[i] = 1
+	}
+	fmt.Println(m.Laplacian(constant)[0])
+	// Output: 0
+}
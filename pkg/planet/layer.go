@@ -0,0 +1,197 @@
+package planet
+
+import (
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image"
+)
+
+// LayerKind is the type of value a Layer holds for each cell.
+type LayerKind string
+
+const (
+	// Scalar layers hold a number for each cell, such as rainfall.
+	Scalar LayerKind = "scalar"
+	// Vector layers hold a direction and magnitude for each cell, such as
+	// wind.
+	Vector LayerKind = "vector"
+	// Categorical layers hold one of a fixed set of labels for each cell,
+	// such as biome.
+	Categorical LayerKind = "categorical"
+)
+
+// Names of the layers backed by Planet's own fields.
+const (
+	HeightsLayer = "heights"
+	WatersLayer  = "waters"
+	FlowsLayer   = "flows"
+)
+
+// Layer is a named quantity with a value for every cell of a Planet.
+//
+// Only the slice matching Kind is set.
+type Layer struct {
+	Name  string    `json:"name"`
+	Kind  LayerKind `json:"kind"`
+	Units string    `json:"units,omitempty"`
+	// Metadata is free-form information about how the layer was derived.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	Scalars    []float64         `json:"scalars,omitempty"`
+	Vectors    []geodesic.Vector `json:"vectors,omitempty"`
+	Categories []int             `json:"categories,omitempty"`
+	// Labels names each category of a Categorical layer.
+	Labels []string `json:"labels,omitempty"`
+}
+
+func NewScalarLayer(name, units string, values []float64) *Layer {
+	return &Layer{Name: name, Kind: Scalar, Units: units, Scalars: values}
+}
+
+func NewVectorLayer(name, units string, values []geodesic.Vector) *Layer {
+	return &Layer{Name: name, Kind: Vector, Units: units, Vectors: values}
+}
+
+func NewCategoricalLayer(name string, labels []string, values []int) *Layer {
+	return &Layer{Name: name, Kind: Categorical, Labels: labels, Categories: values}
+}
+
+// Len returns the number of cells the layer has values for.
+func (l *Layer) Len() int {
+	switch l.Kind {
+	case Vector:
+		return len(l.Vectors)
+	case Categorical:
+		return len(l.Categories)
+	default:
+		return len(l.Scalars)
+	}
+}
+
+// Magnitudes returns a scalar value for every cell: the value itself for
+// Scalar layers, the length of Vector layers and the category of Categorical
+// layers.
+func (l *Layer) Magnitudes() []float64 {
+	switch l.Kind {
+	case Vector:
+		result := make([]float64, len(l.Vectors))
+		for i, v := range l.Vectors {
+			result[i] = v.Length()
+		}
+		return result
+	case Categorical:
+		result := make([]float64, len(l.Categories))
+		for i, c := range l.Categories {
+			result[i] = float64(c)
+		}
+		return result
+	default:
+		return l.Scalars
+	}
+}
+
+// AddLayer attaches l to p, replacing any existing layer with the same name.
+func (p *Planet) AddLayer(l *Layer) error {
+	switch l.Name {
+	case HeightsLayer, WatersLayer, FlowsLayer:
+		return fmt.Errorf("layer name %q is reserved", l.Name)
+	}
+	switch l.Kind {
+	case Scalar, Vector, Categorical:
+	default:
+		return fmt.Errorf("layer %q has unknown kind %q", l.Name, l.Kind)
+	}
+	if len(p.Heights) > 0 && l.Len() != len(p.Heights) {
+		return fmt.Errorf("layer %q has %d values for %d cells", l.Name, l.Len(), len(p.Heights))
+	}
+
+	for i, existing := range p.Layers {
+		if existing.Name == l.Name {
+			p.Layers[i] = l
+			return nil
+		}
+	}
+	p.Layers = append(p.Layers, l)
+	return nil
+}
+
+// Layer returns the layer named name, or nil if p has no such layer.
+//
+// The heights, waters and flows layers share memory with p's fields.
+func (p *Planet) Layer(name string) *Layer {
+	switch name {
+	case HeightsLayer:
+		return p.fieldLayer(name, p.Heights)
+	case WatersLayer:
+		return p.fieldLayer(name, p.Waters)
+	case FlowsLayer:
+		return p.fieldLayer(name, p.Flows)
+	}
+
+	for _, l := range p.Layers {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+func (p *Planet) fieldLayer(name string, values []float64) *Layer {
+	if len(values) == 0 {
+		return nil
+	}
+	return NewScalarLayer(name, "", values)
+}
+
+// LayerNames returns the names of every layer p has values for.
+func (p *Planet) LayerNames() []string {
+	var result []string
+	for _, name := range []string{HeightsLayer, WatersLayer, FlowsLayer} {
+		if p.Layer(name) != nil {
+			result = append(result, name)
+		}
+	}
+	for _, l := range p.Layers {
+		result = append(result, l.Name)
+	}
+	return result
+}
+
+// RemoveLayer detaches the layer named name from p.
+func (p *Planet) RemoveLayer(name string) {
+	for i, l := range p.Layers {
+		if l.Name == name {
+			p.Layers = append(p.Layers[:i], p.Layers[i+1:]...)
+			return
+		}
+	}
+}
+
+// RenderLayer paints the layer named name with cs.
+//
+// Scalar layers are interpolated between cells, Vector layers are painted by
+// their length and Categorical layers by the category of the closest cell,
+// in the color of the threshold of cs nearest it.
+func RenderLayer(p *Planet, name string, cells *render.CellMap, cs *render.ColorScale) (*image.RGBA, error) {
+	l := p.Layer(name)
+	if l == nil {
+		return nil, fmt.Errorf("planet has no layer %q", name)
+	}
+
+	screen := cells.Screen
+	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
+
+	values := l.Magnitudes()
+	if l.Kind == Categorical {
+		pxValues := make([]float64, len(cells.Cells))
+		for pidx, cell := range cells.Cells {
+			pxValues[pidx] = values[cell]
+		}
+		screen.PaintNearest(pxValues, cs, img)
+	} else {
+		screen.Paint(cells.Sample(values), cs, img)
+	}
+	cells.Mask(img)
+	return img, nil
+}
@@ -0,0 +1,100 @@
+package planet
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/store"
+	"testing"
+)
+
+func TestPlanet_AddLayer(t *testing.T) {
+	tcs := []struct {
+		name    string
+		layer   *Layer
+		wantErr bool
+	}{
+		{
+			name:  "scalar",
+			layer: NewScalarLayer("rainfall", "mm", make([]float64, 42)),
+		},
+		{
+			name:  "vector",
+			layer: NewVectorLayer("wind", "m/s", make([]geodesic.Vector, 42)),
+		},
+		{
+			name:  "categorical",
+			layer: NewCategoricalLayer("biome", []string{"desert", "forest"}, make([]int, 42)),
+		},
+		{
+			name:    "reserved name",
+			layer:   NewScalarLayer(HeightsLayer, "", make([]float64, 42)),
+			wantErr: true,
+		},
+		{
+			name:    "wrong length",
+			layer:   NewScalarLayer("rainfall", "mm", make([]float64, 12)),
+			wantErr: true,
+		},
+		{
+			name:    "unknown kind",
+			layer:   &Layer{Name: "rainfall", Kind: "tensor"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Planet{Heights: make([]float64, 42)}
+
+			err := p.AddLayer(tc.layer)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("got AddLayer() error %v, want error %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if got := p.Layer(tc.layer.Name); got != tc.layer {
+				t.Errorf("got Layer(%q) = %v, want %v", tc.layer.Name, got, tc.layer)
+			}
+		})
+	}
+}
+
+func TestLoadFrom_Layers(t *testing.T) {
+	s := store.NewMemory()
+
+	p := &Planet{Size: 1, Heights: make([]float64, 42)}
+	err := p.AddLayer(NewScalarLayer("rainfall", "mm", make([]float64, 42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.AddLayer(NewCategoricalLayer("biome", []string{"desert", "forest"}, make([]int, 42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SaveTo(s, 1, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spheres := []*geodesic.Geodesic{geodesic.Dodecahedron()}
+	spheres = append(spheres, geodesic.Chamfer(spheres[0]))
+	got, err := LoadFrom(s, 1, spheres[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{HeightsLayer, "rainfall", "biome"}, got.LayerNames()); diff != "" {
+		t.Error(diff)
+	}
+	for _, name := range got.LayerNames() {
+		if n := got.Layer(name).Len(); n != 12 {
+			t.Errorf("got Layer(%q).Len() = %d, want %d", name, n, 12)
+		}
+	}
+	if diff := cmp.Diff([]string{"desert", "forest"}, got.Layer("biome").Labels); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -9,4 +9,8 @@ type Planet struct {
 	Waters []float64 `json:"waters,omitempty"`
 	Flows []float64 `json:"flows,omitempty"`
 	Climates []climate.Climate `json:"temperatures,omitempty"`
+
+	// Layers holds any other per-cell quantities, such as those derived from
+	// the fields above.
+	Layers []*Layer `json:"layers,omitempty"`
 }
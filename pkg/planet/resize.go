@@ -0,0 +1,157 @@
+package planet
+
+import (
+	"errors"
+	"github.com/willbeason/worldproc/pkg/climate"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/noise"
+	"math"
+)
+
+// Detail returns variation to add to the height of the cell at v, which first
+// appears in the sphere of the given size.
+type Detail func(size int, v geodesic.Vector) float64
+
+// NoiseDetail adds noise to the heights of new cells, halving in amplitude
+// with each size so finer cells get finer detail.
+func NoiseDetail(n *noise.PerlinFractal, amplitude float64) Detail {
+	return func(size int, v geodesic.Vector) float64 {
+		return amplitude * math.Pow(0.5, float64(size)) * n.ValueAt(v)
+	}
+}
+
+// SizeOf returns the size of the chamfered sphere with nFaces faces, or false
+// if there is none.
+func SizeOf(nFaces int) (int, bool) {
+	n := 12
+	for size := 0; n <= nFaces; size++ {
+		if n == nFaces {
+			return size, true
+		}
+		n = 4*n - 6
+	}
+	return 0, false
+}
+
+// currentSize returns the size of the sphere p's fields are defined on.
+func (p *Planet) currentSize() int {
+	for _, n := range []int{len(p.Heights), len(p.Waters), len(p.Flows), len(p.Climates)} {
+		if size, ok := SizeOf(n); ok {
+			return size
+		}
+	}
+	return p.Size
+}
+
+// Resize moves every field and layer of p onto spheres[size], averaging
+// cells together to make p coarser and interpolating between them to make it
+// finer. spheres must start with the dodecahedron, and is extended with
+// Chamfer if it doesn't reach p's current size or size.
+//
+// If detail is not nil, it is added to the heights of new cells when making p
+// finer.
+func Resize(p *Planet, spheres []*geodesic.Geodesic, size int, detail Detail) error {
+	if len(spheres) == 0 {
+		return errors.New("resizing planet without spheres")
+	}
+
+	from := p.currentSize()
+	for len(spheres) <= from || len(spheres) <= size {
+		spheres = append(spheres[:len(spheres):len(spheres)], geodesic.Chamfer(spheres[len(spheres)-1]))
+	}
+
+	for ; from > size; from-- {
+		fine, coarse := spheres[from], spheres[from-1]
+		p.resample(
+			func(f []float64) []float64 { return geodesic.Restrict(f, fine, coarse) },
+			func(f []geodesic.Vector) []geodesic.Vector { return geodesic.RestrictVectors(f, fine, coarse) },
+			func(f []int) []int { return geodesic.RestrictCategories(f, coarse) },
+		)
+	}
+
+	for ; from < size; from++ {
+		coarse, fine := spheres[from], spheres[from+1]
+		nCoarse := len(coarse.Faces)
+		p.resample(
+			func(f []float64) []float64 { return geodesic.Prolong(f, coarse, fine) },
+			func(f []geodesic.Vector) []geodesic.Vector { return geodesic.ProlongVectors(f, coarse, fine) },
+			func(f []int) []int { return geodesic.ProlongCategories(f, coarse, fine) },
+		)
+
+		if detail != nil && len(p.Heights) > 0 {
+			for i := nCoarse; i < len(p.Heights); i++ {
+				p.Heights[i] += detail(from+1, fine.Centers[i])
+			}
+		}
+	}
+
+	p.Size = size
+	return nil
+}
+
+// resample replaces every field and layer of p with the result of the
+// function for its type.
+func (p *Planet) resample(scalars func([]float64) []float64, vectors func([]geodesic.Vector) []geodesic.Vector, categories func([]int) []int) {
+	resampleScalars := func(f []float64) []float64 {
+		if len(f) == 0 {
+			return f
+		}
+		return scalars(f)
+	}
+
+	p.Heights = resampleScalars(p.Heights)
+	p.Waters = resampleScalars(p.Waters)
+	p.Flows = resampleScalars(p.Flows)
+	if len(p.Climates) > 0 {
+		p.Climates = resampleClimates(p.Climates, scalars, vectors)
+	}
+
+	for _, l := range p.Layers {
+		switch l.Kind {
+		case Scalar:
+			l.Scalars = resampleScalars(l.Scalars)
+		case Vector:
+			if len(l.Vectors) > 0 {
+				l.Vectors = vectors(l.Vectors)
+			}
+		case Categorical:
+			if len(l.Categories) > 0 {
+				l.Categories = categories(l.Categories)
+			}
+		}
+	}
+}
+
+func resampleClimates(climates []climate.Climate, scalars func([]float64) []float64, vectors func([]geodesic.Vector) []geodesic.Vector) []climate.Climate {
+	n := len(climates)
+	landSpecificHeats := make([]float64, n)
+	landEnergies := make([]float64, n)
+	airs := make([]float64, n)
+	airEnergies := make([]float64, n)
+	airVelocities := make([]geodesic.Vector, n)
+	for i, c := range climates {
+		landSpecificHeats[i] = c.LandSpecificHeat
+		landEnergies[i] = c.LandEnergy
+		airs[i] = c.Air
+		airEnergies[i] = c.AirEnergy
+		airVelocities[i] = c.AirVelocity
+	}
+
+	landSpecificHeats = scalars(landSpecificHeats)
+	landEnergies = scalars(landEnergies)
+	airs = scalars(airs)
+	airEnergies = scalars(airEnergies)
+	airVelocities = vectors(airVelocities)
+
+	result := make([]climate.Climate, len(airs))
+	for i := range result {
+		result[i] = climate.Climate{
+			LandSpecificHeat: landSpecificHeats[i],
+			LandEnergy:       landEnergies[i],
+			Air:              airs[i],
+			AirEnergy:        airEnergies[i],
+			AirVelocity:      airVelocities[i],
+		}
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package planet
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	spheres := geodesic.New(3, true)
+
+	heights := make([]float64, len(spheres[1].Centers))
+	for i, c := range spheres[1].Centers {
+		heights[i] = c.Z
+	}
+	p := &Planet{Heights: heights}
+	err := p.AddLayer(NewCategoricalLayer("biome", nil, make([]int, len(heights))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Resize(p, spheres, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Heights) != 642 || p.Layer("biome").Len() != 642 {
+		t.Fatalf("got %d heights and %d biomes, want %d", len(p.Heights), p.Layer("biome").Len(), 642)
+	}
+	// Refining keeps the values of existing cells.
+	if diff := cmp.Diff(heights, p.Heights[:len(heights)]); diff != "" {
+		t.Error(diff)
+	}
+
+	for i, c := range spheres[3].Centers {
+		p.Heights[i] = c.Z
+	}
+	err = Resize(p, spheres[:1], 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Heights) != 162 {
+		t.Fatalf("got %d heights, want %d", len(p.Heights), 162)
+	}
+	// Averaging a smooth field stays close to its value at each center.
+	for i, c := range spheres[2].Centers {
+		if diff := cmp.Diff(c.Z, p.Heights[i], cmpopts.EquateApprox(0.0, 0.03)); diff != "" {
+			t.Errorf("cell %d: %s", i, diff)
+		}
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	for size := 0; size < 10; size++ {
+		n := 1
+		for i := 0; i < size; i++ {
+			n *= 4
+		}
+		got, ok := SizeOf(10*n + 2)
+		if !ok || got != size {
+			t.Errorf("got SizeOf(%d) = %d, %t, want %d", 10*n+2, got, ok, size)
+		}
+	}
+	if _, ok := SizeOf(100); ok {
+		t.Error("got SizeOf(100) ok, want not ok")
+	}
+}
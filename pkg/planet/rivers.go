@@ -0,0 +1,48 @@
+package planet
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/water"
+	"image/color"
+)
+
+// RiverColor is the color DrawRivers draws rivers with by default.
+var RiverColor = color.RGBA{R: 40, G: 90, B: 200, A: 255}
+
+// Drainage returns where water falling on the land of p runs, over the
+// surface of its land and water. Rivers end where they reach an ocean or
+// lake, and if p has no water they all run toward its lowest point.
+func Drainage(p *Planet, sphere *geodesic.Geodesic) *water.Drainage {
+	surface := make([]float64, len(p.Heights))
+	copy(surface, p.Heights)
+	var outlets []int
+	for i, w := range p.Waters {
+		surface[i] += w
+		if w > 0 {
+			outlets = append(outlets, i)
+		}
+	}
+	return water.Drain(surface, sphere, outlets)
+}
+
+// Rivers returns the rivers of p: the channels of its drainage whose Flows are
+// at least minFlow.
+func Rivers(p *Planet, sphere *geodesic.Geodesic, minFlow float64) []water.River {
+	if len(p.Flows) == 0 {
+		return nil
+	}
+	return Drainage(p, sphere).Rivers(p.Flows, minFlow)
+}
+
+// DrawRivers draws rivers along the centers of the cells of sphere they flow
+// through.
+func DrawRivers(o *render.Overlay, rivers []water.River, sphere *geodesic.Geodesic, c color.RGBA) {
+	for _, r := range rivers {
+		points := make([]geodesic.Vector, len(r.Cells))
+		for i, cell := range r.Cells {
+			points[i] = sphere.Centers[cell]
+		}
+		o.Polyline(points, c)
+	}
+}
@@ -3,61 +3,63 @@ package planet
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/store"
 	"os"
-	"path/filepath"
 )
 
+const planetsDir = "planets"
+
+func key(seed int64) string {
+	return fmt.Sprintf("%d.json", seed)
+}
+
+// Save writes planet to the planets directory, panicking on failure.
 func Save(seed int64, planet *Planet) {
-	bytes, err := json.Marshal(planet)
+	err := SaveTo(store.Dir(planetsDir), seed, planet)
 	if err != nil {
 		panic(err)
 	}
-	name := fmt.Sprintf("%d.json", seed)
-	err = ioutil.WriteFile(filepath.Join("planets", name), bytes, os.ModePerm)
+}
+
+// Load reads the planet with seed from the planets directory, panicking on
+// failure. Returns nil if there is no such planet.
+func Load(seed int64, spheres []*geodesic.Geodesic) *Planet {
+	p, err := LoadFrom(store.Dir(planetsDir), seed, spheres)
 	if err != nil {
 		panic(err)
 	}
+	return p
+}
+
+// SaveTo writes planet to s.
+func SaveTo(s store.Store, seed int64, planet *Planet) error {
+	bytes, err := json.Marshal(planet)
+	if err != nil {
+		return err
+	}
+	return s.Write(key(seed), bytes)
 }
 
-func Load(seed int64, size int) *Planet {
+// LoadFrom reads the planet with seed from s, resized to the last sphere in
+// spheres. Returns nil and no error if s has no such planet.
+func LoadFrom(s store.Store, seed int64, spheres []*geodesic.Geodesic) (*Planet, error) {
 	p := &Planet{}
-	name := fmt.Sprintf("%d.json", seed)
-	bytes, err := ioutil.ReadFile(filepath.Join("planets", name))
+	bytes, err := s.Read(key(seed))
 	if os.IsNotExist(err) {
-		return nil
+		return nil, nil
 	}
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	err = json.Unmarshal(bytes, p)
 	if err != nil {
-		panic(err)
-	}
-
-	//if size > p.Size {
-	//	panic(fmt.Sprintf("requested size %d larger than rendered size %d", size, p.Size))
-	//}
-
-	nFaces := 1
-	for i := 0; i < size; i++ {
-		nFaces *= 4
+		return nil, fmt.Errorf("reading planet %d: %w", seed, err)
 	}
-	nFaces = 10*nFaces + 2
 
-	p.Size = size
-	if len(p.Heights) > 0 {
-		p.Heights = p.Heights[:nFaces]
-	}
-	if len(p.Waters) > 0 {
-		p.Waters = p.Waters[:nFaces]
-	}
-	if len(p.Flows) > 0 {
-		p.Flows = p.Flows[:nFaces]
-	}
-	if len(p.Climates) > 0 {
-		p.Climates = p.Climates[:nFaces]
+	err = Resize(p, spheres, len(spheres)-1, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading planet %d: %w", seed, err)
 	}
-
-	return p
+	return p, nil
 }
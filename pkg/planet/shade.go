@@ -0,0 +1,133 @@
+package planet
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/sun"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Hillshade lights the surface of a planet, cell by cell, so shading is the
+// same however the planet is projected.
+type Hillshade struct {
+	// Exaggeration is how far above the unit sphere the surface is raised per
+	// unit of height when finding slopes and shadows.
+	Exaggeration float64
+	// Ambient is the brightness of cells facing away from the light or in
+	// shadow, from 0 to 1.
+	Ambient float64
+	// Shadows is whether terrain between a cell and the light darkens it.
+	Shadows bool
+}
+
+// DefaultHillshade is the shading RenderTerrain uses.
+var DefaultHillshade = Hillshade{
+	Exaggeration: 0.05,
+	Ambient:      0.1,
+	Shadows:      true,
+}
+
+// maxShadowSteps bounds how far shadow rays march, in steps of about half the
+// distance between cells.
+const maxShadowSteps = 4096
+
+// Shade returns the brightness of every cell of p on g, from Ambient to 1.
+//
+// Cells are lit by how directly their surface, the land or the water above
+// it, faces the light. The normal of the surface at each cell is found from
+// the triangles of cell centers around it.
+func (h Hillshade) Shade(p *Planet, g *geodesic.Geodesic, light sun.Light) []float64 {
+	surface := make([]float64, len(p.Heights))
+	copy(surface, p.Heights)
+	for i, w := range p.Waters {
+		surface[i] += w
+	}
+	normals := geodesic.SurfaceNormals(g, surface, h.Exaggeration)
+
+	maxRadius := 0.0
+	for _, height := range surface {
+		maxRadius = math.Max(maxRadius, h.radius(height))
+	}
+	step := 0.5 * math.Sqrt(4*math.Pi/float64(len(g.Centers)))
+
+	result := make([]float64, len(g.Centers))
+	parallelCells(len(g.Centers), func(i int) {
+		d := light.Direction(g.Centers[i])
+		lambert := normals[i].Dot(d)
+		if lambert <= 0 || h.Shadows && h.shadowed(g, surface, i, d, maxRadius, step) {
+			result[i] = h.Ambient
+			return
+		}
+		result[i] = h.Ambient + (1-h.Ambient)*math.Min(1, lambert)
+	})
+	return result
+}
+
+// radius returns the distance from the center of the planet to the surface at
+// height, taking the planet to have unit radius.
+func (h Hillshade) radius(height float64) float64 {
+	return 1 + h.Exaggeration*height
+}
+
+// shadowed returns whether the surface blocks the ray from cell i toward the
+// light in direction d. maxRadius is the greatest radius of the surface,
+// beyond which nothing can block the ray.
+func (h Hillshade) shadowed(g *geodesic.Geodesic, surface []float64, i int, d geodesic.Vector, maxRadius, step float64) bool {
+	origin := g.Centers[i].Normalize().Scale(h.radius(surface[i]))
+	cell := i
+	for n := 1; n <= maxShadowSteps; n++ {
+		p := origin.Add(d.Scale(float64(n) * step))
+		r := p.Length()
+		if r > maxRadius {
+			return false
+		}
+
+		cell = walk(g, cell, p)
+		if cell == i {
+			// The ray hasn't left the cell it starts from yet.
+			continue
+		}
+		if r < h.radius(surface[cell]) {
+			return true
+		}
+	}
+	return false
+}
+
+// walk returns the cell closest to the direction of v, starting from cell
+// start and moving to whichever neighbor is closer until none is. It is fast
+// when v is near start, as along a ray.
+func walk(g *geodesic.Geodesic, start int, v geodesic.Vector) int {
+	v = v.Normalize()
+	cell, best := start, g.Centers[start].Normalize().Dot(v)
+	for {
+		next := cell
+		for _, n := range g.Faces[cell].Neighbors {
+			if dot := g.Centers[n].Normalize().Dot(v); dot > best {
+				next, best = n, dot
+			}
+		}
+		if next == cell {
+			return cell
+		}
+		cell = next
+	}
+}
+
+// parallelCells calls fn for every cell from 0 to n, splitting them between one
+// worker per CPU.
+func parallelCells(n int, fn func(i int)) {
+	workers := runtime.NumCPU()
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += workers {
+				fn(i)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
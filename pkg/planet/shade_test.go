@@ -0,0 +1,58 @@
+package planet
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/sun"
+	"testing"
+)
+
+func TestHillshade_Shade(t *testing.T) {
+	g := geodesic.New(3, true)[3]
+	light := &sun.Directional{Sun: geodesic.Vector{X: 1}}
+
+	// Far from the edge of day, a smooth sphere is lit only by the light.
+	p := &Planet{Heights: make([]float64, len(g.Centers))}
+	shades := DefaultHillshade.Shade(p, g, light)
+	for i, c := range g.Centers {
+		want := DefaultHillshade.Ambient
+		if c.X > 0.1 {
+			want += (1 - DefaultHillshade.Ambient) * c.Normalize().X
+		}
+		if c.X < 0.1 && c.X > -0.1 {
+			continue
+		}
+		if diff := cmp.Diff(want, shades[i], cmpopts.EquateApprox(0.0, 0.05)); diff != "" {
+			t.Errorf("cell %d: %s", i, diff)
+		}
+	}
+
+	// A mountain at the edge of day shades the cells behind it.
+	mountain := walk(g, 0, geodesic.Vector{X: 0.5, Y: 1})
+	p.Heights[mountain] = 4
+	shaded := DefaultHillshade.Shade(p, g, light)
+	// Skip the slope of the mountain, which faces away from the light.
+	behind := furthestFrom(g, furthestFrom(g, mountain, light.Sun), light.Sun)
+	if shades[behind] <= DefaultHillshade.Ambient || shaded[behind] != DefaultHillshade.Ambient {
+		t.Errorf("got shade %v behind mountain, want %v", shaded[behind], DefaultHillshade.Ambient)
+	}
+
+	noShadows := DefaultHillshade
+	noShadows.Shadows = false
+	if got := noShadows.Shade(p, g, light)[behind]; got == DefaultHillshade.Ambient {
+		t.Errorf("got shade %v behind mountain without shadows, want more", got)
+	}
+}
+
+// furthestFrom returns the neighbor of cell i furthest in the direction
+// opposite d.
+func furthestFrom(g *geodesic.Geodesic, i int, d geodesic.Vector) int {
+	result := g.Faces[i].Neighbors[0]
+	for _, n := range g.Faces[i].Neighbors {
+		if g.Centers[n].Dot(d) < g.Centers[result].Dot(d) {
+			result = n
+		}
+	}
+	return result
+}
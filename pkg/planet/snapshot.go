@@ -0,0 +1,107 @@
+package planet
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/climate"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/store"
+	"os"
+)
+
+// Names of the layers ClimateLayers derives.
+const (
+	TemperatureLayer = "temperature"
+	WindLayer        = "wind"
+	PressureLayer    = "pressure"
+)
+
+// Snapshot is the climate of a planet at a moment of a simulation. The rest
+// of the planet doesn't change as its climate is simulated, so isn't kept.
+type Snapshot struct {
+	// Date is the date of the snapshot in days since spring equinox year 0,
+	// as sun.Directional.Set takes it.
+	Date     float64           `json:"date"`
+	Climates []climate.Climate `json:"temperatures"`
+}
+
+func snapshotKey(seed int64, index int) string {
+	return fmt.Sprintf("snapshots/%d/%05d.json", seed, index)
+}
+
+// SaveSnapshot writes the index-th snapshot of the planet with seed to the
+// planets directory, panicking on failure.
+func SaveSnapshot(seed int64, index int, snapshot *Snapshot) {
+	err := SaveSnapshotTo(store.Dir(planetsDir), seed, index, snapshot)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// LoadSnapshots reads the snapshots of the planet with seed from the planets
+// directory, panicking on failure.
+func LoadSnapshots(seed int64, spheres []*geodesic.Geodesic) []Snapshot {
+	snapshots, err := LoadSnapshotsFrom(store.Dir(planetsDir), seed, spheres)
+	if err != nil {
+		panic(err)
+	}
+	return snapshots
+}
+
+// SaveSnapshotTo writes the index-th snapshot of the planet with seed to s.
+func SaveSnapshotTo(s store.Store, seed int64, index int, snapshot *Snapshot) error {
+	bytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.Write(snapshotKey(seed, index), bytes)
+}
+
+// LoadSnapshotsFrom reads the snapshots of the planet with seed from s in
+// order, resized to the last sphere in spheres, stopping at the first index
+// s has no snapshot for.
+func LoadSnapshotsFrom(s store.Store, seed int64, spheres []*geodesic.Geodesic) ([]Snapshot, error) {
+	var result []Snapshot
+	for index := 0; ; index++ {
+		bytes, err := s.Read(snapshotKey(seed, index))
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot := Snapshot{}
+		err = json.Unmarshal(bytes, &snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("reading snapshot %d of planet %d: %w", index, seed, err)
+		}
+
+		p := &Planet{Climates: snapshot.Climates}
+		err = Resize(p, spheres, len(spheres)-1, nil)
+		if err != nil {
+			return nil, fmt.Errorf("reading snapshot %d of planet %d: %w", index, seed, err)
+		}
+		snapshot.Climates = p.Climates
+		result = append(result, snapshot)
+	}
+}
+
+// ClimateLayers returns the air temperature, wind and air pressure of each
+// cell of climates as layers.
+func ClimateLayers(climates []climate.Climate) []*Layer {
+	temperatures := make([]float64, len(climates))
+	winds := make([]geodesic.Vector, len(climates))
+	pressures := make([]float64, len(climates))
+	for i := range climates {
+		temperatures[i] = climates[i].AirTemperature()
+		winds[i] = climates[i].AirVelocity
+		pressures[i] = climates[i].Pressure()
+	}
+
+	return []*Layer{
+		NewScalarLayer(TemperatureLayer, "K", temperatures),
+		NewVectorLayer(WindLayer, "", winds),
+		NewScalarLayer(PressureLayer, "", pressures),
+	}
+}
@@ -0,0 +1,49 @@
+package planet
+
+import (
+	"github.com/willbeason/worldproc/pkg/climate"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/store"
+	"testing"
+)
+
+func TestLoadSnapshotsFrom(t *testing.T) {
+	spheres := geodesic.New(2, true)
+	s := store.NewMemory()
+
+	for i := 0; i < 3; i++ {
+		climates := make([]climate.Climate, len(spheres[1].Centers))
+		for j := range climates {
+			climates[j] = climate.Climate{Air: 1, AirEnergy: climate.AirSpecificHeat * float64(270+i)}
+		}
+		err := SaveSnapshotTo(s, 7, i, &Snapshot{Date: float64(i) / 24, Climates: climates})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := LoadSnapshotsFrom(s, 7, spheres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(got))
+	}
+	for i, snapshot := range got {
+		if snapshot.Date != float64(i)/24 {
+			t.Errorf("snapshot %d: got date %v, want %v", i, snapshot.Date, float64(i)/24)
+		}
+		// Snapshots are resized to the last sphere.
+		if len(snapshot.Climates) != len(spheres[2].Centers) {
+			t.Fatalf("snapshot %d: got %d climates, want %d", i, len(snapshot.Climates), len(spheres[2].Centers))
+		}
+		if temperature := snapshot.Climates[0].AirTemperature(); temperature < float64(270+i)-1e-9 || temperature > float64(270+i)+1e-9 {
+			t.Errorf("snapshot %d: got temperature %v, want %d", i, temperature, 270+i)
+		}
+	}
+
+	got, err = LoadSnapshotsFrom(s, 8, spheres)
+	if err != nil || len(got) != 0 {
+		t.Errorf("got %d snapshots and error %v for missing planet, want none", len(got), err)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/willbeason/worldproc/pkg/render"
 	"github.com/willbeason/worldproc/pkg/sun"
 	"image"
-	"math"
 )
 
 func AddTerrain(p *Planet, sphere *geodesic.Geodesic, perlinNoise *noise.PerlinFractal) {
@@ -16,52 +15,33 @@ func AddTerrain(p *Planet, sphere *geodesic.Geodesic, perlinNoise *noise.PerlinF
 	}
 }
 
-func RenderTerrain(p *Planet, projection render.Projection, spheres []*geodesic.Geodesic, light sun.Light) *image.RGBA {
-	screen := projection.Screen
-	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
-
-	pxWaterHeights := make([]float64, screen.Width*screen.Height)
-	pxLandHeights := make([]float64, screen.Width*screen.Height)
-	pxLights := make([]float64, screen.Width*screen.Height)
-	pxSunlight := make([]geodesic.Angle, screen.Width*screen.Height)
-
-	heights := p.Heights
-	waters := p.Waters
-	flow := p.Flows
-
-	sphere := spheres[len(spheres)-1]
-	for x := 0; x < screen.Width; x++ {
-		for y := 0; y < screen.Height; y++ {
-			pidx := y*screen.Width + x
-			angle := projection.Pixels[pidx]
-			v := angle.Vector()
-			idx := geodesic.Find(spheres, v)
-			dist := math.Sqrt(geodesic.DistSq(v, sphere.Centers[idx]))
-
-			pxW1 := waters[idx] + flow[idx]/2000.0
-			pxH1 := heights[idx]
+// RenderTerrain paints the land and water of p on g with land colored by
+// land, lit by light as DefaultHillshade shades it.
+func RenderTerrain(p *Planet, g *geodesic.Geodesic, cells *render.CellMap, light sun.Light, land *render.ColorScale) *image.RGBA {
+	return RenderShadedTerrain(p, cells, DefaultHillshade.Shade(p, g, light), land)
+}
 
-			// Linearly interpolate the cell's stats with the second-closest cell.
-			idx2 := 0
-			distSq2 := math.MaxFloat64
-			for _, n := range sphere.Faces[idx].Neighbors {
-				nDistSq2 := geodesic.DistSq(v, sphere.Centers[n])
-				if nDistSq2 < distSq2 {
-					idx2 = n
-					distSq2 = nDistSq2
-				}
-			}
-			dist2 := math.Sqrt(distSq2)
-			pxW2 := waters[idx2] + flow[idx2]/2000.0
-			pxH2 := heights[idx2]
+// RenderShadedTerrain paints the land and water of p with land colored by
+// land, and each cell darkened by its shade from Hillshade.Shade. Shading is
+// the slowest part of rendering terrain, so when rendering many images of the
+// same planet in the same light find the shades once and reuse them.
+func RenderShadedTerrain(p *Planet, cells *render.CellMap, shades []float64, land *render.ColorScale) *image.RGBA {
+	screen := cells.Screen
+	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
 
-			pxWaterHeights[pidx] = render.Lerp(pxW1, pxW2, dist/(dist+dist2))
-			pxLandHeights[pidx] = render.Lerp(pxH1, pxH2, dist/(dist+dist2))
-			pxLights[pidx] = light.VisualIntensity(v)
-			pxSunlight[pidx] = light.AltitudeAzimuth(angle)
+	waters := make([]float64, len(p.Heights))
+	for i := range p.Waters {
+		waters[i] = p.Waters[i]
+		if len(p.Flows) > 0 {
+			waters[i] += p.Flows[i] / 2000.0
 		}
 	}
 
-	screen.PaintLandWater(pxLandHeights, pxWaterHeights, pxLights, pxSunlight, img)
+	pxWaterHeights := cells.Sample(waters)
+	pxLandHeights := cells.Sample(p.Heights)
+	pxShades := cells.Sample(shades)
+
+	screen.PaintLandWater(pxLandHeights, pxWaterHeights, pxShades, land, img)
+	cells.Mask(img)
 	return img
 }
@@ -14,7 +14,6 @@ const WaterQuanta = 0.01
 // coverage is the estimate of the planet's surface area to be covered with water.
 func AddWater(p *Planet, coverage float64, sphere *geodesic.Geodesic) {
 	p.Waters = make([]float64, len(p.Heights))
-	p.Flows = make([]float64, len(p.Heights))
 
 	sortedHeights := make([]float64, len(p.Heights))
 	copy(sortedHeights, p.Heights)
@@ -31,11 +30,19 @@ func AddWater(p *Planet, coverage float64, sphere *geodesic.Geodesic) {
 	}
 	avgWater := seaWater / float64(idx)
 
-	iters := int(avgWater / WaterQuanta)
-	for iter := 0; iter < iters; iter++ {
-		fmt.Println(iter, "...", "Raining")
-		water.Rain(WaterQuanta, p.Waters, p.Heights, p.Flows, sphere)
+	// Rain falls evenly in whole quanta, and runs down into the oceans and
+	// lakes it fills.
+	rain := float64(int(avgWater/WaterQuanta)) * WaterQuanta
+	for i := range p.Waters {
+		p.Waters[i] = rain
 	}
 	fmt.Println("... Equalizing")
 	water.Equalize(p.Waters, p.Heights, sphere)
+
+	fmt.Println("... Draining")
+	rains := make([]float64, len(p.Heights))
+	for i := range rains {
+		rains[i] = rain
+	}
+	p.Flows = Drainage(p, sphere).Accumulate(rains)
 }
@@ -0,0 +1,137 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FrameSink receives the frames of an animation in order.
+//
+// Frames are written out as they arrive rather than held until Close, so long
+// runs don't keep every frame in memory.
+type FrameSink interface {
+	WriteFrame(img image.Image) error
+	// Close finishes the animation. It doesn't close the writer the
+	// animation was written to unless the sink created it.
+	Close() error
+}
+
+// CreateAnimation creates a FrameSink writing to file, showing each frame for
+// delay. Files ending in .gif are written as animated GIFs and files ending in
+// .png or .apng as animated PNGs. Files whose names contain a verb such as
+// %03d are written as one PNG per frame, named by formatting file with the
+// index of the frame.
+func CreateAnimation(file string, delay time.Duration) (FrameSink, error) {
+	if strings.Contains(file, "%") {
+		return &PNGSequence{Pattern: file}, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(file))
+	switch ext {
+	case ".gif", ".png", ".apng":
+	default:
+		return nil, fmt.Errorf("unknown animation format %q", ext)
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	if ext == ".gif" {
+		return fileSink{FrameSink: NewGIF(out, delay), file: out}, nil
+	}
+	return fileSink{FrameSink: NewAPNG(out, delay), file: out}, nil
+}
+
+// fileSink closes the file a FrameSink writes to when the sink is closed.
+type fileSink struct {
+	FrameSink
+	file *os.File
+}
+
+func (s fileSink) Close() error {
+	err := s.FrameSink.Close()
+	if err != nil {
+		_ = s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// PNGSequence writes each frame to its own PNG file, named by formatting
+// Pattern with the index of the frame, as in "renders/tiling/frame-%03d.png".
+type PNGSequence struct {
+	Pattern string
+
+	n int
+}
+
+func (s *PNGSequence) WriteFrame(img image.Image) error {
+	out, err := os.Create(fmt.Sprintf(s.Pattern, s.n))
+	if err != nil {
+		return err
+	}
+	s.n++
+
+	err = png.Encode(out, img)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (s *PNGSequence) Close() error {
+	return nil
+}
+
+// Captioned writes a caption, such as the simulated date, in the bottom left
+// corner of each frame before passing it on to Sink. Frames passed to
+// WriteFrame are left as they are.
+type Captioned struct {
+	Sink FrameSink
+	// Text is the caption of the frames written after it is set.
+	Text string
+
+	// TextScale is how many pixels across each pixel of the font is, or 1 if
+	// zero.
+	TextScale int
+	// Color is the color of the text, and Background fills the area behind
+	// it. Background may be transparent.
+	Color, Background color.RGBA
+}
+
+func (c *Captioned) WriteFrame(img image.Image) error {
+	if c.Text == "" {
+		return c.Sink.WriteFrame(img)
+	}
+
+	bounds := img.Bounds()
+	captioned := image.NewRGBA(bounds)
+	draw.Draw(captioned, bounds, img, bounds.Min, draw.Src)
+
+	scale := c.TextScale
+	if scale == 0 {
+		scale = 1
+	}
+	width := TextWidth(c.Text, scale) + 2*legendPadding
+	height := GlyphHeight*scale + 2*legendPadding
+	area := image.Rect(bounds.Min.X, bounds.Max.Y-height, bounds.Min.X+width, bounds.Max.Y)
+	if c.Background.A != 0 {
+		draw.Draw(captioned, area, image.NewUniform(c.Background), image.Point{}, draw.Over)
+	}
+	DrawText(captioned, area.Min.X+legendPadding, area.Min.Y+legendPadding, scale, c.Text, c.Color)
+
+	return c.Sink.WriteFrame(captioned)
+}
+
+func (c *Captioned) Close() error {
+	return c.Sink.Close()
+}
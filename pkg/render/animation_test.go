@@ -0,0 +1,203 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/google/go-cmp/cmp"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testFrames returns n frames with a gradient moving across them and a
+// transparent corner.
+func testFrames(n int) []*image.RGBA {
+	result := make([]*image.RGBA, n)
+	for i := range result {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 40; x++ {
+				if x < 5 && y < 5 {
+					continue
+				}
+				img.SetRGBA(x, y, color.RGBA{R: uint8(6 * x), G: uint8(12 * y), B: uint8(50 * i), A: 255})
+			}
+		}
+		result[i] = img
+	}
+	return result
+}
+
+func TestQuantize(t *testing.T) {
+	img := testFrames(1)[0]
+
+	got := Quantize(img, 16)
+	if len(got.Palette) > 16 {
+		t.Errorf("got %d colors, want at most 16", len(got.Palette))
+	}
+	if _, _, _, a := got.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("got alpha %d for transparent pixel, want 0", a)
+	}
+	if _, _, _, a := got.At(20, 10).RGBA(); a != 0xFFFF {
+		t.Errorf("got alpha %d for opaque pixel, want %d", a, 0xFFFF)
+	}
+
+	// Images with few colors keep them exactly.
+	flat := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range flat.Pix {
+		flat.Pix[i] = 255
+	}
+	flat.SetRGBA(1, 1, color.RGBA{R: 8, G: 16, B: 248, A: 255})
+	got = Quantize(flat, 256)
+	if diff := cmp.Diff(color.RGBA{R: 8, G: 16, B: 248, A: 255}, got.At(1, 1)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGIF(t *testing.T) {
+	frames := testFrames(3)
+
+	buf := &bytes.Buffer{}
+	sink := NewGIF(buf, 50*time.Millisecond)
+	for _, frame := range frames {
+		err := sink.WriteFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := sink.WriteFrame(image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	if err == nil {
+		t.Error("got no error writing frame of a different size")
+	}
+	err = sink.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Image) != len(frames) {
+		t.Fatalf("got %d frames, want %d", len(got.Image), len(frames))
+	}
+	if diff := cmp.Diff([]int{5, 5, 5}, got.Delay); diff != "" {
+		t.Error(diff)
+	}
+	if got.LoopCount != 0 {
+		t.Errorf("got loop count %d, want 0", got.LoopCount)
+	}
+	for i, img := range got.Image {
+		if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+			t.Errorf("frame %d: got alpha %d for transparent pixel, want 0", i, a)
+		}
+	}
+}
+
+func TestAPNG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	frames := testFrames(3)
+	file := filepath.Join(dir, "frames.png")
+	sink, err := CreateAnimation(file, 40*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range frames {
+		err = sink.WriteFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = sink.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoders without animation see the first frame.
+	first, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(frames[0].Pix, first.(*image.NRGBA).Pix); diff != "" {
+		t.Error(diff)
+	}
+
+	chunks := make(map[string]int)
+	var numFrames uint32
+	for i := len(pngSignature); i < len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		name := string(data[i+4 : i+8])
+		chunks[name]++
+		if name == "acTL" {
+			numFrames = binary.BigEndian.Uint32(data[i+8:])
+		}
+		i += 12 + length
+	}
+	want := map[string]int{"IHDR": 1, "acTL": 1, "fcTL": 3, "IDAT": 1, "fdAT": 2, "IEND": 1}
+	if diff := cmp.Diff(want, chunks); diff != "" {
+		t.Error(diff)
+	}
+	if numFrames != 3 {
+		t.Errorf("got %d frames in animation control, want 3", numFrames)
+	}
+}
+
+func TestCaptioned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := CreateAnimation(filepath.Join(dir, "frame-%d.png"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	captioned := &Captioned{Sink: sink, Text: "1", Color: white}
+	frame := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	err = captioned.WriteFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = captioned.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(make([]uint8, len(frame.Pix)), frame.Pix); diff != "" {
+		t.Error("caption was drawn on the frame passed in:", diff)
+	}
+
+	in, err := os.Open(filepath.Join(dir, "frame-0.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	got, err := png.Decode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The top of the stem of the 1 is in the middle of the top row of its
+	// glyph.
+	x, y := legendPadding+2, 20-legendPadding-GlyphHeight
+	if r, _, _, _ := got.At(x, y).RGBA(); r != 0xFFFF {
+		t.Errorf("got red %d at %d,%d, want %d", r, x, y, 0xFFFF)
+	}
+}
@@ -0,0 +1,254 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+	"time"
+)
+
+// APNG writes frames as an animated PNG which loops forever, keeping every
+// color and level of transparency. Viewers without support for animation
+// show the first frame.
+//
+// The number of frames is recorded before the frames themselves, so APNG
+// goes back to fill it in when closed.
+type APNG struct {
+	w     io.WriteSeeker
+	delay time.Duration
+
+	width, height int
+	// frames is how many frames have been written and sequence is the number
+	// of the next frame control or frame data chunk.
+	frames, sequence uint32
+	// actl is where the animation control chunk starts.
+	actl int64
+	err  error
+}
+
+// NewAPNG returns an APNG writing to w which shows each frame for delay,
+// rounded to milliseconds.
+func NewAPNG(w io.WriteSeeker, delay time.Duration) *APNG {
+	return &APNG{w: w, delay: delay}
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+func (a *APNG) WriteFrame(img image.Image) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	bounds := img.Bounds()
+	if a.frames == 0 {
+		a.width, a.height = bounds.Dx(), bounds.Dy()
+		a.header()
+	} else if bounds.Dx() != a.width || bounds.Dy() != a.height {
+		return fmt.Errorf("got %d by %d frame, want %d by %d", bounds.Dx(), bounds.Dy(), a.width, a.height)
+	}
+
+	data, err := pngImageData(img)
+	if err != nil {
+		return err
+	}
+
+	ms := a.delay.Round(time.Millisecond) / time.Millisecond
+	if ms > 0xFFFF {
+		ms = 0xFFFF
+	}
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], a.sequence)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(a.width))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(a.height))
+	// The frame covers the whole image, so its offset is zero.
+	binary.BigEndian.PutUint16(fctl[20:], uint16(ms))
+	binary.BigEndian.PutUint16(fctl[22:], 1000)
+	// Leave the frame as it is when done, and replace the last frame with
+	// it rather than drawing over it.
+	fctl[24], fctl[25] = 0, 0
+	a.sequence++
+	a.chunk("fcTL", fctl)
+
+	// The first frame is the image viewers without animation show.
+	if a.frames == 0 {
+		a.chunk("IDAT", data)
+	} else {
+		fdat := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, a.sequence)
+		a.sequence++
+		a.chunk("fdAT", append(fdat, data...))
+	}
+	a.frames++
+	return a.err
+}
+
+func (a *APNG) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.frames == 0 {
+		return errors.New("APNG has no frames")
+	}
+	a.chunk("IEND", nil)
+
+	end, err := a.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = a.w.Seek(a.actl, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	a.animationControl()
+	if a.err != nil {
+		return a.err
+	}
+	_, err = a.w.Seek(end, io.SeekStart)
+	return err
+}
+
+// header writes the PNG signature, the image header and an animation control
+// chunk to be filled in when the number of frames is known.
+func (a *APNG) header() {
+	if a.err != nil {
+		return
+	}
+	a.actl, a.err = a.w.Seek(0, io.SeekCurrent)
+	if a.err != nil {
+		return
+	}
+	_, a.err = a.w.Write(pngSignature)
+	a.actl += int64(len(pngSignature))
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(a.width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(a.height))
+	// 8 bits per channel of non-premultiplied RGBA, with the default
+	// compression, filtering and no interlacing.
+	ihdr[8], ihdr[9] = 8, 6
+	a.chunk("IHDR", ihdr)
+	a.actl += 12 + int64(len(ihdr))
+
+	a.animationControl()
+}
+
+// animationControl writes the number of frames written so far and that the
+// animation loops forever.
+func (a *APNG) animationControl() {
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], a.frames)
+	a.chunk("acTL", actl)
+}
+
+// chunk writes a PNG chunk of type name holding data.
+func (a *APNG) chunk(name string, data []byte) {
+	if a.err != nil {
+		return
+	}
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header[4:])
+	_, _ = crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		_, a.err = a.w.Write(b)
+		if a.err != nil {
+			return
+		}
+	}
+}
+
+// pngImageData returns the compressed rows of img as 8 bit RGBA, each
+// filtered with whichever PNG filter leaves the smallest differences.
+func pngImageData(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width := 4 * bounds.Dx()
+
+	previous := make([]byte, width)
+	current := make([]byte, width)
+	filtered := make([][]byte, 5)
+	for i := range filtered {
+		filtered[i] = make([]byte, 1+width)
+		filtered[i][0] = byte(i)
+	}
+
+	buf := &bytes.Buffer{}
+	z := zlib.NewWriter(buf)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			i := 4 * (x - bounds.Min.X)
+			current[i], current[i+1], current[i+2], current[i+3] = c.R, c.G, c.B, c.A
+		}
+
+		best, bestSum := 0, -1
+		for filter, row := range filtered {
+			sum := 0
+			for i, c := range current {
+				var left, upLeft byte
+				if i >= 4 {
+					left, upLeft = current[i-4], previous[i-4]
+				}
+				up := previous[i]
+
+				var d byte
+				switch filter {
+				case 0:
+					d = c
+				case 1:
+					d = c - left
+				case 2:
+					d = c - up
+				case 3:
+					d = c - byte((int(left)+int(up))/2)
+				case 4:
+					d = c - paeth(left, up, upLeft)
+				}
+				row[1+i] = d
+				// Treat differences as signed, as small negative ones are
+				// as compressible as small positive ones.
+				sum += abs(int(int8(d)))
+			}
+			if bestSum < 0 || sum < bestSum {
+				best, bestSum = filter, sum
+			}
+		}
+
+		_, err := z.Write(filtered[best])
+		if err != nil {
+			return nil, err
+		}
+		previous, current = current, previous
+	}
+
+	err := z.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// paeth returns whichever of a, b and c is closest to a+b-c, as the PNG
+// Paeth filter predicts each byte.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
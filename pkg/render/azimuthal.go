@@ -0,0 +1,90 @@
+package render
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+)
+
+// Orthographic shows the sphere as a globe seen from far away, centered on
+// Center. Rotation turns the globe counter-clockwise about its center, in
+// radians; with no rotation north is toward +y. Its natural aspect ratio is
+// 1:1.
+type Orthographic struct {
+	Center   geodesic.Angle
+	Rotation float64
+}
+
+var _ Projector = Orthographic{}
+
+func (o Orthographic) Project(x, y float64) (geodesic.Angle, bool) {
+	// The globe fills the rectangle.
+	u, v := 2*x, 2*y
+	r2 := u*u + v*v
+	if r2 > 1 {
+		return geodesic.Angle{}, false
+	}
+
+	c, e, n := frame(o.Center, o.Rotation)
+	p := c.Scale(math.Sqrt(1 - r2)).Add(e.Scale(u)).Add(n.Scale(v))
+	return p.Angle(), true
+}
+
+func (o Orthographic) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	c, e, n := frame(o.Center, o.Rotation)
+	p := a.Vector()
+	// Allow for rounding at the edge of the globe.
+	if p.Dot(c) < -1e-12 {
+		return 0, 0, false
+	}
+	return p.Dot(e) / 2, p.Dot(n) / 2, true
+}
+
+// AzimuthalEquidistant shows the whole sphere as a disk centered on Center,
+// where the distance from the center of the disk is proportional to the
+// distance from Center. Centered on a pole it is the usual polar view, and
+// the opposite pole is the edge of the disk. Rotation is as for Orthographic.
+// Its natural aspect ratio is 1:1.
+type AzimuthalEquidistant struct {
+	Center   geodesic.Angle
+	Rotation float64
+}
+
+var _ Projector = AzimuthalEquidistant{}
+
+func (az AzimuthalEquidistant) Project(x, y float64) (geodesic.Angle, bool) {
+	u, v := 2*math.Pi*x, 2*math.Pi*y
+	r := math.Sqrt(u*u + v*v)
+	if r > math.Pi {
+		return geodesic.Angle{}, false
+	}
+
+	c, e, n := frame(az.Center, az.Rotation)
+	if r == 0 {
+		return c.Angle(), true
+	}
+	p := c.Scale(math.Cos(r)).Add(e.Scale(math.Sin(r) * u / r)).Add(n.Scale(math.Sin(r) * v / r))
+	return p.Angle(), true
+}
+
+func (az AzimuthalEquidistant) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	c, e, n := frame(az.Center, az.Rotation)
+	p := a.Vector()
+	r := math.Acos(math.Max(-1, math.Min(1, p.Dot(c))))
+	direction := math.Atan2(p.Dot(n), p.Dot(e))
+	scale := r / (2 * math.Pi)
+	return scale * math.Cos(direction), scale * math.Sin(direction), true
+}
+
+// frame returns the unit vector toward center and the directions on the
+// sphere there which are right and up on the screen once turned
+// counter-clockwise by rotation.
+func frame(center geodesic.Angle, rotation float64) (c, right, up geodesic.Vector) {
+	c = center.Vector()
+	east := geodesic.Vector{X: -math.Sin(center.Phi), Y: math.Cos(center.Phi)}
+	north := c.Cross(east)
+
+	cos, sin := math.Cos(rotation), math.Sin(rotation)
+	right = east.Scale(cos).Sub(north.Scale(sin))
+	up = east.Scale(sin).Add(north.Scale(cos))
+	return c, right, up
+}
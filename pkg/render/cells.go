@@ -0,0 +1,167 @@
+package render
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"runtime"
+	"sync"
+)
+
+// CellMap records the cells of a geodesic sphere each pixel of a Projection
+// falls in. Finding cells is by far the most expensive part of rendering, so
+// a CellMap should be built once and reused for every frame.
+type CellMap struct {
+	Projection
+
+	// Cells is the closest cell to each pixel.
+	Cells []int
+	// Points is the triangle of cells around each pixel, used to interpolate
+	// between cells. Pixels which aren't Visible have all zero weights, so
+	// they sample as zero.
+	Points []geodesic.Barycentric
+}
+
+// NewCellMap finds the cells of sphere for every pixel in projection.
+func NewCellMap(projection Projection, sphere *geodesic.Geodesic) *CellMap {
+	return NewCellMapFromSampler(projection, geodesic.NewSampler(sphere))
+}
+
+// NewCellMapFromSampler finds the cells of sampler's sphere for every pixel in
+// projection. Building a Sampler is expensive, so share one when making
+// CellMaps for many projections of the same sphere.
+func NewCellMapFromSampler(projection Projection, sampler *geodesic.Sampler) *CellMap {
+	nPixels := len(projection.Pixels)
+	result := &CellMap{
+		Projection: projection,
+		Cells:      make([]int, nPixels),
+		Points:     make([]geodesic.Barycentric, nPixels),
+	}
+
+	parallelRows(projection.Height, func(y int) {
+		for pidx := y * projection.Width; pidx < (y+1)*projection.Width; pidx++ {
+			if !projection.Visible[pidx] {
+				continue
+			}
+			v := projection.Pixels[pidx].Vector()
+			idx := sampler.Find(v)
+			result.Cells[pidx] = idx
+			result.Points[pidx] = sampler.LocateFrom(idx, v)
+		}
+	})
+
+	return result
+}
+
+// CellMaps keeps the CellMap of a sphere for every projection it has been
+// asked for, so rendering a projection again, as for each frame of an
+// animation, finds no cells. It is safe for concurrent use.
+type CellMaps struct {
+	sampler *geodesic.Sampler
+
+	mu   sync.Mutex
+	maps map[cellMapKey]*cellMapEntry
+}
+
+// cellMapEntry is a CellMap which is built once, by whichever Get asks for it
+// first, while other Gets for it wait.
+type cellMapEntry struct {
+	once  sync.Once
+	cells *CellMap
+}
+
+type cellMapKey struct {
+	screen    Screen
+	projector Projector
+}
+
+// NewCellMaps returns an empty cache of the CellMaps of sphere.
+func NewCellMaps(sphere *geodesic.Geodesic) *CellMaps {
+	return &CellMaps{
+		sampler: geodesic.NewSampler(sphere),
+		maps:    make(map[cellMapKey]*cellMapEntry),
+	}
+}
+
+// Get returns the CellMap of screen projected with projector, building it the
+// first time it is asked for. projector must be comparable, as every
+// Projector of this package is.
+//
+// Only Gets for the same CellMap wait while it is built.
+func (c *CellMaps) Get(screen Screen, projector Projector) *CellMap {
+	key := cellMapKey{screen: screen, projector: projector}
+
+	c.mu.Lock()
+	entry, found := c.maps[key]
+	if !found {
+		entry = &cellMapEntry{}
+		c.maps[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.cells = NewCellMapFromSampler(Project(screen, projector), c.sampler)
+	})
+	return entry.cells
+}
+
+// Len returns how many CellMaps c holds.
+func (c *CellMaps) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.maps)
+}
+
+// Sample returns the value of the per-cell values at every pixel.
+func (m *CellMap) Sample(values []float64) []float64 {
+	result := make([]float64, len(m.Points))
+	parallelRows(m.Height, func(y int) {
+		for pidx := y * m.Width; pidx < (y+1)*m.Width; pidx++ {
+			result[pidx] = m.Points[pidx].Scalar(values)
+		}
+	})
+	return result
+}
+
+// Borders returns whether each pixel of the screen is next to a pixel of a
+// different cell, given the cell of every pixel. The cells need not be those
+// of a CellMap.
+func (s Screen) Borders(cells []int) []bool {
+	result := make([]bool, len(cells))
+	for pidx, cell := range cells {
+		x, y := pidx%s.Width, pidx/s.Width
+		switch {
+		case x > 0 && cells[pidx-1] != cell,
+			x < s.Width-1 && cells[pidx+1] != cell,
+			y > 0 && cells[pidx-s.Width] != cell,
+			y < s.Height-1 && cells[pidx+s.Width] != cell:
+			result[pidx] = true
+		}
+	}
+	return result
+}
+
+// Borders returns whether each pixel is next to a pixel of a different cell.
+func (m *CellMap) Borders() []bool {
+	return m.Screen.Borders(m.Cells)
+}
+
+// parallelRows calls fn for every row from 0 to height, splitting rows
+// between one worker per CPU.
+func parallelRows(height int, fn func(y int)) {
+	rows := make(chan int, height)
+	for y := 0; y < height; y++ {
+		rows <- y
+	}
+	close(rows)
+
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < runtime.NumCPU(); worker++ {
+		wg.Add(1)
+		go func() {
+			for y := range rows {
+				fn(y)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,85 @@
+package render
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"sync"
+	"testing"
+)
+
+func TestNewCellMap(t *testing.T) {
+	sphere := geodesic.Chamfer(geodesic.Dodecahedron())
+
+	projection := Project(Screen{Width: 64, Height: 32}, Equirectangular{})
+	cells := NewCellMap(projection, sphere)
+
+	for pidx, angle := range projection.Pixels {
+		want := geodesic.NaiveFind(sphere, angle.Vector())
+		if cells.Cells[pidx] != want {
+			t.Fatalf("got Cells[%d] = %d, want %d", pidx, cells.Cells[pidx], want)
+		}
+		for _, w := range cells.Points[pidx].Weights {
+			if w < 0 || w > 1 {
+				t.Fatalf("got Points[%d].Weights = %v, want between 0 and 1", pidx, cells.Points[pidx].Weights)
+			}
+		}
+	}
+
+	values := make([]float64, len(sphere.Centers))
+	for i := range values {
+		values[i] = 2.0
+	}
+	want := make([]float64, len(projection.Pixels))
+	for i := range want {
+		want[i] = 2.0
+	}
+	if diff := cmp.Diff(want, cells.Sample(values), cmpopts.EquateApprox(0.0, 1e-9)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCellMaps_Get(t *testing.T) {
+	maps := NewCellMaps(geodesic.Chamfer(geodesic.Dodecahedron()))
+	screen := Screen{Width: 32, Height: 16}
+
+	first := maps.Get(screen, Equirectangular{})
+	if again := maps.Get(screen, Equirectangular{}); again != first {
+		t.Error("got a new CellMap for the same projection, want the cached one")
+	}
+	if other := maps.Get(screen, Orthographic{}); other == first {
+		t.Error("got the same CellMap for a different projector")
+	}
+	if other := maps.Get(Screen{Width: 16, Height: 16}, Equirectangular{}); other == first {
+		t.Error("got the same CellMap for a different screen")
+	}
+	if got := maps.Len(); got != 3 {
+		t.Errorf("got %d CellMaps, want 3", got)
+	}
+}
+
+func TestCellMaps_GetConcurrent(t *testing.T) {
+	maps := NewCellMaps(geodesic.Chamfer(geodesic.Dodecahedron()))
+	screens := []Screen{{Width: 32, Height: 16}, {Width: 64, Height: 32}}
+
+	got := make([]*CellMap, 8)
+	wg := sync.WaitGroup{}
+	wg.Add(len(got))
+	for i := range got {
+		i := i
+		go func() {
+			defer wg.Done()
+			got[i] = maps.Get(screens[i%len(screens)], Equirectangular{})
+		}()
+	}
+	wg.Wait()
+
+	for i, cells := range got {
+		if cells != got[i%len(screens)] {
+			t.Errorf("Get %d built its own CellMap, want the one shared by every Get of its screen", i)
+		}
+		if want := screens[i%len(screens)]; cells.Width != want.Width {
+			t.Errorf("Get %d got CellMap %d wide, want %d", i, cells.Width, want.Width)
+		}
+	}
+}
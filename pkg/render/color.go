@@ -2,6 +2,7 @@ package render
 
 import (
 	"image/color"
+	"math"
 )
 
 type ColorPoint struct {
@@ -12,8 +13,27 @@ type ColorPoint struct {
 type ColorScale struct {
 	Thresholds []float64
 	Colors []color.RGBA
+
+	// Interpolation is how colors between thresholds are found. The zero
+	// value interpolates RGB values directly.
+	Interpolation Interpolation
 }
 
+// Interpolation is a color space to interpolate between colors in.
+type Interpolation string
+
+const (
+	// RGB interpolates the sRGB values of colors, which is fast but makes
+	// the midpoints of very different colors dull and dark.
+	RGB Interpolation = "rgb"
+	// Lab interpolates in CIELAB, in which equal distances are about
+	// equally different to the eye.
+	Lab Interpolation = "lab"
+	// OKLab interpolates in OKLab, which is like CIELAB but keeps hues
+	// steadier, particularly for blues.
+	OKLab Interpolation = "oklab"
+)
+
 func NewColorScale(ps []ColorPoint) *ColorScale {
 	result := &ColorScale{
 		Thresholds: make([]float64, len(ps)),
@@ -41,6 +61,25 @@ func lerpC(left, right color.RGBA, w float64) color.RGBA {
 	}
 }
 
+// Rescale returns a copy of s with its thresholds stretched to run from min to
+// max, as for applying a palette defined from 0 to 1 to a range of values.
+func (s ColorScale) Rescale(min, max float64) *ColorScale {
+	result := &ColorScale{
+		Thresholds:    make([]float64, len(s.Thresholds)),
+		Colors:        append([]color.RGBA{}, s.Colors...),
+		Interpolation: s.Interpolation,
+	}
+	first, last := s.Thresholds[0], s.Thresholds[len(s.Thresholds)-1]
+	for i, t := range s.Thresholds {
+		w := 0.0
+		if last > first {
+			w = (t - first) / (last - first)
+		}
+		result.Thresholds[i] = Lerp(min, max, w)
+	}
+	return result
+}
+
 func (s ColorScale) ColorAt(f float64) color.RGBA {
 	if f < s.Thresholds[0] {
 		// We're before the first threshold, so use the first color.
@@ -53,10 +92,130 @@ func (s ColorScale) ColorAt(f float64) color.RGBA {
 			// Linearly interpolate between the threshold colors.
 			leftT := s.Thresholds[rightIdx-1]
 			p := (f - leftT) / (rightT - leftT)
-			return lerpC(s.Colors[rightIdx-1], s.Colors[rightIdx], p)
+			return s.Interpolation.lerp(s.Colors[rightIdx-1], s.Colors[rightIdx], p)
 		}
 	}
 
 	// We're above the highest threshold, so use the last color.
 	return s.Colors[len(s.Colors)-1]
 }
+
+// NearestColor returns the color of the threshold of s closest to f without
+// interpolating, as for painting categories.
+func (s ColorScale) NearestColor(f float64) color.RGBA {
+	nearest := 0
+	for i, t := range s.Thresholds {
+		if math.Abs(f-t) < math.Abs(f-s.Thresholds[nearest]) {
+			nearest = i
+		}
+	}
+	return s.Colors[nearest]
+}
+
+func (in Interpolation) lerp(left, right color.RGBA, w float64) color.RGBA {
+	var toSpace, fromSpace func(r, g, b float64) (float64, float64, float64)
+	switch in {
+	case Lab:
+		toSpace, fromSpace = linearToLab, labToLinear
+	case OKLab:
+		toSpace, fromSpace = linearToOKLab, okLabToLinear
+	default:
+		return lerpC(left, right, w)
+	}
+
+	l0, a0, b0 := toSpace(linearRGB(left))
+	l1, a1, b1 := toSpace(linearRGB(right))
+	r, g, b := fromSpace(Lerp(l0, l1, w), Lerp(a0, a1, w), Lerp(b0, b1, w))
+	return color.RGBA{
+		R: encodeSRGB(r),
+		G: encodeSRGB(g),
+		B: encodeSRGB(b),
+		A: uint8(math.Round(Lerp(float64(left.A), float64(right.A), w))),
+	}
+}
+
+// linearRGB returns the linear light intensities of the channels of c.
+func linearRGB(c color.RGBA) (r, g, b float64) {
+	return decodeSRGB(c.R), decodeSRGB(c.G), decodeSRGB(c.B)
+}
+
+func decodeSRGB(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func encodeSRGB(c float64) uint8 {
+	c = math.Max(0, math.Min(1, c))
+	if c <= 0.0031308 {
+		c *= 12.92
+	} else {
+		c = 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(c * 255))
+}
+
+// linearToOKLab and okLabToLinear convert between linear sRGB and OKLab, as
+// defined by Björn Ottosson.
+func linearToOKLab(r, g, b float64) (float64, float64, float64) {
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s
+}
+
+func okLabToLinear(lightness, a, b float64) (float64, float64, float64) {
+	l := lightness + 0.3963377774*a + 0.2158037573*b
+	m := lightness - 0.1055613458*a - 0.0638541728*b
+	s := lightness - 0.0894841775*a - 1.2914855480*b
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return 4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s
+}
+
+// The D65 white point, and the constant CIELAB switches from a cube root to a
+// line below.
+const (
+	whiteX   = 0.95047
+	whiteZ   = 1.08883
+	labDelta = 6.0 / 29
+)
+
+// linearToLab and labToLinear convert between linear sRGB and CIELAB with a
+// D65 white point.
+func linearToLab(r, g, b float64) (float64, float64, float64) {
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / whiteX
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / whiteZ
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+func labToLinear(lightness, a, b float64) (float64, float64, float64) {
+	fy := (lightness + 16) / 116
+	x := whiteX * labFInverse(fy+a/500)
+	y := labFInverse(fy)
+	z := whiteZ * labFInverse(fy-b/200)
+	return 3.2404542*x - 1.5371385*y - 0.4985314*z,
+		-0.9692660*x + 1.8760108*y + 0.0415560*z,
+		0.0556434*x - 0.2040259*y + 1.0572252*z
+}
+
+func labF(t float64) float64 {
+	if t > labDelta*labDelta*labDelta {
+		return math.Cbrt(t)
+	}
+	return t/(3*labDelta*labDelta) + 4.0/29
+}
+
+func labFInverse(t float64) float64 {
+	if t > labDelta {
+		return t * t * t
+	}
+	return 3 * labDelta * labDelta * (t - 4.0/29)
+}
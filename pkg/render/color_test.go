@@ -56,3 +56,28 @@ func TestColorScale_Grays(t *testing.T) {
 		}
 	}
 }
+
+func TestColorScale_NearestColor(t *testing.T) {
+	red, green, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}
+	cs := NewColorScale([]ColorPoint{{0.0, red}, {1.0, green}, {2.0, blue}})
+
+	tcs := []struct{
+		p float64
+		want color.RGBA
+	} {
+		{p: -1.0, want: red},
+		{p: 0.0, want: red},
+		{p: 0.4, want: red},
+		{p: 1.0, want: green},
+		{p: 1.6, want: blue},
+		{p: 3.0, want: blue},
+	}
+
+	for _, tc := range tcs {
+		got := cs.NearestColor(tc.p)
+
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Error(tc.p, diff)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package render
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+	"sync"
+)
+
+// Dymaxion unfolds the icosahedron whose vertices are the faces of
+// geodesic.Dodecahedron onto a flat net of its 20 triangles, as Fuller's
+// Dymaxion map does. Each triangle of the sphere is projected onto the
+// matching triangle of the net from the center of the sphere, so the base
+// cells of every geodesic sphere appear as the corners of the net.
+//
+// The net is the usual one for the icosahedron: a strip of ten triangles
+// along the equator with five above reaching to the north pole and five below
+// reaching to the south. It is cut near the meridian opposite the Prime
+// Meridian, so the Prime Meridian runs down its middle. Its natural aspect
+// ratio is 11:3*Sqrt3, about 2.12:1.
+type Dymaxion struct{}
+
+var _ Projector = Dymaxion{}
+
+// netTriangle is a triangle of the icosahedron and where it is on the net.
+type netTriangle struct {
+	// faces are the faces of the dodecahedron at the corners of the triangle.
+	faces [3]int
+	// corners are the x,y coordinates of the corners on the net.
+	corners [3][2]float64
+}
+
+// netWidth is the width of the net in lengths of the sides of its triangles.
+const netWidth = 5.5
+
+var (
+	// netHeight is the height of each triangle of the net.
+	netHeight = math.Sqrt(3) / 2
+
+	netOnce      sync.Once
+	netTriangles []netTriangle
+	netCenters   []geodesic.Vector
+)
+
+// dymaxionNet lays out the triangles of the icosahedron on the net.
+func dymaxionNet() ([]netTriangle, []geodesic.Vector) {
+	netOnce.Do(func() {
+		netCenters = geodesic.Dodecahedron().Centers
+
+		// Faces around the northern and southern rings of the dodecahedron in
+		// order of increasing longitude, starting west of the meridian
+		// opposite the Prime Meridian. Each face of the southern ring is
+		// halfway between two faces of the northern ring.
+		north, south := 0, 11
+		upper := [5]int{5, 4, 3, 2, 1}
+		lower := [5]int{9, 8, 7, 6, 10}
+
+		for i := 0; i < 5; i++ {
+			u0, u1 := upper[i], upper[(i+1)%5]
+			l0, l1 := lower[i], lower[(i+1)%5]
+			x := float64(i)
+			netTriangles = append(netTriangles,
+				netTriangle{
+					faces:   [3]int{north, u0, u1},
+					corners: [3][2]float64{{x + 0.5, 2 * netHeight}, {x, netHeight}, {x + 1, netHeight}},
+				},
+				netTriangle{
+					faces:   [3]int{u0, l0, u1},
+					corners: [3][2]float64{{x, netHeight}, {x + 0.5, 0}, {x + 1, netHeight}},
+				},
+				netTriangle{
+					faces:   [3]int{l0, l1, u1},
+					corners: [3][2]float64{{x + 0.5, 0}, {x + 1.5, 0}, {x + 1, netHeight}},
+				},
+				netTriangle{
+					faces:   [3]int{l0, south, l1},
+					corners: [3][2]float64{{x + 0.5, 0}, {x + 1, -netHeight}, {x + 1.5, 0}},
+				},
+			)
+		}
+	})
+	return netTriangles, netCenters
+}
+
+func (Dymaxion) Project(x, y float64) (geodesic.Angle, bool) {
+	triangles, centers := dymaxionNet()
+
+	// The net spans 0 to netWidth across and -netHeight to 2*netHeight up.
+	u := netWidth/2 + netWidth*x
+	v := netHeight/2 + 3*netHeight*y
+
+	for _, t := range triangles {
+		w, ok := planarWeights(t.corners, u, v)
+		if !ok {
+			continue
+		}
+		p := geodesic.Vector{}
+		for k, f := range t.faces {
+			p = p.Add(centers[f].Scale(w[k]))
+		}
+		return p.Angle(), true
+	}
+	return geodesic.Angle{}, false
+}
+
+func (Dymaxion) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	triangles, centers := dymaxionNet()
+	p := a.Vector()
+
+	for _, t := range triangles {
+		w, ok := sphericalWeights(centers, t.faces, p)
+		if !ok {
+			continue
+		}
+		u, v := 0.0, 0.0
+		for k, c := range t.corners {
+			u += w[k] * c[0]
+			v += w[k] * c[1]
+		}
+		return (u - netWidth/2) / netWidth, (v - netHeight/2) / (3 * netHeight), true
+	}
+	// Every direction is in some triangle, but rounding may leave points on
+	// an edge in neither.
+	return 0, 0, false
+}
+
+// netTolerance is how far outside a triangle a point may be and still count
+// as inside, so points on edges are in at least one triangle.
+const netTolerance = 1e-12
+
+// planarWeights returns the barycentric weights of u,v in the triangle with
+// corners, and whether u,v is inside it.
+func planarWeights(corners [3][2]float64, u, v float64) ([3]float64, bool) {
+	a, b, c := corners[0], corners[1], corners[2]
+	det := (b[0]-a[0])*(c[1]-a[1]) - (c[0]-a[0])*(b[1]-a[1])
+	wb := ((u-a[0])*(c[1]-a[1]) - (c[0]-a[0])*(v-a[1])) / det
+	wc := ((b[0]-a[0])*(v-a[1]) - (u-a[0])*(b[1]-a[1])) / det
+	w := [3]float64{1 - wb - wc, wb, wc}
+	return w, w[0] >= -netTolerance && w[1] >= -netTolerance && w[2] >= -netTolerance
+}
+
+// sphericalWeights returns the weights of the corners of the triangle of
+// faces which sum to one and whose combination is in the direction of p, and
+// whether p is inside the triangle.
+func sphericalWeights(centers []geodesic.Vector, faces [3]int, p geodesic.Vector) ([3]float64, bool) {
+	a, b, c := centers[faces[0]], centers[faces[1]], centers[faces[2]]
+	// Each weight is the volume of the tetrahedron with the origin, p and the
+	// other two corners.
+	w := [3]float64{
+		p.Dot(b.Cross(c)),
+		p.Dot(c.Cross(a)),
+		p.Dot(a.Cross(b)),
+	}
+	sum := w[0] + w[1] + w[2]
+	if sum <= 0 {
+		return w, false
+	}
+	for k := range w {
+		w[k] /= sum
+	}
+	return w, w[0] >= -netTolerance && w[1] >= -netTolerance && w[2] >= -netTolerance
+}
@@ -0,0 +1,377 @@
+package render
+
+import (
+	"bufio"
+	"compress/lzw"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// GIF writes frames as an animated GIF which loops forever.
+//
+// GIFs have at most 256 colors per frame, so each frame is reduced to its own
+// palette with Quantize and dithered.
+type GIF struct {
+	w     *bufio.Writer
+	delay int
+
+	width, height int
+	frames        int
+	err           error
+}
+
+// NewGIF returns a GIF writing to w which shows each frame for delay, rounded
+// to hundredths of a second.
+func NewGIF(w io.Writer, delay time.Duration) *GIF {
+	return &GIF{
+		w:     bufio.NewWriter(w),
+		delay: int(delay.Round(10*time.Millisecond) / (10 * time.Millisecond)),
+	}
+}
+
+func (g *GIF) WriteFrame(img image.Image) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	bounds := img.Bounds()
+	if g.frames == 0 {
+		g.width, g.height = bounds.Dx(), bounds.Dy()
+		if g.width > 0xFFFF || g.height > 0xFFFF {
+			return fmt.Errorf("%d by %d image is too large for GIF", g.width, g.height)
+		}
+		g.header()
+	} else if bounds.Dx() != g.width || bounds.Dy() != g.height {
+		return fmt.Errorf("got %d by %d frame, want %d by %d", bounds.Dx(), bounds.Dy(), g.width, g.height)
+	}
+
+	g.frame(Quantize(img, 256))
+	g.frames++
+	return g.err
+}
+
+func (g *GIF) Close() error {
+	if g.err != nil {
+		return g.err
+	}
+	if g.frames == 0 {
+		return errors.New("GIF has no frames")
+	}
+	g.write([]byte{0x3B})
+	if g.err != nil {
+		return g.err
+	}
+	return g.w.Flush()
+}
+
+func (g *GIF) write(b []byte) {
+	if g.err != nil {
+		return
+	}
+	_, g.err = g.w.Write(b)
+}
+
+// header writes the GIF header, the logical screen descriptor without a
+// global color table, and the extension which makes the animation loop.
+func (g *GIF) header() {
+	g.write([]byte("GIF89a"))
+	g.write([]byte{
+		byte(g.width), byte(g.width >> 8),
+		byte(g.height), byte(g.height >> 8),
+		0, 0, 0,
+	})
+	g.write([]byte{0x21, 0xFF, 0x0B})
+	g.write([]byte("NETSCAPE2.0"))
+	// Loop forever.
+	g.write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+// frame writes img with its palette as the local color table.
+func (g *GIF) frame(img *image.Paletted) {
+	transparent := -1
+	for i, c := range img.Palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent = i
+			break
+		}
+	}
+
+	// Graphic control extension. Frames with transparent pixels clear the
+	// last frame rather than showing it through them.
+	flags, index := byte(0x04), byte(0)
+	if transparent >= 0 {
+		flags, index = 0x08|0x01, byte(transparent)
+	}
+	g.write([]byte{0x21, 0xF9, 0x04, flags, byte(g.delay), byte(g.delay >> 8), index, 0x00})
+
+	// Color tables have a power of two entries, and LZW codes at least two
+	// bits.
+	depth := bits.Len(uint(len(img.Palette) - 1))
+	if depth < 1 {
+		depth = 1
+	}
+	g.write([]byte{
+		0x2C,
+		0, 0, 0, 0,
+		byte(g.width), byte(g.width >> 8),
+		byte(g.height), byte(g.height >> 8),
+		0x80 | byte(depth-1),
+	})
+	table := make([]byte, 3<<depth)
+	for i, c := range img.Palette {
+		rgba := color.RGBAModel.Convert(c).(color.RGBA)
+		table[3*i], table[3*i+1], table[3*i+2] = rgba.R, rgba.G, rgba.B
+	}
+	g.write(table)
+
+	litWidth := depth
+	if litWidth < 2 {
+		litWidth = 2
+	}
+	g.write([]byte{byte(litWidth)})
+	if g.err != nil {
+		return
+	}
+
+	blocks := &blockWriter{w: g.w}
+	lzwWriter := lzw.NewWriter(blocks, lzw.LSB, litWidth)
+	for y := 0; y < g.height; y++ {
+		_, err := lzwWriter.Write(img.Pix[y*img.Stride : y*img.Stride+g.width])
+		if err != nil {
+			g.err = err
+			return
+		}
+	}
+	g.err = lzwWriter.Close()
+	if g.err != nil {
+		return
+	}
+	g.err = blocks.close()
+}
+
+// blockWriter splits image data into the sub-blocks of at most 255 bytes GIF
+// stores it in.
+type blockWriter struct {
+	w   io.Writer
+	buf [256]byte
+	n   int
+}
+
+func (b *blockWriter) Write(p []byte) (int, error) {
+	for i, c := range p {
+		b.buf[1+b.n] = c
+		b.n++
+		if b.n == 255 {
+			err := b.flush()
+			if err != nil {
+				return i, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+func (b *blockWriter) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.buf[0] = byte(b.n)
+	_, err := b.w.Write(b.buf[:1+b.n])
+	b.n = 0
+	return err
+}
+
+// close writes any remaining data and the empty block ending the data.
+func (b *blockWriter) close() error {
+	err := b.flush()
+	if err != nil {
+		return err
+	}
+	_, err = b.w.Write([]byte{0})
+	return err
+}
+
+// Quantize returns img reduced to a palette of at most n colors chosen by
+// median cut, dithered with Floyd-Steinberg error diffusion. Pixels less than
+// half opaque become transparent, and take one of the n colors if there are
+// any.
+func Quantize(img image.Image, n int) *image.Paletted {
+	bounds := img.Bounds()
+
+	// Count colors at five bits per channel, which is plenty to choose a
+	// palette from and keeps the histogram small.
+	counts := make(map[uint16]*colorCount)
+	hasTransparent := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				hasTransparent = true
+				continue
+			}
+			k := uint16(c.R>>3)<<10 | uint16(c.G>>3)<<5 | uint16(c.B>>3)
+			count := counts[k]
+			if count == nil {
+				count = &colorCount{rgb: [3]uint8{c.R >> 3, c.G >> 3, c.B >> 3}}
+				counts[k] = count
+			}
+			count.count++
+			count.sum[0] += int(c.R)
+			count.sum[1] += int(c.G)
+			count.sum[2] += int(c.B)
+		}
+	}
+
+	if hasTransparent {
+		n--
+	}
+	palette := medianCut(counts, n)
+	if hasTransparent {
+		palette = append(palette, color.RGBA{})
+	}
+	if len(palette) == 0 {
+		palette = append(palette, color.RGBA{A: 255})
+	}
+
+	// Dithering ignores alpha, so transparent pixels are set afterward.
+	opaque := image.NewRGBA(bounds)
+	draw.Draw(opaque, bounds, img, bounds.Min, draw.Src)
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 255
+	}
+	result := image.NewPaletted(bounds, palette)
+	dither := palette
+	if hasTransparent {
+		dither = palette[:len(palette)-1]
+	}
+	if len(dither) > 0 {
+		result.Palette = dither
+		draw.FloydSteinberg.Draw(result, bounds, opaque, bounds.Min)
+		result.Palette = palette
+	}
+
+	if hasTransparent {
+		transparent := uint8(len(palette) - 1)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if _, _, _, a := img.At(x, y).RGBA(); a < 0x8000 {
+					result.SetColorIndex(x, y, transparent)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// colorCount is a color of a histogram, at five bits per channel, how many
+// pixels have it and the sum of their full colors.
+type colorCount struct {
+	rgb   [3]uint8
+	count int
+	sum   [3]int
+}
+
+// medianCut returns at most n colors representing the colors of counts.
+//
+// It repeatedly splits the box of colors with the most pixels along its
+// widest channel, at the median pixel, and then takes the mean color of the
+// pixels in each box.
+func medianCut(counts map[uint16]*colorCount, n int) color.Palette {
+	if n <= 0 || len(counts) == 0 {
+		return nil
+	}
+
+	colors := make([]colorCount, 0, len(counts))
+	for _, count := range counts {
+		colors = append(colors, *count)
+	}
+	// Keep the result the same for the same image, as map order isn't.
+	sort.Slice(colors, func(i, j int) bool {
+		return colors[i].rgb[0] < colors[j].rgb[0] ||
+			colors[i].rgb[0] == colors[j].rgb[0] && (colors[i].rgb[1] < colors[j].rgb[1] ||
+				colors[i].rgb[1] == colors[j].rgb[1] && colors[i].rgb[2] < colors[j].rgb[2])
+	})
+
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// Split the box with the most pixels which has more than one color.
+		best, bestCount := -1, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if count := pixels(box); count > bestCount {
+				best, bestCount = i, count
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		channel := widestChannel(box)
+		sort.SliceStable(box, func(i, j int) bool {
+			return box[i].rgb[channel] < box[j].rgb[channel]
+		})
+		split, seen := 1, box[0].count
+		for split < len(box)-1 && seen+box[split].count <= bestCount/2 {
+			seen += box[split].count
+			split++
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		var sum [3]int
+		count := pixels(box)
+		for _, c := range box {
+			for ch := range sum {
+				sum[ch] += c.sum[ch]
+			}
+		}
+		palette[i] = color.RGBA{
+			R: uint8(sum[0] / count),
+			G: uint8(sum[1] / count),
+			B: uint8(sum[2] / count),
+			A: 255,
+		}
+	}
+	return palette
+}
+
+func pixels(box []colorCount) int {
+	result := 0
+	for _, c := range box {
+		result += c.count
+	}
+	return result
+}
+
+// widestChannel returns the channel whose values vary most within box.
+func widestChannel(box []colorCount) int {
+	result, widest := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		min, max := uint8(255), uint8(0)
+		for _, c := range box {
+			if c.rgb[ch] < min {
+				min = c.rgb[ch]
+			}
+			if c.rgb[ch] > max {
+				max = c.rgb[ch]
+			}
+		}
+		if int(max-min) > widest {
+			result, widest = ch, int(max-min)
+		}
+	}
+	return result
+}
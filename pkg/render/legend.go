@@ -0,0 +1,197 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// Legend is a horizontal bar showing the colors of a ColorScale from its first
+// threshold to its last, with labelled ticks below it.
+type Legend struct {
+	Scale *ColorScale
+	// Title, if set, is written above the bar.
+	Title string
+	// Ticks are the values to label. If nil, about five round values are.
+	Ticks []float64
+
+	// Width and Height are the size of the bar in pixels, or 200 by 12 if
+	// zero.
+	Width, Height int
+	// TextScale is how many pixels across each pixel of the font is, or 1 if
+	// zero.
+	TextScale int
+	// Color is the color of text, ticks and the outline of the bar, and
+	// Background fills the area the legend covers. Both may be transparent.
+	Color, Background color.RGBA
+}
+
+const (
+	legendPadding    = 4
+	legendTickLength = 3
+)
+
+func (l Legend) barSize() (int, int) {
+	width, height := l.Width, l.Height
+	if width == 0 {
+		width = 200
+	}
+	if height == 0 {
+		height = 12
+	}
+	return width, height
+}
+
+func (l Legend) textScale() int {
+	if l.TextScale == 0 {
+		return 1
+	}
+	return l.TextScale
+}
+
+func (l Legend) ticks() []float64 {
+	if l.Ticks != nil {
+		return l.Ticks
+	}
+	return NiceTicks(l.Scale.Thresholds[0], l.Scale.Thresholds[len(l.Scale.Thresholds)-1], 5)
+}
+
+// labels returns the text of each tick, with as many decimals as the
+// smallest gap between ticks needs.
+func (l Legend) labels(ticks []float64) []string {
+	decimals := 0
+	for i := 1; i < len(ticks); i++ {
+		gap := math.Abs(ticks[i] - ticks[i-1])
+		if gap > 0 {
+			decimals = int(math.Max(float64(decimals), -math.Floor(math.Log10(gap)+1e-9)))
+		}
+	}
+
+	result := make([]string, len(ticks))
+	for i, t := range ticks {
+		result[i] = strconv.FormatFloat(t, 'f', decimals, 64)
+		if t == 0 || result[i] == "-"+strconv.FormatFloat(0, 'f', decimals, 64) {
+			result[i] = strconv.FormatFloat(0, 'f', decimals, 64)
+		}
+	}
+	return result
+}
+
+// margin returns how far labels may reach past either end of the bar.
+func (l Legend) margin(labels []string) int {
+	result := 0
+	for _, label := range labels {
+		w := (TextWidth(label, l.textScale()) + 1) / 2
+		if w > result {
+			result = w
+		}
+	}
+	return result
+}
+
+// Size returns the width and height of the area Draw covers.
+func (l Legend) Size() (int, int) {
+	barWidth, barHeight := l.barSize()
+	scale := l.textScale()
+	ticks := l.ticks()
+	labels := l.labels(ticks)
+
+	margin := l.margin(labels)
+	width := barWidth + 2*margin
+	if titleWidth := TextWidth(l.Title, scale); titleWidth > width {
+		width = titleWidth
+	}
+	height := barHeight + legendTickLength + 1 + GlyphHeight*scale
+	if l.Title != "" {
+		height += GlyphHeight*scale + legendPadding
+	}
+	return width + 2*legendPadding, height + 2*legendPadding
+}
+
+// Draw draws the legend onto img with its top left corner at x, y, and
+// returns the area it covers.
+func (l Legend) Draw(img *image.RGBA, x, y int) image.Rectangle {
+	width, height := l.Size()
+	bounds := image.Rect(x, y, x+width, y+height)
+	if l.Background.A != 0 {
+		for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+			for px := bounds.Min.X; px < bounds.Max.X; px++ {
+				img.SetRGBA(px, py, l.Background)
+			}
+		}
+	}
+
+	barWidth, barHeight := l.barSize()
+	scale := l.textScale()
+	ticks := l.ticks()
+	labels := l.labels(ticks)
+
+	top := y + legendPadding
+	if l.Title != "" {
+		DrawText(img, x+legendPadding, top, scale, l.Title, l.Color)
+		top += GlyphHeight*scale + legendPadding
+	}
+
+	// Center the bar, as the title may be wider than it.
+	left := x + (width-barWidth)/2
+	min := l.Scale.Thresholds[0]
+	max := l.Scale.Thresholds[len(l.Scale.Thresholds)-1]
+	for i := 0; i < barWidth; i++ {
+		c := l.Scale.ColorAt(Lerp(min, max, (float64(i)+0.5)/float64(barWidth)))
+		for j := 0; j < barHeight; j++ {
+			img.SetRGBA(left+i, top+j, c)
+		}
+	}
+	if l.Color.A != 0 {
+		for i := -1; i <= barWidth; i++ {
+			img.SetRGBA(left+i, top-1, l.Color)
+			img.SetRGBA(left+i, top+barHeight, l.Color)
+		}
+		for j := 0; j < barHeight; j++ {
+			img.SetRGBA(left-1, top+j, l.Color)
+			img.SetRGBA(left+barWidth, top+j, l.Color)
+		}
+	}
+
+	for i, t := range ticks {
+		if t < math.Min(min, max) || t > math.Max(min, max) || min == max {
+			continue
+		}
+		tx := left + int(math.Round((t-min)/(max-min)*float64(barWidth-1)))
+		for j := 0; j < legendTickLength; j++ {
+			img.SetRGBA(tx, top+barHeight+1+j, l.Color)
+		}
+		labelWidth := TextWidth(labels[i], scale)
+		DrawText(img, tx-labelWidth/2, top+barHeight+legendTickLength+2, scale, labels[i], l.Color)
+	}
+
+	return bounds
+}
+
+// NiceTicks returns about n round values from min to max, spaced by 1, 2 or
+// 5 times a power of ten.
+func NiceTicks(min, max float64, n int) []float64 {
+	if max < min {
+		min, max = max, min
+	}
+	if max == min || n < 1 {
+		return []float64{min}
+	}
+
+	rough := (max - min) / float64(n)
+	power := math.Pow(10, math.Floor(math.Log10(rough)))
+	step := power * 10
+	for _, f := range []float64{1, 2, 5} {
+		if f*power >= rough {
+			step = f * power
+			break
+		}
+	}
+
+	var result []float64
+	for i := math.Ceil(min/step - 1e-9); i*step <= max+step*1e-9; i++ {
+		result = append(result, i*step)
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package render
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+)
+
+// DefaultMercatorLatitude is the latitude, about 85.05 degrees, at which the
+// Mercator projection is square.
+var DefaultMercatorLatitude = math.Atan(math.Sinh(math.Pi))
+
+// Mercator is the conformal cylindrical projection. The poles are infinitely
+// far away, so it only shows latitudes up to MaxLatitude, in radians, or
+// DefaultMercatorLatitude if MaxLatitude is zero. Its natural aspect ratio is
+// Pi:ln(tan(Pi/4 + MaxLatitude/2)), so 1:1 by default.
+type Mercator struct {
+	MaxLatitude float64
+}
+
+var _ Projector = Mercator{}
+
+// maxY returns the distance from the equator to the top of the map.
+func (m Mercator) maxY() float64 {
+	latitude := m.MaxLatitude
+	if latitude == 0 {
+		latitude = DefaultMercatorLatitude
+	}
+	return math.Log(math.Tan(math.Pi/4 + latitude/2))
+}
+
+func (m Mercator) Project(x, y float64) (geodesic.Angle, bool) {
+	return geodesic.Angle{
+		Theta: math.Atan(math.Sinh(2 * y * m.maxY())),
+		Phi:   2 * math.Pi * x,
+	}, inside(x, y)
+}
+
+func (m Mercator) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	maxY := m.maxY()
+	y := math.Log(math.Tan(math.Pi/4 + a.Theta/2))
+	if math.IsNaN(y) || math.Abs(y) > maxY {
+		return 0, 0, false
+	}
+	return wrap(a.Phi) / (2 * math.Pi), y / (2 * maxY), true
+}
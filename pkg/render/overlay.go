@@ -0,0 +1,232 @@
+package render
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Overlay draws lines, arrows and labels on the sphere onto an image of a
+// Projection.
+//
+// Lines follow the sphere, so they curve as the projection does. They are
+// broken wherever they leave the projected domain or cross a seam of the
+// projection, such as the antimeridian of Equirectangular, rather than being
+// drawn across the whole image.
+type Overlay struct {
+	Projection Projection
+	Image      *image.RGBA
+}
+
+func NewOverlay(projection Projection, img *image.RGBA) *Overlay {
+	return &Overlay{Projection: projection, Image: img}
+}
+
+const (
+	// minDepth is how many times curves are always halved before checking
+	// whether they are straight, so curves whose ends and middle happen to
+	// line up are still drawn correctly.
+	minDepth = 3
+	// maxDepth is how many times curves are halved before giving up on
+	// finding a straight piece, where they cross a seam or leave the
+	// projected domain.
+	maxDepth = 20
+	// hiddenDepth is how many times curves are halved looking for visible
+	// parts before assuming there are none.
+	hiddenDepth = 6
+	// flatness is how far in pixels the middle of a piece of a curve may be
+	// from the line between its ends and still be drawn as that line.
+	flatness = 0.5
+)
+
+// screenPoint is where a point on the sphere is drawn, if it is shown.
+type screenPoint struct {
+	x, y float64
+	ok   bool
+}
+
+func (o *Overlay) point(v geodesic.Vector) screenPoint {
+	x, y, ok := o.Projection.Pixel(v.Angle())
+	return screenPoint{x: x, y: y, ok: ok}
+}
+
+// Line draws the shorter great circle arc between a and b.
+func (o *Overlay) Line(a, b geodesic.Vector, c color.RGBA) {
+	o.Curve(greatCircle(a, b), c)
+}
+
+// Polyline draws great circle arcs between consecutive points, such as the
+// course of a river.
+func (o *Overlay) Polyline(points []geodesic.Vector, c color.RGBA) {
+	for i := 1; i < len(points); i++ {
+		o.Line(points[i-1], points[i], c)
+	}
+}
+
+// Curve draws the curve on the sphere which at returns points along as t goes
+// from 0 to 1.
+func (o *Overlay) Curve(at func(t float64) geodesic.Vector, c color.RGBA) {
+	o.curve(at, 0, o.point(at(0)), 1, o.point(at(1)), 0, c)
+}
+
+func (o *Overlay) curve(at func(t float64) geodesic.Vector, t0 float64, p0 screenPoint, t1 float64, p1 screenPoint, depth int, c color.RGBA) {
+	tm := (t0 + t1) / 2
+	pm := o.point(at(tm))
+
+	if p0.ok && pm.ok && p1.ok {
+		offset := math.Hypot(pm.x-(p0.x+p1.x)/2, pm.y-(p0.y+p1.y)/2)
+		if depth >= minDepth && offset <= flatness {
+			o.segment(p0, p1, c)
+			return
+		}
+	} else if depth >= hiddenDepth && !p0.ok && !pm.ok && !p1.ok {
+		return
+	}
+
+	if depth == maxDepth {
+		// The ends are next to each other on the sphere but not on the
+		// screen, so the curve crosses a seam here.
+		return
+	}
+	o.curve(at, t0, p0, tm, pm, depth+1, c)
+	o.curve(at, tm, pm, t1, p1, depth+1, c)
+}
+
+// segment draws a straight line between two points on the screen.
+func (o *Overlay) segment(p0, p1 screenPoint, c color.RGBA) {
+	x0, y0 := int(math.Round(p0.x)), int(math.Round(p0.y))
+	x1, y1 := int(math.Round(p1.x)), int(math.Round(p1.y))
+
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x1 < x0 {
+		sx = -1
+	}
+	if y1 < y0 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		o.Image.SetRGBA(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// Graticule draws parallels and meridians every step radians.
+func (o *Overlay) Graticule(step float64, c color.RGBA) {
+	for theta := step; theta < math.Pi/2; theta += step {
+		o.parallel(theta, c)
+		o.parallel(-theta, c)
+	}
+	o.parallel(0, c)
+
+	for phi := -math.Pi; phi < math.Pi-step/2; phi += step {
+		phi := phi
+		o.Curve(func(t float64) geodesic.Vector {
+			return geodesic.Angle{Theta: (t - 0.5) * math.Pi, Phi: phi}.Vector()
+		}, c)
+	}
+}
+
+func (o *Overlay) parallel(theta float64, c color.RGBA) {
+	o.Curve(func(t float64) geodesic.Vector {
+		return geodesic.Angle{Theta: theta, Phi: (2*t - 1) * math.Pi}.Vector()
+	}, c)
+}
+
+// CellBorders draws the edges between the faces of g.
+func (o *Overlay) CellBorders(g *geodesic.Geodesic, c color.RGBA) {
+	geometry := g.Geometry()
+	for i, face := range g.Faces {
+		corners := geometry.Corners[i]
+		for k, n := range face.Neighbors {
+			// Each edge is drawn once, from the face with the lower index.
+			if n < i {
+				continue
+			}
+			// The edge with the kth neighbor is between the corners it shares
+			// with the neighbors before and after it.
+			prev := corners[(k+len(corners)-1)%len(corners)]
+			o.Line(prev, corners[k], c)
+		}
+	}
+}
+
+// Arrow draws an arrow from at in the direction of the tangent vector v,
+// reaching scale times the length of v in radians along the sphere.
+func (o *Overlay) Arrow(at, v geodesic.Vector, scale float64, c color.RGBA) {
+	length := v.Length() * scale
+	if length == 0 {
+		return
+	}
+	direction := v.Reject(at).Normalize()
+	head := at.Scale(math.Cos(length)).Add(direction.Scale(math.Sin(length)))
+	shaft := greatCircle(at, head)
+	o.Curve(shaft, c)
+
+	// Point the head along the end of the shaft as it is drawn.
+	tip, behind := o.point(head), o.point(shaft(0.9))
+	if !tip.ok || !behind.ok {
+		return
+	}
+	dx, dy := tip.x-behind.x, tip.y-behind.y
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return
+	}
+	size := math.Min(4, math.Max(2, 2.5*d))
+	dx, dy = dx/d*size, dy/d*size
+	for _, side := range []float64{-1, 1} {
+		// Barbs are 30 degrees either side of the shaft.
+		bx := -dx*math.Cos(math.Pi/6) - side*dy*math.Sin(math.Pi/6)
+		by := -dy*math.Cos(math.Pi/6) + side*dx*math.Sin(math.Pi/6)
+		o.segment(tip, screenPoint{x: tip.x + bx, y: tip.y + by, ok: true}, c)
+	}
+}
+
+// Label draws text with the built-in font just to the right of at, centered
+// vertically on it.
+func (o *Overlay) Label(at geodesic.Vector, text string, c color.RGBA) {
+	p := o.point(at)
+	if !p.ok {
+		return
+	}
+	x, y := int(math.Round(p.x)), int(math.Round(p.y))
+	DrawText(o.Image, x+3, y-GlyphHeight/2, 1, text, c)
+}
+
+// greatCircle returns the shorter great circle arc from a to b. It is not
+// unique for opposite points.
+func greatCircle(a, b geodesic.Vector) func(t float64) geodesic.Vector {
+	a, b = a.Normalize(), b.Normalize()
+	omega := math.Acos(math.Max(-1, math.Min(1, a.Dot(b))))
+	sin := math.Sin(omega)
+	if sin < 1e-12 {
+		return func(t float64) geodesic.Vector {
+			return a.Scale(1 - t).Add(b.Scale(t)).Normalize()
+		}
+	}
+	return func(t float64) geodesic.Vector {
+		return a.Scale(math.Sin((1-t)*omega) / sin).Add(b.Scale(math.Sin(t*omega) / sin))
+	}
+}
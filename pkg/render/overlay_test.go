@@ -0,0 +1,180 @@
+package render
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+var black = color.RGBA{A: 255}
+
+func newTestOverlay(screen Screen, projector Projector) *Overlay {
+	img := image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height))
+	return NewOverlay(Project(screen, projector), img)
+}
+
+// drawn returns the pixels of img which have been drawn on.
+func drawn(img *image.RGBA) []image.Point {
+	var result []image.Point
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.RGBAAt(x, y).A != 0 {
+				result = append(result, image.Point{X: x, Y: y})
+			}
+		}
+	}
+	return result
+}
+
+func degrees(theta, phi float64) geodesic.Vector {
+	return geodesic.Angle{Theta: theta * math.Pi / 180, Phi: phi * math.Pi / 180}.Vector()
+}
+
+func TestOverlay_Line(t *testing.T) {
+	screen := Screen{Width: 360, Height: 180}
+	o := newTestOverlay(screen, Equirectangular{})
+
+	// The line crosses the antimeridian, so it is drawn at either edge of the
+	// map rather than across it.
+	o.Line(degrees(0, 170), degrees(0, -170), black)
+
+	points := drawn(o.Image)
+	if len(points) == 0 {
+		t.Fatal("got nothing drawn")
+	}
+	left, right := 0, 0
+	for _, p := range points {
+		switch {
+		case p.X < 12:
+			left++
+		case p.X >= screen.Width-12:
+			right++
+		default:
+			t.Fatalf("got pixel %v drawn away from the antimeridian", p)
+		}
+	}
+	if left < 8 || right < 8 {
+		t.Errorf("got %d pixels drawn on the left and %d on the right, want about 10 of each", left, right)
+	}
+}
+
+func TestOverlay_Line_FarSide(t *testing.T) {
+	screen := Screen{Width: 100, Height: 100}
+	o := newTestOverlay(screen, Orthographic{})
+
+	// Only the half of the line on the near side of the globe is drawn.
+	o.Line(degrees(0, -45), degrees(0, 135), black)
+
+	points := drawn(o.Image)
+	if len(points) == 0 {
+		t.Fatal("got nothing drawn")
+	}
+	for _, p := range points {
+		x, y := screen.Coordinates(float64(p.X), float64(p.Y))
+		if 4*(x*x+y*y) > 1.05 {
+			t.Errorf("got pixel %v drawn off the globe", p)
+		}
+	}
+}
+
+func TestOverlay_Graticule(t *testing.T) {
+	screen := Screen{Width: 200, Height: 100}
+	o := newTestOverlay(screen, Mollweide{})
+	o.Graticule(math.Pi/6, black)
+
+	points := drawn(o.Image)
+	if len(points) == 0 {
+		t.Fatal("got nothing drawn")
+	}
+	for _, p := range points {
+		x, y := screen.Coordinates(float64(p.X), float64(p.Y))
+		if 4*(x*x+y*y) > 1.05 {
+			t.Errorf("got pixel %v drawn outside the ellipse", p)
+		}
+	}
+}
+
+func TestOverlay_CellBorders(t *testing.T) {
+	screen := Screen{Width: 256, Height: 128}
+	spheres := []*geodesic.Geodesic{geodesic.Dodecahedron()}
+	spheres = append(spheres, geodesic.Chamfer(spheres[0]))
+
+	projection := Project(screen, Equirectangular{})
+	o := NewOverlay(projection, image.NewRGBA(image.Rect(0, 0, screen.Width, screen.Height)))
+	o.CellBorders(spheres[1], black)
+
+	// Every pixel on the border between cells is next to a line.
+	cells := NewCellMap(projection, spheres[1])
+	for pidx, border := range cells.Borders() {
+		if !border {
+			continue
+		}
+		x, y := pidx%screen.Width, pidx/screen.Width
+		found := false
+		for dy := -2; dy <= 2 && !found; dy++ {
+			for dx := -2; dx <= 2 && !found; dx++ {
+				found = o.Image.RGBAAt(x+dx, y+dy).A != 0
+			}
+		}
+		if !found {
+			t.Fatalf("got no line near border pixel %d, %d", x, y)
+		}
+	}
+}
+
+func TestOverlay_Arrow(t *testing.T) {
+	screen := Screen{Width: 360, Height: 180}
+	o := newTestOverlay(screen, Equirectangular{})
+
+	// An arrow pointing east along the equator for 20 degrees.
+	o.Arrow(degrees(0, 0), geodesic.Vector{Y: 1}, 20*math.Pi/180, black)
+
+	tip, _, _ := o.Projection.Pixel(geodesic.Angle{Phi: 20 * math.Pi / 180})
+	maxX := 0
+	for _, p := range drawn(o.Image) {
+		if p.X > maxX {
+			maxX = p.X
+		}
+	}
+	if math.Abs(float64(maxX)-tip) > 1 {
+		t.Errorf("got arrow reaching pixel %d, want %g", maxX, tip)
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	DrawText(img, 1, 1, 1, "1", black)
+
+	want := []image.Point{
+		{3, 1},
+		{2, 2}, {3, 2},
+		{3, 3}, {3, 4}, {3, 5}, {3, 6},
+		{2, 7}, {3, 7}, {4, 7},
+	}
+	if diff := cmp.Diff(want, drawn(img)); diff != "" {
+		t.Error(diff)
+	}
+
+	if got := TextWidth("abc", 2); got != 34 {
+		t.Errorf("got TextWidth() = %d, want %d", got, 34)
+	}
+}
+
+func TestScreen_Borders(t *testing.T) {
+	screen := Screen{Width: 4, Height: 2}
+	got := screen.Borders([]int{
+		0, 0, 1, 1,
+		0, 0, 1, 2,
+	})
+	want := []bool{
+		false, true, true, true,
+		false, true, true, true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,216 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// palettes are the built-in scientific palettes, as colors evenly spaced from
+// 0 to 1.
+var palettes = map[string][]string{
+	// Sequential palettes from matplotlib, which are perceptually uniform and
+	// readable with color blindness.
+	"viridis": {"#440154", "#472d7b", "#3b528b", "#2c728e", "#21918c", "#28ae80", "#5ec962", "#addc30", "#fde725"},
+	"magma":   {"#000004", "#1c1044", "#4f127b", "#812581", "#b5367a", "#e55964", "#fb8761", "#fec287", "#fcfdbf"},
+	// Diverging palettes from ColorBrewer, for values either side of a
+	// midpoint such as zero.
+	"rdbu": {"#67001f", "#b2182b", "#d6604d", "#f4a582", "#fddbc7", "#f7f7f7", "#d1e5f0", "#92c5de", "#4393c3", "#2166ac", "#053061"},
+	"brbg": {"#543005", "#8c510a", "#bf812d", "#dfc27d", "#f6e8c3", "#f5f5f5", "#c7eae5", "#80cdc1", "#35978f", "#01665e", "#003c30"},
+}
+
+// PaletteNames returns the names of the built-in palettes.
+func PaletteNames() []string {
+	result := make([]string, 0, len(palettes))
+	for name := range palettes {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Palette returns the built-in palette named name, case-insensitively, as a
+// ColorScale from 0 to 1 interpolated in OKLab. Use Rescale to apply it to
+// other ranges.
+func Palette(name string) (*ColorScale, error) {
+	hexes, found := palettes[strings.ToLower(name)]
+	if !found {
+		return nil, fmt.Errorf("unknown palette %q, want one of %s", name, strings.Join(PaletteNames(), ", "))
+	}
+
+	points := make([]ColorPoint, len(hexes))
+	for i, hex := range hexes {
+		c, err := ParseColor(hex)
+		if err != nil {
+			panic(err)
+		}
+		points[i] = ColorPoint{Threshold: float64(i) / float64(len(hexes)-1), Color: c}
+	}
+	result := NewColorScale(points)
+	result.Interpolation = OKLab
+	return result, nil
+}
+
+// ParseColor parses a color written as #rrggbb or #rrggbbaa.
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 || len(hex) == len(s) {
+		return color.RGBA{}, fmt.Errorf("color %q isn't #rrggbb or #rrggbbaa", s)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("color %q isn't #rrggbb or #rrggbbaa", s)
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// paletteFile is the contents of a palette file, which either lists the
+// points of a ColorScale or names a built-in palette.
+type paletteFile struct {
+	// Palette names a built-in palette to use instead of Points.
+	Palette string `json:"palette" yaml:"palette"`
+	Points  []struct {
+		Value float64 `json:"value" yaml:"value"`
+		Color string  `json:"color" yaml:"color"`
+	} `json:"points" yaml:"points"`
+	Interpolation Interpolation `json:"interpolation" yaml:"interpolation"`
+	// Min and Max, if either is set, are the range to rescale the palette to.
+	Min *float64 `json:"min" yaml:"min"`
+	Max *float64 `json:"max" yaml:"max"`
+}
+
+// ParseColorScale parses a palette file in JSON:
+//
+//	{
+//	  "interpolation": "oklab",
+//	  "points": [
+//	    {"value": -1, "color": "#2166ac"},
+//	    {"value": 0, "color": "#f7f7f7"},
+//	    {"value": 1, "color": "#b2182b"}
+//	  ]
+//	}
+//
+// Instead of points, "palette" may name a built-in palette, which "min" and
+// "max" rescale. Interpolation is "rgb", "lab" or "oklab", and defaults to
+// "rgb" for points and "oklab" for built-in palettes.
+func ParseColorScale(data []byte) (*ColorScale, error) {
+	var file paletteFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+	return file.colorScale()
+}
+
+// ParseColorScaleYAML parses a palette file written in YAML with the same
+// structure ParseColorScale expects. Colors must be quoted, as YAML treats
+// anything after # as a comment.
+func ParseColorScaleYAML(data []byte) (*ColorScale, error) {
+	var file paletteFile
+	err := yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+	return file.colorScale()
+}
+
+// LoadColorScale reads the palette file at path, in YAML if it ends in .yaml
+// or .yml and otherwise in JSON.
+func LoadColorScale(path string) (*ColorScale, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ColorScale
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		result, err = ParseColorScaleYAML(data)
+	default:
+		result, err = ParseColorScale(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading palette %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// LoadPalettes replaces each color scale of scales with the palette file in
+// dir named after it with a .json, .yaml or .yml extension, if there is one.
+func LoadPalettes(dir string, scales map[string]*ColorScale) error {
+	for name := range scales {
+		for _, ext := range []string{".json", ".yaml", ".yml"} {
+			path := filepath.Join(dir, name+ext)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				continue
+			}
+
+			cs, err := LoadColorScale(path)
+			if err != nil {
+				return err
+			}
+			scales[name] = cs
+			break
+		}
+	}
+	return nil
+}
+
+func (f *paletteFile) colorScale() (*ColorScale, error) {
+	var result *ColorScale
+	switch {
+	case f.Palette != "" && len(f.Points) > 0:
+		return nil, fmt.Errorf("palette file has both a palette and points")
+	case f.Palette != "":
+		var err error
+		result, err = Palette(f.Palette)
+		if err != nil {
+			return nil, err
+		}
+	case len(f.Points) == 0:
+		return nil, fmt.Errorf("palette file has no points")
+	default:
+		points := make([]ColorPoint, len(f.Points))
+		for i, p := range f.Points {
+			if i > 0 && p.Value < f.Points[i-1].Value {
+				return nil, fmt.Errorf("point %d has value %g less than the point before it", i, p.Value)
+			}
+			c, err := ParseColor(p.Color)
+			if err != nil {
+				return nil, fmt.Errorf("point %d: %w", i, err)
+			}
+			points[i] = ColorPoint{Threshold: p.Value, Color: c}
+		}
+		result = NewColorScale(points)
+	}
+
+	switch f.Interpolation {
+	case "":
+	case RGB, Lab, OKLab:
+		result.Interpolation = f.Interpolation
+	default:
+		return nil, fmt.Errorf("unknown interpolation %q", f.Interpolation)
+	}
+
+	if f.Min != nil || f.Max != nil {
+		min, max := result.Thresholds[0], result.Thresholds[len(result.Thresholds)-1]
+		if f.Min != nil {
+			min = *f.Min
+		}
+		if f.Max != nil {
+			max = *f.Max
+		}
+		result = result.Rescale(min, max)
+	}
+	return result, nil
+}
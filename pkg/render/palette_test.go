@@ -0,0 +1,354 @@
+package render
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpolation_RoundTrip(t *testing.T) {
+	spaces := []struct {
+		name     string
+		to, from func(r, g, b float64) (float64, float64, float64)
+	}{
+		{name: "lab", to: linearToLab, from: labToLinear},
+		{name: "oklab", to: linearToOKLab, from: okLabToLinear},
+	}
+
+	for _, space := range spaces {
+		t.Run(space.name, func(t *testing.T) {
+			for _, c := range []color.RGBA{{A: 255}, {R: 255, G: 255, B: 255}, {R: 255}, {G: 128, B: 255}, {R: 14, G: 31, B: 75}} {
+				got := color.RGBA{}
+				r, g, b := space.from(space.to(linearRGB(c)))
+				got.R, got.G, got.B, got.A = encodeSRGB(r), encodeSRGB(g), encodeSRGB(b), c.A
+				if diff := cmp.Diff(c, got); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestColorScale_ColorAt_Interpolation(t *testing.T) {
+	black, white := color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	tcs := []struct {
+		interpolation Interpolation
+		want          color.RGBA
+	}{
+		{interpolation: RGB, want: color.RGBA{R: 127, G: 127, B: 127, A: 255}},
+		// Perceptual spaces put the midpoint at half the lightness of white,
+		// which is darker than the midpoint of the RGB values.
+		{interpolation: Lab, want: color.RGBA{R: 119, G: 119, B: 119, A: 255}},
+		{interpolation: OKLab, want: color.RGBA{R: 99, G: 99, B: 99, A: 255}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(string(tc.interpolation), func(t *testing.T) {
+			cs := NewColorScale([]ColorPoint{{0, black}, {1, white}})
+			cs.Interpolation = tc.interpolation
+
+			if diff := cmp.Diff(tc.want, cs.ColorAt(0.5)); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(white, cs.ColorAt(1)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestColorScale_Rescale(t *testing.T) {
+	cs, err := Palette("viridis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := cs.Rescale(-10, 10)
+
+	if got.Thresholds[0] != -10 || got.Thresholds[len(got.Thresholds)-1] != 10 {
+		t.Errorf("got thresholds %v, want -10 to 10", got.Thresholds)
+	}
+	if diff := cmp.Diff(cs.ColorAt(0.3), got.ColorAt(-4)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	for _, name := range PaletteNames() {
+		t.Run(name, func(t *testing.T) {
+			cs, err := Palette(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cs.Thresholds[0] != 0 || cs.Thresholds[len(cs.Thresholds)-1] != 1 {
+				t.Errorf("got thresholds %v, want 0 to 1", cs.Thresholds)
+			}
+		})
+	}
+
+	_, err := Palette("jet")
+	if err == nil {
+		t.Error("got Palette() error nil for unknown palette")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tcs := []struct {
+		s       string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{s: "#440154", want: color.RGBA{R: 0x44, G: 0x01, B: 0x54, A: 255}},
+		{s: "#FDE72580", want: color.RGBA{R: 0xfd, G: 0xe7, B: 0x25, A: 0x80}},
+		{s: "440154", wantErr: true},
+		{s: "#4401", wantErr: true},
+		{s: "#44015g", wantErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.s, func(t *testing.T) {
+			got, err := ParseColor(tc.s)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("got ParseColor() error %v, want error %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+const testPaletteJSON = `{
+  "interpolation": "oklab",
+  "points": [
+    {"value": -1, "color": "#2166ac"},
+    {"value": 0, "color": "#f7f7f7"},
+    {"value": 2, "color": "#b2182b"}
+  ]
+}`
+
+func TestParseColorScale(t *testing.T) {
+	want := &ColorScale{
+		Thresholds:    []float64{-1, 0, 2},
+		Colors:        []color.RGBA{{0x21, 0x66, 0xac, 255}, {0xf7, 0xf7, 0xf7, 255}, {0xb2, 0x18, 0x2b, 255}},
+		Interpolation: OKLab,
+	}
+
+	got, err := ParseColorScale([]byte(testPaletteJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+
+	tcs := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "built-in", data: `{"palette": "RdBu", "min": -5, "max": 5}`},
+		{name: "unknown palette", data: `{"palette": "jet"}`, wantErr: true},
+		{name: "no points", data: `{}`, wantErr: true},
+		{name: "unordered", data: `{"points": [{"value": 1, "color": "#000000"}, {"value": 0, "color": "#ffffff"}]}`, wantErr: true},
+		{name: "bad color", data: `{"points": [{"value": 1, "color": "black"}]}`, wantErr: true},
+		{name: "bad interpolation", data: `{"palette": "magma", "interpolation": "hsv"}`, wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseColorScale([]byte(tc.data))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("got ParseColorScale() error %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseColorScaleYAML(t *testing.T) {
+	want, err := ParseColorScale([]byte(testPaletteJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "block",
+			data: `# A diverging palette.
+interpolation: oklab
+points:
+- value: -1
+  color: "#2166ac"   # blue
+- value: 0
+  color: '#f7f7f7'
+-
+  value: 2
+  color: "#b2182b"
+`,
+		},
+		{
+			name: "flow",
+			data: `interpolation: oklab
+points:
+  - {value: -1, color: "#2166ac"}
+  - {value: 0, color: "#f7f7f7"}
+  - {value: 2, color: "#b2182b"}
+`,
+		},
+		{name: "json", data: testPaletteJSON},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseColorScaleYAML([]byte(tc.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+
+	_, err = ParseColorScaleYAML([]byte("points: [value: 1"))
+	if err == nil {
+		t.Error("got ParseColorScaleYAML() error nil for malformed YAML")
+	}
+}
+
+func TestLoadColorScale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"palette.json": `{"palette": "viridis", "max": 100}`,
+		"palette.yaml": "palette: viridis\nmax: 100\n",
+	}
+	want, _ := Palette("viridis")
+	want = want.Rescale(0, 100)
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			err := ioutil.WriteFile(path, []byte(data), os.ModePerm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadColorScale(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestLoadPalettes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palettes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "land.yml"), []byte("palette: viridis\n"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "other.json"), []byte(`{"palette": "magma"}`), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scales := map[string]*ColorScale{"land": LandColorScale, "wind": AirVelocityColorScale}
+	err = LoadPalettes(dir, scales)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := Palette("viridis")
+	if diff := cmp.Diff(want, scales["land"]); diff != "" {
+		t.Error(diff)
+	}
+	if scales["wind"] != AirVelocityColorScale {
+		t.Error("replaced wind color scale with no palette file")
+	}
+	if len(scales) != 2 {
+		t.Errorf("got %d color scales, want 2", len(scales))
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "wind.json"), []byte("{"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = LoadPalettes(dir, scales); err == nil {
+		t.Error("got LoadPalettes() error nil for a malformed palette")
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	tcs := []struct {
+		min, max float64
+		want     []float64
+	}{
+		{min: 0, max: 1, want: []float64{0, 0.2, 0.4, 0.6, 0.8, 1}},
+		{min: 223, max: 323, want: []float64{240, 260, 280, 300, 320}},
+		{min: -0.1, max: 1, want: []float64{0, 0.5, 1}},
+		{min: 3, max: 3, want: []float64{3}},
+	}
+
+	for _, tc := range tcs {
+		got := NiceTicks(tc.min, tc.max, 5)
+		if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+			t.Errorf("NiceTicks(%g, %g): %s", tc.min, tc.max, diff)
+		}
+	}
+}
+
+func TestLegend_Draw(t *testing.T) {
+	cs, err := Palette("viridis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Legend{Scale: cs.Rescale(-1, 1), Title: "Height", Width: 100, Height: 10, Color: black}
+
+	width, height := l.Size()
+	img := newTestOverlay(Screen{Width: width + 10, Height: height + 10}, Equirectangular{}).Image
+	bounds := l.Draw(img, 5, 5)
+
+	for _, p := range drawn(img) {
+		if !p.In(bounds) {
+			t.Fatalf("got pixel %v drawn outside %v", p, bounds)
+		}
+	}
+
+	// The ends of the bar are the colors of the ends of the scale.
+	left := 5 + (width-100)/2
+	top := 5 + legendPadding + GlyphHeight + legendPadding
+	for _, tc := range []struct {
+		x     int
+		value float64
+	}{{x: left, value: -1}, {x: left + 99, value: 1}} {
+		got := img.RGBAAt(tc.x, top+5)
+		want := l.Scale.ColorAt(tc.value)
+		for _, d := range []int{int(got.R) - int(want.R), int(got.G) - int(want.G), int(got.B) - int(want.B)} {
+			if math.Abs(float64(d)) > 4 {
+				t.Errorf("got %v at the end of the bar, want about %v", got, want)
+				break
+			}
+		}
+	}
+
+	if diff := cmp.Diff([]string{"-1.0", "-0.5", "0.0", "0.5", "1.0"}, l.labels(l.ticks())); diff != "" {
+		t.Error(diff)
+	}
+}
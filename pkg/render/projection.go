@@ -2,52 +2,124 @@ package render
 
 import (
 	"github.com/willbeason/worldproc/pkg/geodesic"
+	"image"
+	"image/color"
 	"math"
 )
 
 type Projection struct {
 	Screen
+	Projector Projector
+
 	Pixels []geodesic.Angle
+	// Visible is whether each pixel shows part of the sphere. Pixels outside
+	// the projected domain have the zero Angle.
+	Visible []bool
 }
 
 func Project(screen Screen, projector Projector) Projection {
-	invWidth := 1.0 / float64(screen.Width + 1)
-	invHeight := 1.0 / float64(screen.Height + 1)
-
 	result := Projection{
-		Screen: screen,
-		Pixels: make([]geodesic.Angle, screen.Width*screen.Height),
+		Screen:    screen,
+		Projector: projector,
+		Pixels:    make([]geodesic.Angle, screen.Width*screen.Height),
+		Visible:   make([]bool, screen.Width*screen.Height),
 	}
 
 	for px := 0; px < screen.Width; px++ {
-		x := (float64(px) + 0.5 - (float64(screen.Width) / 2.0))  * invWidth
-
 		for py := 0; py < screen.Height; py++ {
-			y :=(float64(py) - (float64(screen.Height) / 2.0)) * invHeight
-			result.Pixels[py*screen.Width+px] = projector.Project(x, y)
+			x, y := screen.Coordinates(float64(px), float64(py))
+			pidx := py*screen.Width + px
+			result.Pixels[pidx], result.Visible[pidx] = projector.Project(x, y)
 		}
 	}
 
 	return result
 }
 
+// Pixel returns the position of a on the screen, in the same units as pixel
+// indices. ok is false if a is not shown.
+func (p Projection) Pixel(a geodesic.Angle) (px, py float64, ok bool) {
+	x, y, ok := p.Projector.Inverse(a)
+	if !ok {
+		return 0, 0, false
+	}
+	px, py = p.Position(x, y)
+	return px, py, true
+}
+
+// Mask makes every pixel of img which doesn't show part of the sphere
+// transparent.
+func (p Projection) Mask(img *image.RGBA) {
+	for pidx, visible := range p.Visible {
+		if !visible {
+			img.SetRGBA(pidx%p.Width, pidx/p.Width, color.RGBA{})
+		}
+	}
+}
+
+// Coordinates returns the projector coordinates of the pixel at px, py.
+// Integer values are the centers of pixels.
+func (s Screen) Coordinates(px, py float64) (x, y float64) {
+	x = (px + 0.5 - float64(s.Width)/2.0) * (1.0 / float64(s.Width+1))
+	y = (py - float64(s.Height)/2.0) * (1.0 / float64(s.Height+1))
+	return x, y
+}
+
+// Position is the inverse of Coordinates.
+func (s Screen) Position(x, y float64) (px, py float64) {
+	px = x*float64(s.Width+1) + float64(s.Width)/2.0 - 0.5
+	py = y*float64(s.Height+1) + float64(s.Height)/2.0
+	return px, py
+}
+
 type Projector interface {
 	// Project transforms a pair of x,y coordinates representing a location in
-	// a rectangle into an angle on a geodesic sphere.
+	// a rectangle into an angle on a geodesic sphere. ok is false if the
+	// location is outside the part of the rectangle the sphere is projected
+	// onto, as for the corners of a globe.
 	//
-	// -1 < x,y < 1
-	// x corresponds to left/right and y to up/down:
-	// -1 is left/bottom
+	// -1/2 <= x,y <= 1/2
+	// x corresponds to left/right and y to south/north:
+	// -1/2 is left/south
 	// 0 is center
-	// +1 is right/top
-	Project(x, y float64) geodesic.Angle
+	// +1/2 is right/north
+	//
+	// Each projector fills the rectangle best when the screen has the
+	// projection's natural aspect ratio.
+	Project(x, y float64) (a geodesic.Angle, ok bool)
+
+	// Inverse returns the x,y coordinates a is projected to. ok is false if
+	// a is not shown, as for the far side of a globe.
+	Inverse(a geodesic.Angle) (x, y float64, ok bool)
 }
 
+// Equirectangular maps longitude and latitude directly to x and y. Its natural
+// aspect ratio is 2:1.
 type Equirectangular struct {}
 
-func (Equirectangular) Project(x, y float64) geodesic.Angle {
+var _ Projector = Equirectangular{}
+
+func (Equirectangular) Project(x, y float64) (geodesic.Angle, bool) {
 	return geodesic.Angle{
 		Theta: y * math.Pi,
 		Phi: x * math.Pi * 2,
+	}, inside(x, y)
+}
+
+func (Equirectangular) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	return wrap(a.Phi) / (2 * math.Pi), a.Theta / math.Pi, true
+}
+
+// inside returns whether x,y is within the rectangle projectors map.
+func inside(x, y float64) bool {
+	return math.Abs(x) <= 0.5 && math.Abs(y) <= 0.5
+}
+
+// wrap returns the longitude equal to phi between -Pi and Pi.
+func wrap(phi float64) float64 {
+	phi = math.Mod(phi+math.Pi, 2*math.Pi)
+	if phi < 0 {
+		phi += 2 * math.Pi
 	}
+	return phi - math.Pi
 }
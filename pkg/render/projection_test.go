@@ -0,0 +1,133 @@
+package render
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var projectors = []struct {
+	name      string
+	projector Projector
+	// whole is whether the projector shows every direction.
+	whole bool
+}{
+	{name: "equirectangular", projector: Equirectangular{}, whole: true},
+	{name: "orthographic", projector: Orthographic{Center: geodesic.Angle{Theta: 0.5, Phi: 2}, Rotation: 0.3}},
+	{name: "mollweide", projector: Mollweide{}, whole: true},
+	{name: "robinson", projector: Robinson{}, whole: true},
+	{name: "azimuthal equidistant", projector: AzimuthalEquidistant{Center: geodesic.Angle{Theta: math.Pi / 2}}, whole: true},
+	{name: "mercator", projector: Mercator{}},
+	{name: "dymaxion", projector: Dymaxion{}, whole: true},
+}
+
+func TestProjector_Inverse(t *testing.T) {
+	for _, tc := range projectors {
+		t.Run(tc.name, func(t *testing.T) {
+			// Points on the seams of projections have more than one x,y, so
+			// use a grid which doesn't line up with them.
+			n := 0
+			for i := 0; i < 100; i++ {
+				for j := 0; j < 100; j++ {
+					x, y := (float64(i)+0.5)*0.00987-0.4931, (float64(j)+0.5)*0.00991-0.4937
+					a, ok := tc.projector.Project(x, y)
+					if !ok {
+						continue
+					}
+					n++
+
+					gotX, gotY, ok := tc.projector.Inverse(a)
+					if !ok {
+						t.Fatalf("got Inverse(Project(%g, %g)) not shown", x, y)
+					}
+					if math.Abs(gotX-x) > 1e-9 || math.Abs(gotY-y) > 1e-9 {
+						t.Fatalf("got Inverse(Project(%g, %g)) = %g, %g", x, y, gotX, gotY)
+					}
+				}
+			}
+			if n == 0 {
+				t.Error("no points were in the projected domain")
+			}
+		})
+	}
+}
+
+func TestProjector_Project(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for _, tc := range projectors {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 1000; i++ {
+				v := geodesic.Vector{X: r.NormFloat64(), Y: r.NormFloat64(), Z: r.NormFloat64()}.Normalize()
+				x, y, ok := tc.projector.Inverse(v.Angle())
+				if !ok {
+					if tc.whole {
+						t.Fatalf("got Inverse(%v) not shown", v)
+					}
+					continue
+				}
+				if !inside(x, y) {
+					t.Fatalf("got Inverse(%v) = %g, %g outside the rectangle", v, x, y)
+				}
+
+				a, ok := tc.projector.Project(x, y)
+				if !ok {
+					t.Fatalf("got Project(Inverse(%v)) not shown", v)
+				}
+				if d := a.Vector().Sub(v).Length(); d > 1e-9 {
+					t.Fatalf("got Project(Inverse(%v)) = %v, %g away", v, a.Vector(), d)
+				}
+			}
+		})
+	}
+}
+
+func TestOrthographic(t *testing.T) {
+	o := Orthographic{Center: geodesic.Angle{Phi: math.Pi / 2}}
+
+	if _, _, ok := o.Inverse(geodesic.Angle{Phi: -math.Pi / 2}); ok {
+		t.Error("got far side of globe shown")
+	}
+	if _, ok := o.Project(0.49, 0.49); ok {
+		t.Error("got corner of rectangle shown")
+	}
+
+	a, _ := o.Project(0, 0.5)
+	if math.Abs(a.Theta-math.Pi/2) > 1e-9 {
+		t.Errorf("got top of globe at latitude %g, want %g", a.Theta, math.Pi/2)
+	}
+}
+
+func TestProject_Visible(t *testing.T) {
+	screen := Screen{Width: 64, Height: 32}
+	projection := Project(screen, Mollweide{})
+
+	for py := 0; py < screen.Height; py++ {
+		for px := 0; px < screen.Width; px++ {
+			x, y := screen.Coordinates(float64(px), float64(py))
+			// The ellipse just touches the edges of the rectangle.
+			want := 4*x*x+4*y*y <= 1
+			if got := projection.Visible[py*screen.Width+px]; got != want {
+				t.Errorf("got pixel %d, %d visible %t, want %t", px, py, got, want)
+			}
+		}
+	}
+
+	cells := NewCellMap(projection, geodesic.Dodecahedron())
+	values := make([]float64, 12)
+	for i := range values {
+		values[i] = 1
+	}
+	if got := cells.Sample(values)[0]; got != 0 {
+		t.Errorf("got corner pixel sampled as %g, want 0", got)
+	}
+}
+
+func TestScreen_Position(t *testing.T) {
+	screen := Screen{Width: 64, Height: 32}
+	x, y := screen.Coordinates(10, 20)
+	px, py := screen.Position(x, y)
+	if math.Abs(px-10) > 1e-9 || math.Abs(py-20) > 1e-9 {
+		t.Errorf("got Position(Coordinates(10, 20)) = %g, %g", px, py)
+	}
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+	"sort"
+)
+
+// Mollweide is an equal-area projection of the sphere onto an ellipse. Its
+// natural aspect ratio is 2:1.
+type Mollweide struct{}
+
+var _ Projector = Mollweide{}
+
+func (Mollweide) Project(x, y float64) (geodesic.Angle, bool) {
+	// The unit sphere projects onto an ellipse with semi-axes 2*Sqrt2 and
+	// Sqrt2.
+	if 4*x*x+4*y*y > 1 {
+		return geodesic.Angle{}, false
+	}
+	u, v := 4*math.Sqrt2*x, 2*math.Sqrt2*y
+
+	aux := math.Asin(math.Max(-1, math.Min(1, v/math.Sqrt2)))
+	theta := math.Asin(math.Max(-1, math.Min(1, (2*aux+math.Sin(2*aux))/math.Pi)))
+	phi := 0.0
+	if c := math.Cos(aux); c > 0 {
+		phi = math.Pi * u / (2 * math.Sqrt2 * c)
+	}
+	return geodesic.Angle{Theta: theta, Phi: phi}, true
+}
+
+func (Mollweide) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	aux := mollweideAuxiliary(a.Theta)
+	u := 2 * math.Sqrt2 / math.Pi * wrap(a.Phi) * math.Cos(aux)
+	v := math.Sqrt2 * math.Sin(aux)
+	return u / (4 * math.Sqrt2), v / (2 * math.Sqrt2), true
+}
+
+// mollweideAuxiliary solves 2*aux + sin(2*aux) = Pi*sin(theta) for aux by
+// Newton's method.
+func mollweideAuxiliary(theta float64) float64 {
+	if math.Abs(theta) >= math.Pi/2 {
+		return math.Copysign(math.Pi/2, theta)
+	}
+	target := math.Pi * math.Sin(theta)
+	aux := theta
+	for i := 0; i < 50; i++ {
+		f := 2*aux + math.Sin(2*aux) - target
+		df := 2 + 2*math.Cos(2*aux)
+		if df == 0 {
+			break
+		}
+		step := f / df
+		aux -= step
+		if math.Abs(step) < 1e-12 {
+			break
+		}
+	}
+	return aux
+}
+
+// Robinson is a compromise projection which shows the whole sphere with
+// neither shapes nor areas badly distorted, defined by a table of lengths of
+// parallels and distances between them. Its natural aspect ratio is about
+// 1.97:1.
+type Robinson struct{}
+
+var _ Projector = Robinson{}
+
+// robinsonX and robinsonY are the length of each parallel and its distance
+// from the equator every 5 degrees from the equator to the pole, from
+// Robinson's published table.
+var (
+	robinsonX = []float64{
+		1.0000, 0.9986, 0.9954, 0.9900, 0.9822, 0.9730, 0.9600, 0.9427, 0.9216, 0.8962,
+		0.8679, 0.8350, 0.7986, 0.7597, 0.7186, 0.6732, 0.6213, 0.5722, 0.5322,
+	}
+	robinsonY = []float64{
+		0.0000, 0.0620, 0.1240, 0.1860, 0.2480, 0.3100, 0.3720, 0.4340, 0.4958, 0.5571,
+		0.6176, 0.6769, 0.7346, 0.7903, 0.8435, 0.8936, 0.9394, 0.9761, 1.0000,
+	}
+)
+
+// robinsonStep is the latitude between rows of the table.
+const robinsonStep = 5 * math.Pi / 180
+
+func (Robinson) Project(x, y float64) (geodesic.Angle, bool) {
+	if !inside(x, y) {
+		return geodesic.Angle{}, false
+	}
+	v := math.Abs(2 * y)
+
+	// Find the parallels the point is between.
+	i := sort.SearchFloat64s(robinsonY, v) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(robinsonY)-1 {
+		i = len(robinsonY) - 2
+	}
+	w := (v - robinsonY[i]) / (robinsonY[i+1] - robinsonY[i])
+	theta := math.Copysign((float64(i)+w)*robinsonStep, y)
+
+	length := Lerp(robinsonX[i], robinsonX[i+1], w)
+	phi := 2 * math.Pi * x / length
+	if math.Abs(phi) > math.Pi {
+		return geodesic.Angle{}, false
+	}
+	return geodesic.Angle{Theta: theta, Phi: phi}, true
+}
+
+func (Robinson) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	f := math.Min(math.Abs(a.Theta)/robinsonStep, float64(len(robinsonY)-1))
+	i := int(f)
+	if i >= len(robinsonY)-1 {
+		i = len(robinsonY) - 2
+	}
+	w := f - float64(i)
+
+	x := Lerp(robinsonX[i], robinsonX[i+1], w) * wrap(a.Phi) / (2 * math.Pi)
+	y := math.Copysign(Lerp(robinsonY[i], robinsonY[i+1], w), a.Theta) / 2
+	return x, y, true
+}
@@ -1,10 +1,8 @@
 package render
 
 import (
-	"github.com/willbeason/worldproc/pkg/geodesic"
 	"image"
 	"image/color"
-	"math"
 	"sync"
 )
 
@@ -22,70 +20,8 @@ var (
 	snow        = color.RGBA{255, 255, 255, 255}
 )
 
-var landWaterCS = NewColorScale(
-	[]ColorPoint{
-		{-0.1, deepWater},
-		{-0.01, color.RGBA{0, 32, 255, 255}},
-		{0, color.RGBA{0, 128, 255, 255}},
-		{0.02, color.RGBA{192, 192, 32, 255}},
-		{0.1, color.RGBA{0, 192, 0, 255}},
-		{0.15, color.RGBA{64, 128, 64, 255}},
-		{0.2, color.RGBA{64, 96, 64, 255}},
-		{0.5, color.RGBA{192, 192, 192, 255}},
-		{1.0, color.RGBA{255, 255, 255, 255}},
-	})
-
-func (s Screen) PaintAndShade(heights []float64, lightAngles []geodesic.Angle, img *image.RGBA) {
-	wg := sync.WaitGroup{}
-	wg.Add(s.Width)
-
-	for x := 0; x < s.Width; x++ {
-		xl := x
-		go func() {
-			for y := 0; y < s.Height; y++ {
-				idx := y*s.Width + xl
-				h := heights[idx]
-
-				h = h / 2.6
-				if h > 1.0 || h < -1.0 {
-					panic(h)
-				}
-
-				c := landWaterCS.ColorAt(h)
-				c = s.shadow(c, heights, xl, y, idx, lightAngles[idx])
-
-				img.Set(xl, y, c)
-			}
-			wg.Done()
-		}()
-
-	}
-	wg.Wait()
-}
-
-func (s Screen) shadow(c color.RGBA, heights []float64, x, y, idx int, lightAngle geodesic.Angle) color.RGBA {
-	if x <= 0 || x >= s.Width-1 || y <= 0 || y >= s.Height-1 {
-		return c
-	}
-
-	hl := heights[idx-1]
-	hr := heights[idx+1]
-	hu := heights[idx+s.Width]
-	hd := heights[idx-s.Width]
-	m := 0.1
-	if lightAngle.Theta > 0 {
-		m = gradient(hl, hr, hu, hd).Dot(lightAngle.Vector())
-		m = math.Max(0.1, m)
-	}
-
-	c.R = uint8(float64(c.R) * m)
-	c.G = uint8(float64(c.G) * m)
-	c.B = uint8(float64(c.B) * m)
-
-	return c
-}
-
-var landCS = NewColorScale(
+// LandColorScale is the default color of land by its height.
+var LandColorScale = NewColorScale(
 	[]ColorPoint{
 		{0, sand},
 		{0.05, brightGreen},
@@ -95,31 +31,36 @@ var landCS = NewColorScale(
 		{1.0, snow},
 	})
 
-func (s Screen) PaintLandWater(heights, waters, lights []float64, lightAngles []geodesic.Angle, img *image.RGBA) {
-	for x := 0; x < s.Width; x++ {
-		for y := 0; y < s.Height; y++ {
-			idx := y*s.Width + x
-
-			w := waters[idx]
-			h := heights[idx]
-
-			c := landCS.ColorAt(h)
+// TerrainColor returns the unshaded color of a cell with the given height
+// and depth of water, as PaintLandWater paints it. land colors dry land by its
+// height.
+func TerrainColor(land *ColorScale, height, water float64) color.RGBA {
+	switch {
+	case water > 0.01:
+		return deepWater
+	case water > 0.0:
+		return lerpC(land.ColorAt(height), deepWater, water/0.01)
+	default:
+		return land.ColorAt(height)
+	}
+}
 
-			if w > 0.01 {
-				c = deepWater
-				c = lerpC(color.RGBA{A: 255}, c, lights[idx])
-			} else {
-				c = s.shadow(c, heights, x, y, idx, lightAngles[idx])
-				if w > 0.0 {
-					c = lerpC(c, deepWater, w/0.01)
-				}
-			}
-			img.Set(x, y, c)
+// PaintLandWater paints every pixel with the terrain color of its height and
+// depth of water, with land colored by land, darkened by its shade from 0 to
+// 1.
+func (s Screen) PaintLandWater(heights, waters, shades []float64, land *ColorScale, img *image.RGBA) {
+	parallelRows(s.Height, func(y int) {
+		for x := 0; x < s.Width; x++ {
+			idx := y*s.Width + x
+			c := TerrainColor(land, heights[idx], waters[idx])
+			c = lerpC(color.RGBA{A: 255}, c, shades[idx])
+			img.SetRGBA(x, y, c)
 		}
-	}
+	})
 }
 
-var temperatureCS = NewColorScale(
+// TemperatureColorScale is the default color of air temperatures, in Kelvin.
+var TemperatureColorScale = NewColorScale(
 	[]ColorPoint{
 		{223, color.RGBA{R: 255, G: 255, B: 255, A: 255}}, // -50 C
 		{233, color.RGBA{R: 255, G: 0, B: 255, A: 255}}, // -40 C
@@ -134,52 +75,23 @@ var temperatureCS = NewColorScale(
 		{323, color.RGBA{R: 128, G: 0, B: 0, A: 255}}, // 50 C
 	})
 
-func (s Screen) PaintTemperature(temperatures []float64, img *image.RGBA) {
-	for x := 0; x < s.Width; x++ {
-		for y := 0; y < s.Height; y++ {
-			idx := y*s.Width + x
-			t := temperatures[idx]
-			c := temperatureCS.ColorAt(t)
-			img.Set(x, y, c)
-		}
-	}
-}
-
-var airVelocityCS = NewColorScale(
+// AirVelocityColorScale is the default color of wind speeds.
+var AirVelocityColorScale = NewColorScale(
 	[]ColorPoint{
 		{0.0, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
 		{0.1, color.RGBA{R: 255, G: 0, B: 0, A: 255}},
 	})
 
-func (s Screen) PaintAirVelocities(airVelocities []float64, img *image.RGBA) {
-	for x := 0; x < s.Width; x++ {
-		for y := 0; y < s.Height; y++ {
-			idx := y*s.Width + x
-			t := airVelocities[idx]
-			c := airVelocityCS.ColorAt(t)
-			img.Set(x, y, c)
-		}
-	}
-}
-
-var airPressureCS = NewColorScale(
+// AirPressureColorScale is the default color of air pressures, relative to
+// the average.
+var AirPressureColorScale = NewColorScale(
 	[]ColorPoint{
 		{0.8, color.RGBA{R: 255, G: 0, B: 0, A: 255}},
 		{1.0, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
 		{1.2, color.RGBA{R: 0, G: 0, B: 255, A: 255}},
 	})
 
-func (s Screen) PaintAirPressure(airPressures []float64, img *image.RGBA) {
-	for x := 0; x < s.Width; x++ {
-		for y := 0; y < s.Height; y++ {
-			idx := y*s.Width + x
-			t := airPressures[idx]
-			c := airPressureCS.ColorAt(t)
-			img.Set(x, y, c)
-		}
-	}
-}
-
+// Paint paints every pixel with the color cs gives its value.
 func (s Screen) Paint(heights []float64, cs *ColorScale, img *image.RGBA) {
 	wg := sync.WaitGroup{}
 	wg.Add(s.Width)
@@ -201,8 +113,12 @@ func (s Screen) Paint(heights []float64, cs *ColorScale, img *image.RGBA) {
 	wg.Wait()
 }
 
-func gradient(l, r, u, d float64) geodesic.Vector {
-	dx := l - r
-	dy := d - u
-	return geodesic.Vector{X: dx, Y: dy, Z: 0.2}.Normalize()
+// PaintNearest paints values with the color of the nearest threshold of cs,
+// as for categories which have no values between them.
+func (s Screen) PaintNearest(values []float64, cs *ColorScale, img *image.RGBA) {
+	parallelRows(s.Height, func(y int) {
+		for x := 0; x < s.Width; x++ {
+			img.SetRGBA(x, y, cs.NearestColor(values[y*s.Width+x]))
+		}
+	})
 }
@@ -0,0 +1,290 @@
+package serve
+
+// page is the globe viewer. It draws textures from the API on a sphere with
+// WebGL, and needs nothing but the Server to run.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>worldproc</title>
+<style>
+html, body { margin: 0; height: 100%; overflow: hidden; background: #000; color: #ddd; font: 14px sans-serif; }
+canvas { display: block; width: 100%; height: 100%; cursor: grab; }
+#controls { position: absolute; top: 8px; left: 8px; padding: 8px; background: rgba(0, 0, 0, 0.6); border-radius: 4px; }
+#controls label { display: block; margin: 4px 0; }
+#snapshot { width: 240px; vertical-align: middle; }
+</style>
+</head>
+<body>
+<canvas id="globe"></canvas>
+<div id="controls">
+  <label>Layer <select id="layer"></select></label>
+  <label id="snapshots" hidden>
+    <button id="play" type="button">Play</button>
+    <input id="snapshot" type="range" min="0" value="0">
+    <span id="date"></span>
+  </label>
+  <div id="status"></div>
+</div>
+<script>
+"use strict";
+
+const canvas = document.getElementById("globe");
+const layerSelect = document.getElementById("layer");
+const snapshotInput = document.getElementById("snapshot");
+const playButton = document.getElementById("play");
+const dateText = document.getElementById("date");
+const statusText = document.getElementById("status");
+
+const gl = canvas.getContext("webgl");
+if (!gl) {
+  statusText.textContent = "This browser doesn't support WebGL.";
+  throw new Error("no WebGL");
+}
+
+const vertexSource = [
+  "attribute vec3 position;",
+  "attribute vec2 uv;",
+  "uniform mat4 matrix;",
+  "varying vec2 vUV;",
+  "void main() {",
+  "  vUV = uv;",
+  "  gl_Position = matrix * vec4(position, 1.0);",
+  "}",
+].join("\n");
+
+const fragmentSource = [
+  "precision mediump float;",
+  "uniform sampler2D map;",
+  "varying vec2 vUV;",
+  "void main() {",
+  "  gl_FragColor = texture2D(map, vUV);",
+  "}",
+].join("\n");
+
+function compile(type, source) {
+  const shader = gl.createShader(type);
+  gl.shaderSource(shader, source);
+  gl.compileShader(shader);
+  if (!gl.getShaderParameter(shader, gl.COMPILE_STATUS)) {
+    throw new Error(gl.getShaderInfoLog(shader));
+  }
+  return shader;
+}
+
+const program = gl.createProgram();
+gl.attachShader(program, compile(gl.VERTEX_SHADER, vertexSource));
+gl.attachShader(program, compile(gl.FRAGMENT_SHADER, fragmentSource));
+gl.linkProgram(program);
+if (!gl.getProgramParameter(program, gl.LINK_STATUS)) {
+  throw new Error(gl.getProgramInfoLog(program));
+}
+gl.useProgram(program);
+
+// The globe is a sphere of latitude and longitude lines, so equirectangular
+// textures map straight onto it. Textures have south in their first row, and
+// the planet's north pole is up.
+const nLat = 90, nLon = 180;
+const vertices = [];
+for (let i = 0; i <= nLat; i++) {
+  const lat = -Math.PI / 2 + Math.PI * i / nLat;
+  for (let j = 0; j <= nLon; j++) {
+    const lon = -Math.PI + 2 * Math.PI * j / nLon;
+    const x = Math.cos(lat) * Math.cos(lon);
+    const y = Math.cos(lat) * Math.sin(lon);
+    const z = Math.sin(lat);
+    vertices.push(x, z, -y, j / nLon, i / nLat);
+  }
+}
+const indices = [];
+for (let i = 0; i < nLat; i++) {
+  for (let j = 0; j < nLon; j++) {
+    const a = i * (nLon + 1) + j, b = a + nLon + 1;
+    indices.push(a, b, a + 1, a + 1, b, b + 1);
+  }
+}
+
+gl.bindBuffer(gl.ARRAY_BUFFER, gl.createBuffer());
+gl.bufferData(gl.ARRAY_BUFFER, new Float32Array(vertices), gl.STATIC_DRAW);
+gl.bindBuffer(gl.ELEMENT_ARRAY_BUFFER, gl.createBuffer());
+gl.bufferData(gl.ELEMENT_ARRAY_BUFFER, new Uint16Array(indices), gl.STATIC_DRAW);
+
+const position = gl.getAttribLocation(program, "position");
+const uv = gl.getAttribLocation(program, "uv");
+gl.enableVertexAttribArray(position);
+gl.vertexAttribPointer(position, 3, gl.FLOAT, false, 20, 0);
+gl.enableVertexAttribArray(uv);
+gl.vertexAttribPointer(uv, 2, gl.FLOAT, false, 20, 12);
+const matrixLocation = gl.getUniformLocation(program, "matrix");
+
+const texture = gl.createTexture();
+gl.bindTexture(gl.TEXTURE_2D, texture);
+gl.texImage2D(gl.TEXTURE_2D, 0, gl.RGBA, 1, 1, 0, gl.RGBA, gl.UNSIGNED_BYTE, new Uint8Array([64, 64, 64, 255]));
+gl.texParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE);
+gl.texParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE);
+gl.texParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR);
+gl.texParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR);
+
+// Matrices are column-major, as WebGL takes them.
+function multiply(a, b) {
+  const result = new Float32Array(16);
+  for (let col = 0; col < 4; col++) {
+    for (let row = 0; row < 4; row++) {
+      let sum = 0;
+      for (let k = 0; k < 4; k++) {
+        sum += a[k * 4 + row] * b[col * 4 + k];
+      }
+      result[col * 4 + row] = sum;
+    }
+  }
+  return result;
+}
+
+function perspective(fovy, aspect, near, far) {
+  const f = 1 / Math.tan(fovy / 2);
+  return new Float32Array([
+    f / aspect, 0, 0, 0,
+    0, f, 0, 0,
+    0, 0, (far + near) / (near - far), -1,
+    0, 0, 2 * far * near / (near - far), 0,
+  ]);
+}
+
+function translateZ(z) {
+  return new Float32Array([1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, z, 1]);
+}
+
+function rotateX(a) {
+  const c = Math.cos(a), s = Math.sin(a);
+  return new Float32Array([1, 0, 0, 0, 0, c, s, 0, 0, -s, c, 0, 0, 0, 0, 1]);
+}
+
+function rotateY(a) {
+  const c = Math.cos(a), s = Math.sin(a);
+  return new Float32Array([c, 0, -s, 0, 0, 1, 0, 0, s, 0, c, 0, 0, 0, 0, 1]);
+}
+
+let yaw = 0, pitch = 0.3, distance = 3;
+let dragging = null;
+
+canvas.addEventListener("mousedown", function (e) {
+  dragging = {x: e.clientX, y: e.clientY};
+  canvas.style.cursor = "grabbing";
+});
+window.addEventListener("mouseup", function () {
+  dragging = null;
+  canvas.style.cursor = "grab";
+});
+window.addEventListener("mousemove", function (e) {
+  if (!dragging) {
+    return;
+  }
+  // Turn the globe less when zoomed in, so the surface follows the mouse.
+  const speed = 0.005 * (distance - 1) / 2;
+  yaw += (e.clientX - dragging.x) * speed;
+  pitch += (e.clientY - dragging.y) * speed;
+  pitch = Math.max(-Math.PI / 2, Math.min(Math.PI / 2, pitch));
+  dragging = {x: e.clientX, y: e.clientY};
+});
+canvas.addEventListener("wheel", function (e) {
+  e.preventDefault();
+  distance = Math.max(1.2, Math.min(10, distance * Math.exp(e.deltaY * 0.001)));
+}, {passive: false});
+
+function draw() {
+  const width = canvas.clientWidth * window.devicePixelRatio;
+  const height = canvas.clientHeight * window.devicePixelRatio;
+  if (canvas.width !== width || canvas.height !== height) {
+    canvas.width = width;
+    canvas.height = height;
+  }
+  gl.viewport(0, 0, canvas.width, canvas.height);
+  gl.clearColor(0, 0, 0, 1);
+  gl.enable(gl.DEPTH_TEST);
+  gl.clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT);
+
+  let matrix = perspective(Math.PI / 4, canvas.width / canvas.height, 0.01, 100);
+  matrix = multiply(matrix, translateZ(-distance));
+  matrix = multiply(matrix, rotateX(pitch));
+  matrix = multiply(matrix, rotateY(yaw));
+  gl.uniformMatrix4fv(matrixLocation, false, matrix);
+  gl.drawElements(gl.TRIANGLES, indices.length, gl.UNSIGNED_SHORT, 0);
+  requestAnimationFrame(draw);
+}
+requestAnimationFrame(draw);
+
+// load counts texture requests, so textures which arrive after a newer one
+// was asked for are dropped.
+let load = 0;
+let snapshots = [];
+
+function loadTexture() {
+  const n = ++load;
+  const url = "api/textures/" + encodeURIComponent(layerSelect.value) +
+    ".png?width=2048&snapshot=" + snapshotInput.value;
+  if (snapshots.length > 0) {
+    dateText.textContent = snapshots[snapshotInput.value].label;
+  }
+  statusText.textContent = "Loading...";
+
+  const img = new Image();
+  img.onload = function () {
+    if (n !== load) {
+      return;
+    }
+    gl.bindTexture(gl.TEXTURE_2D, texture);
+    gl.texImage2D(gl.TEXTURE_2D, 0, gl.RGBA, gl.RGBA, gl.UNSIGNED_BYTE, img);
+    statusText.textContent = "";
+  };
+  img.onerror = function () {
+    if (n === load) {
+      statusText.textContent = "Couldn't load " + url;
+    }
+  };
+  img.src = url;
+}
+
+let playing = null;
+playButton.addEventListener("click", function () {
+  if (playing) {
+    clearInterval(playing);
+    playing = null;
+    playButton.textContent = "Play";
+    return;
+  }
+  playButton.textContent = "Pause";
+  playing = setInterval(function () {
+    snapshotInput.value = (Number(snapshotInput.value) + 1) % snapshots.length;
+    loadTexture();
+  }, 500);
+});
+
+layerSelect.addEventListener("change", loadTexture);
+snapshotInput.addEventListener("input", loadTexture);
+
+fetch("api/planet").then(function (response) {
+  if (!response.ok) {
+    throw new Error(response.statusText);
+  }
+  return response.json();
+}).then(function (info) {
+  for (const name of ["terrain"].concat(info.layers.map(function (l) { return l.name; }))) {
+    const option = document.createElement("option");
+    option.value = name;
+    option.textContent = name;
+    layerSelect.appendChild(option);
+  }
+
+  snapshots = info.snapshots;
+  if (snapshots.length > 0) {
+    snapshotInput.max = snapshots.length - 1;
+    document.getElementById("snapshots").hidden = false;
+  }
+  loadTexture();
+}).catch(function (err) {
+  statusText.textContent = "Couldn't load planet: " + err.message;
+});
+</script>
+</body>
+</html>
+`
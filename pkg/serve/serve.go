@@ -0,0 +1,433 @@
+// Package serve serves a planet to a globe viewer in the browser over HTTP.
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/export"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/sun"
+	"image"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TerrainTexture is the name of the texture of the land and water of the
+// planet, shaded as planet.RenderTerrain shades it.
+const TerrainTexture = "terrain"
+
+// Texture widths Server renders, in pixels. Widths are powers of two, so
+// Server keeps the cells of few sizes of texture. Textures are
+// equirectangular, so are half as tall as they are wide.
+const (
+	DefaultTextureWidth = 2048
+	MaxTextureWidth     = 8192
+	minTextureWidth     = 16
+)
+
+// Exaggerations Server raises the surface of meshes by. Exaggerations are
+// rounded to the nearest ExaggerationStep, so Server keeps few meshes.
+const (
+	MaxExaggeration  = 0.2
+	ExaggerationStep = 0.01
+)
+
+// meshCacheSize is how many encoded meshes Server keeps.
+const meshCacheSize = 8
+
+// DefaultColorScales are the color scales Server paints the layers
+// planet.ClimateLayers derives with, matching the renders of cmd/gen.
+var DefaultColorScales = map[string]*render.ColorScale{
+	planet.TemperatureLayer: render.TemperatureColorScale,
+	planet.WindLayer:        render.AirVelocityColorScale,
+	planet.PressureLayer:    render.AirPressureColorScale,
+}
+
+// Server serves a planet over HTTP:
+//
+//	/                      the globe viewer
+//	/api/planet            the layers and snapshots of the planet, as JSON
+//	/api/layers/NAME       the values of a layer, as JSON
+//	/api/mesh.glb          the surface of the planet, as binary glTF
+//	/api/textures/NAME.png an equirectangular render of a layer, or of terrain
+//
+// Layers and textures are of the snapshot the snapshot parameter indexes, or
+// the first. Textures are as wide as the width parameter, a power of two, and
+// the mesh raises the surface by the exaggeration parameter. Server keeps the
+// meshes it has encoded most recently.
+//
+// It is safe for concurrent use once serving, and its fields must not be
+// changed afterward.
+type Server struct {
+	Planet *planet.Planet
+	// Sphere is the sphere whose cells Planet has values for.
+	Sphere *geodesic.Geodesic
+	// Snapshots are the states of the climate of Planet to choose between. If
+	// empty, the climate of Planet is shown.
+	Snapshots []planet.Snapshot
+
+	// Light shades terrain, or if nil it is lit evenly from above.
+	Light sun.Light
+	// ColorScales paint layers by name, or DefaultColorScales if nil. Other
+	// layers are painted with viridis from their least to greatest value.
+	ColorScales map[string]*render.ColorScale
+	// Land colors dry land by its height in the terrain texture and the mesh,
+	// or render.LandColorScale does if it is nil.
+	Land *render.ColorScale
+
+	once   sync.Once
+	mux    *http.ServeMux
+	shades []float64
+	// cellMaps holds the equirectangular CellMap of each texture width.
+	cellMaps *render.CellMaps
+
+	meshMu sync.Mutex
+	// meshes holds the most recent encoded meshes, and meshOrder their keys
+	// from oldest to newest.
+	meshes    map[meshKey]*meshEntry
+	meshOrder []meshKey
+}
+
+type meshKey struct {
+	snapshot int
+	// steps is the exaggeration in ExaggerationSteps.
+	steps int
+}
+
+// meshEntry is a mesh which is encoded once, by whichever request asks for it
+// first, while other requests for it wait.
+type meshEntry struct {
+	once sync.Once
+	glb  []byte
+	err  error
+}
+
+func (s *Server) init() {
+	light := s.Light
+	if light == nil {
+		light = sun.Constant{}
+	}
+	s.shades = planet.DefaultHillshade.Shade(s.Planet, s.Sphere, light)
+	s.cellMaps = render.NewCellMaps(s.Sphere)
+	s.meshes = make(map[meshKey]*meshEntry)
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/", s.servePage)
+	s.mux.HandleFunc("/api/planet", s.serveInfo)
+	s.mux.HandleFunc("/api/layers/", s.serveLayer)
+	s.mux.HandleFunc("/api/mesh.glb", s.serveMesh)
+	s.mux.HandleFunc("/api/textures/", s.serveTexture)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.once.Do(s.init)
+	s.mux.ServeHTTP(w, r)
+}
+
+// Info describes what a Server has to show.
+type Info struct {
+	Cells     int            `json:"cells"`
+	Layers    []LayerInfo    `json:"layers"`
+	Snapshots []SnapshotInfo `json:"snapshots"`
+}
+
+type LayerInfo struct {
+	Name  string           `json:"name"`
+	Kind  planet.LayerKind `json:"kind"`
+	Units string           `json:"units,omitempty"`
+}
+
+type SnapshotInfo struct {
+	Date float64 `json:"date"`
+	// Label is Date as sun.FormatDate writes it.
+	Label string `json:"label"`
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(page))
+}
+
+func (s *Server) serveInfo(w http.ResponseWriter, r *http.Request) {
+	p, err := s.planetAt(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info := Info{
+		Cells:     len(s.Sphere.Centers),
+		Layers:    []LayerInfo{},
+		Snapshots: []SnapshotInfo{},
+	}
+	for _, name := range p.LayerNames() {
+		l := p.Layer(name)
+		info.Layers = append(info.Layers, LayerInfo{Name: l.Name, Kind: l.Kind, Units: l.Units})
+	}
+	for _, snapshot := range s.Snapshots {
+		info.Snapshots = append(info.Snapshots, SnapshotInfo{Date: snapshot.Date, Label: sun.FormatDate(snapshot.Date)})
+	}
+	writeJSON(w, info)
+}
+
+func (s *Server) serveLayer(w http.ResponseWriter, r *http.Request) {
+	p, err := s.planetAt(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	l := p.Layer(strings.TrimPrefix(r.URL.Path, "/api/layers/"))
+	if l == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, l)
+}
+
+func (s *Server) serveMesh(w http.ResponseWriter, r *http.Request) {
+	exaggeration, err := floatParam(r, "exaggeration", planet.DefaultHillshade.Exaggeration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if exaggeration < 0 || exaggeration > MaxExaggeration {
+		http.Error(w, fmt.Sprintf("exaggeration must be from 0 to %g", MaxExaggeration), http.StatusBadRequest)
+		return
+	}
+	snapshot, err := s.snapshot(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := meshKey{snapshot: snapshot, steps: int(math.Round(exaggeration / ExaggerationStep))}
+	entry := s.mesh(key)
+	entry.once.Do(func() {
+		entry.glb, entry.err = s.encodeMesh(key)
+	})
+	if entry.err != nil {
+		http.Error(w, entry.err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "model/gltf-binary")
+	_, _ = w.Write(entry.glb)
+}
+
+func (s *Server) serveTexture(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/textures/")
+	if !strings.HasSuffix(name, ".png") {
+		http.NotFound(w, r)
+		return
+	}
+	name = strings.TrimSuffix(name, ".png")
+
+	width, err := intParam(r, "width", DefaultTextureWidth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if width < minTextureWidth || width > MaxTextureWidth || width&(width-1) != 0 {
+		http.Error(w, fmt.Sprintf("width must be a power of two from %d to %d", minTextureWidth, MaxTextureWidth), http.StatusBadRequest)
+		return
+	}
+	p, err := s.planetAt(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cells := s.cellMap(width)
+	var img *image.RGBA
+	if name == TerrainTexture {
+		img = planet.RenderShadedTerrain(p, cells, s.shades, s.land())
+	} else {
+		l := p.Layer(name)
+		if l == nil {
+			http.NotFound(w, r)
+			return
+		}
+		img, err = planet.RenderLayer(p, name, cells, s.colorScale(l))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err = png.Encode(buf, img)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// planetAt returns Planet with the climate of the snapshot r asks for, and
+// the layers derived from it.
+func (s *Server) planetAt(r *http.Request) (*planet.Planet, error) {
+	snapshot, err := s.snapshot(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.planetOf(snapshot), nil
+}
+
+// snapshot returns the index of the snapshot r asks for, or zero if there are
+// no Snapshots.
+func (s *Server) snapshot(r *http.Request) (int, error) {
+	if len(s.Snapshots) == 0 {
+		return 0, nil
+	}
+	index, err := floatParam(r, "snapshot", 0)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 || int(index) >= len(s.Snapshots) || index != math.Trunc(index) {
+		return 0, fmt.Errorf("snapshot must be from 0 to %d", len(s.Snapshots)-1)
+	}
+	return int(index), nil
+}
+
+// planetOf returns Planet with the climate of the snapshot at index, and the
+// layers derived from it.
+func (s *Server) planetOf(index int) *planet.Planet {
+	p := *s.Planet
+	if len(s.Snapshots) > 0 {
+		p.Climates = s.Snapshots[index].Climates
+	}
+
+	// Leave the layers of Planet as they are, and keep any of its own
+	// layers with the same names as climate layers.
+	p.Layers = p.Layers[:len(p.Layers):len(p.Layers)]
+	if len(p.Climates) > 0 {
+		for _, l := range planet.ClimateLayers(p.Climates) {
+			if p.Layer(l.Name) == nil {
+				p.Layers = append(p.Layers, l)
+			}
+		}
+	}
+	return &p
+}
+
+// mesh returns the entry of the mesh of key, adding it if there is none and
+// dropping the oldest entry if there are more than meshCacheSize.
+func (s *Server) mesh(key meshKey) *meshEntry {
+	s.meshMu.Lock()
+	defer s.meshMu.Unlock()
+
+	entry, ok := s.meshes[key]
+	if ok {
+		return entry
+	}
+	entry = &meshEntry{}
+	s.meshes[key] = entry
+	s.meshOrder = append(s.meshOrder, key)
+	if len(s.meshOrder) > meshCacheSize {
+		delete(s.meshes, s.meshOrder[0])
+		s.meshOrder = s.meshOrder[1:]
+	}
+	return entry
+}
+
+// encodeMesh returns the binary glTF of the mesh of key.
+func (s *Server) encodeMesh(key meshKey) ([]byte, error) {
+	exaggeration := float64(key.steps) * ExaggerationStep
+	m, err := export.FromPlanet(s.planetOf(key.snapshot), s.Sphere, exaggeration, s.land())
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	err = export.WriteGLB(buf, m)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cellMap returns the CellMap of equirectangular textures width pixels wide.
+func (s *Server) cellMap(width int) *render.CellMap {
+	return s.cellMaps.Get(render.Screen{Width: width, Height: width / 2}, render.Equirectangular{})
+}
+
+// land returns the color scale to paint dry land with.
+func (s *Server) land() *render.ColorScale {
+	if s.Land == nil {
+		return render.LandColorScale
+	}
+	return s.Land
+}
+
+// colorScale returns the color scale to paint l with.
+func (s *Server) colorScale(l *planet.Layer) *render.ColorScale {
+	scales := s.ColorScales
+	if scales == nil {
+		scales = DefaultColorScales
+	}
+	if cs, found := scales[l.Name]; found {
+		return cs
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range l.Magnitudes() {
+		min, max = math.Min(min, v), math.Max(max, v)
+	}
+	if min >= max {
+		max = min + 1
+	}
+	cs, err := render.Palette("viridis")
+	if err != nil {
+		panic(err)
+	}
+	return cs.Rescale(min, max)
+}
+
+// floatParam returns the query parameter of r named name, or def if r doesn't
+// set it.
+func floatParam(r *http.Request, name string, def float64) (float64, error) {
+	q := r.URL.Query().Get(name)
+	if q == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(q, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%s must be a number, got %q", name, q)
+	}
+	return v, nil
+}
+
+// intParam returns the query parameter of r named name, or def if r doesn't
+// set it.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	q := r.URL.Query().Get(name)
+	if q == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(q)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer, got %q", name, q)
+	}
+	return v, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
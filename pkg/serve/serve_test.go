@@ -0,0 +1,231 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/climate"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newTestServer() *Server {
+	g := geodesic.New(2, true)[2]
+	p := &planet.Planet{
+		Heights:  make([]float64, len(g.Centers)),
+		Waters:   make([]float64, len(g.Centers)),
+		Climates: make([]climate.Climate, len(g.Centers)),
+	}
+	var snapshots []planet.Snapshot
+	for i, c := range g.Centers {
+		p.Heights[i] = c.Z
+		p.Climates[i] = climate.Climate{Air: 1, AirEnergy: climate.AirSpecificHeat * 280}
+	}
+	for _, kelvin := range []float64{270, 290} {
+		climates := make([]climate.Climate, len(g.Centers))
+		for i := range climates {
+			climates[i] = climate.Climate{Air: 1, AirEnergy: climate.AirSpecificHeat * kelvin}
+		}
+		snapshots = append(snapshots, planet.Snapshot{Date: kelvin - 270, Climates: climates})
+	}
+
+	return &Server{Planet: p, Sphere: g, Snapshots: snapshots}
+}
+
+func get(t *testing.T, s http.Handler, url string) *http.Response {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w.Result()
+}
+
+func TestServer_Info(t *testing.T) {
+	s := newTestServer()
+
+	resp := get(t, s, "/api/planet")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	got := Info{}
+	err := json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Info{
+		Cells: 162,
+		Layers: []LayerInfo{
+			{Name: planet.HeightsLayer, Kind: planet.Scalar},
+			{Name: planet.WatersLayer, Kind: planet.Scalar},
+			{Name: planet.TemperatureLayer, Kind: planet.Scalar, Units: "K"},
+			{Name: planet.WindLayer, Kind: planet.Vector},
+			{Name: planet.PressureLayer, Kind: planet.Scalar},
+		},
+		Snapshots: []SnapshotInfo{
+			{Date: 0, Label: "Year 0 Day 0 12:00"},
+			{Date: 20, Label: "Year 0 Day 20 12:00"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestServer_Layer(t *testing.T) {
+	s := newTestServer()
+
+	for snapshot, want := range []float64{270, 290} {
+		resp := get(t, s, "/api/layers/temperature?snapshot="+strconv.Itoa(snapshot))
+		got := planet.Layer{}
+		err := json.NewDecoder(resp.Body).Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Len() != 162 || got.Scalars[0] != want {
+			t.Errorf("got %d temperatures starting with %v, want 162 starting with %v", got.Len(), got.Scalars[0], want)
+		}
+	}
+
+	// The planet itself keeps its own climate.
+	if got := s.Planet.Climates[0].AirTemperature(); got != 280 {
+		t.Errorf("got temperature %v, want 280", got)
+	}
+	if s.Planet.Layer(planet.TemperatureLayer) != nil {
+		t.Error("serving snapshot added layer to planet")
+	}
+
+	tcs := []struct {
+		url  string
+		want int
+	}{
+		{url: "/api/layers/rainfall", want: http.StatusNotFound},
+		{url: "/api/layers/temperature?snapshot=2", want: http.StatusBadRequest},
+		{url: "/api/layers/temperature?snapshot=x", want: http.StatusBadRequest},
+	}
+	for _, tc := range tcs {
+		if got := get(t, s, tc.url).StatusCode; got != tc.want {
+			t.Errorf("got status %d for %s, want %d", got, tc.url, tc.want)
+		}
+	}
+}
+
+func TestServer_Texture(t *testing.T) {
+	s := newTestServer()
+
+	for _, name := range []string{TerrainTexture, planet.HeightsLayer, planet.WindLayer} {
+		resp := get(t, s, "/api/textures/"+name+".png?width=64")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d, want %d", name, resp.StatusCode, http.StatusOK)
+		}
+		img, err := png.Decode(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := img.Bounds().Size(); got.X != 64 || got.Y != 32 {
+			t.Errorf("%s: got %v texture, want 64 by 32", name, got)
+		}
+	}
+
+	for _, width := range []string{"100000", "8", "100", "64.5", "x"} {
+		if got := get(t, s, "/api/textures/terrain.png?width="+width).StatusCode; got != http.StatusBadRequest {
+			t.Errorf("got status %d for width %s, want %d", got, width, http.StatusBadRequest)
+		}
+	}
+	if got := s.cellMaps.Len(); got != 1 {
+		t.Errorf("got %d cached cell maps, want 1", got)
+	}
+}
+
+func TestServer_Mesh(t *testing.T) {
+	s := newTestServer()
+
+	glb := func(url string) []byte {
+		t.Helper()
+		resp := get(t, s, url)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d, want %d", url, resp.StatusCode, http.StatusOK)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	// Exaggerations round to the same step, so share a mesh.
+	first := glb("/api/mesh.glb?exaggeration=0.1&snapshot=1")
+	second := glb("/api/mesh.glb?exaggeration=0.1004&snapshot=1")
+	if !bytes.Equal(first, second) {
+		t.Error("got different meshes for exaggerations of the same step")
+	}
+	if bytes.Equal(first, glb("/api/mesh.glb?exaggeration=0.2&snapshot=1")) {
+		t.Error("got the same mesh for different exaggerations")
+	}
+	if got := len(s.meshes); got != 2 {
+		t.Errorf("got %d cached meshes, want 2", got)
+	}
+
+	for i := 0; i <= meshCacheSize; i++ {
+		glb("/api/mesh.glb?exaggeration=" + strconv.FormatFloat(float64(i)*ExaggerationStep, 'f', -1, 64))
+	}
+	if got := len(s.meshes); got != meshCacheSize {
+		t.Errorf("got %d cached meshes, want %d", got, meshCacheSize)
+	}
+
+	for _, exaggeration := range []string{"-0.01", "0.3", "1000", "x"} {
+		if got := get(t, s, "/api/mesh.glb?exaggeration="+exaggeration).StatusCode; got != http.StatusBadRequest {
+			t.Errorf("got status %d for exaggeration %s, want %d", got, exaggeration, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestServer_Land(t *testing.T) {
+	s := newTestServer()
+	red := color.RGBA{R: 255, A: 255}
+	s.Land = render.NewColorScale([]render.ColorPoint{{Threshold: 0, Color: red}, {Threshold: 1, Color: red}})
+
+	resp := get(t, s, "/api/textures/"+TerrainTexture+".png?width=64")
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The test planet is dry, so is red everywhere, darkened by shading.
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, g, b, _ := img.At(x, y).RGBA(); g != 0 || b != 0 {
+				t.Fatalf("got color %v at (%d, %d), want a shade of red", img.At(x, y), x, y)
+			}
+		}
+	}
+}
+
+func TestServer_Page(t *testing.T) {
+	s := newTestServer()
+
+	resp := get(t, s, "/")
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || len(body) != len(page) {
+		t.Errorf("got status %d and %d bytes, want %d and %d", resp.StatusCode, len(body), http.StatusOK, len(page))
+	}
+
+	resp = get(t, s, "/api/mesh.glb")
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body[:4]) != "glTF" {
+		t.Errorf("got status %d and mesh starting %q, want %d and glTF", resp.StatusCode, body[:4], http.StatusOK)
+	}
+}
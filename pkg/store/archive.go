@@ -0,0 +1,240 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// archiveMagic starts every archive and indexMagic ends it.
+var (
+	archiveMagic = []byte("WPARCHV1")
+	indexMagic   = []byte("WPAINDEX")
+)
+
+// footerSize is the size of the offset of the index and indexMagic.
+const footerSize = 16
+
+// ErrClosed is returned when using an Archive after closing it.
+var ErrClosed = errors.New("archive is closed")
+
+// Archive is a Store which keeps values in a single file, such as the many
+// small images of a tile pyramid which would be slow to copy as separate
+// files.
+//
+// Values are appended to the file as they are written, and an index of
+// where each one is is written when the Archive is closed. Replacing a value
+// leaves the old one in the file. It is safe for concurrent use.
+type Archive struct {
+	mu     sync.RWMutex
+	file   *os.File
+	index  map[string]archiveEntry
+	end    int64
+	dirty  bool
+	closed bool
+}
+
+type archiveEntry struct {
+	offset int64
+	length uint32
+}
+
+var _ Store = &Archive{}
+
+// CreateArchive creates an empty archive at path, replacing any existing file.
+func CreateArchive(path string) (*Archive, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(archiveMagic)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &Archive{
+		file:  f,
+		index: make(map[string]archiveEntry),
+		end:   int64(len(archiveMagic)),
+		dirty: true,
+	}, nil
+}
+
+// OpenArchive opens the archive at path to read and add values.
+func OpenArchive(path string) (*Archive, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{file: f}
+	err = a.readIndex()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("reading archive %s: %w", path, err)
+	}
+	return a, nil
+}
+
+func (a *Archive) readIndex() error {
+	info, err := a.file.Stat()
+	if err != nil {
+		return err
+	}
+	a.end = info.Size()
+	if a.end < int64(len(archiveMagic)+footerSize) {
+		return errors.New("too short to be an archive")
+	}
+
+	header := make([]byte, len(archiveMagic))
+	_, err = a.file.ReadAt(header, 0)
+	if err != nil {
+		return err
+	}
+	footer := make([]byte, footerSize)
+	_, err = a.file.ReadAt(footer, a.end-footerSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(header, archiveMagic) || !bytes.Equal(footer[8:], indexMagic) {
+		return errors.New("not an archive, or not closed after writing")
+	}
+
+	indexOffset := int64(binary.LittleEndian.Uint64(footer))
+	if indexOffset < int64(len(archiveMagic)) || indexOffset > a.end-footerSize {
+		return errors.New("index out of range")
+	}
+	index := make([]byte, a.end-footerSize-indexOffset)
+	_, err = a.file.ReadAt(index, indexOffset)
+	if err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(index)
+	var count uint32
+	err = binary.Read(r, binary.LittleEndian, &count)
+	if err != nil {
+		return err
+	}
+	a.index = make(map[string]archiveEntry, count)
+	for i := uint32(0); i < count; i++ {
+		var keyLength uint16
+		err = binary.Read(r, binary.LittleEndian, &keyLength)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, keyLength)
+		_, err = io.ReadFull(r, key)
+		if err != nil {
+			return err
+		}
+		var entry struct {
+			Offset uint64
+			Length uint32
+		}
+		err = binary.Read(r, binary.LittleEndian, &entry)
+		if err != nil {
+			return err
+		}
+		if int64(entry.Offset)+int64(entry.Length) > indexOffset {
+			return fmt.Errorf("value %q out of range", key)
+		}
+		a.index[string(key)] = archiveEntry{offset: int64(entry.Offset), length: entry.Length}
+	}
+	return nil
+}
+
+func (a *Archive) Read(key string) ([]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return nil, ErrClosed
+	}
+
+	entry, found := a.index[key]
+	if !found {
+		return nil, &os.PathError{Op: "read", Path: key, Err: os.ErrNotExist}
+	}
+	data := make([]byte, entry.length)
+	_, err := a.file.ReadAt(data, entry.offset)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (a *Archive) Write(key string, data []byte) error {
+	if len(key) > 1<<16-1 {
+		return fmt.Errorf("key of %d bytes is too long", len(key))
+	}
+	if int64(len(data)) > 1<<32-1 {
+		return fmt.Errorf("value of %d bytes is too long", len(data))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return ErrClosed
+	}
+
+	_, err := a.file.WriteAt(data, a.end)
+	if err != nil {
+		return err
+	}
+	a.index[key] = archiveEntry{offset: a.end, length: uint32(len(data))}
+	a.end += int64(len(data))
+	a.dirty = true
+	return nil
+}
+
+// Keys returns the key of every value in the archive, in no particular order.
+func (a *Archive) Keys() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]string, 0, len(a.index))
+	for key := range a.index {
+		result = append(result, key)
+	}
+	return result
+}
+
+// Close writes the index of the archive if it has changed, and closes the
+// file. Values written since the archive was last closed are lost if it isn't
+// closed.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return ErrClosed
+	}
+	a.closed = true
+
+	if a.dirty {
+		err := a.writeIndex()
+		if err != nil {
+			_ = a.file.Close()
+			return err
+		}
+	}
+	return a.file.Close()
+}
+
+func (a *Archive) writeIndex() error {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(a.index)))
+	for key, entry := range a.index {
+		_ = binary.Write(buf, binary.LittleEndian, uint16(len(key)))
+		buf.WriteString(key)
+		_ = binary.Write(buf, binary.LittleEndian, uint64(entry.offset))
+		_ = binary.Write(buf, binary.LittleEndian, entry.length)
+	}
+	_ = binary.Write(buf, binary.LittleEndian, uint64(a.end))
+	buf.Write(indexMagic)
+
+	_, err := a.file.WriteAt(buf.Bytes(), a.end)
+	return err
+}
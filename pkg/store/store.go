@@ -0,0 +1,98 @@
+// Package store persists generated data such as spheres and planets.
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store reads and writes values by key.
+type Store interface {
+	// Read returns the value stored at key.
+	//
+	// If there is no such key, returns an error for which os.IsNotExist is true.
+	Read(key string) ([]byte, error)
+
+	// Write stores data at key, replacing any existing value.
+	Write(key string, data []byte) error
+}
+
+// Mapper is implemented by Stores which can memory-map values rather than
+// copying them into memory.
+type Mapper interface {
+	// Map returns the value stored at key.
+	//
+	// The returned function releases the value and must be called once data is
+	// no longer needed.
+	Map(key string) (data []byte, release func() error, err error)
+}
+
+// Map returns the value at key, memory-mapping it if s supports it.
+func Map(s Store, key string) ([]byte, func() error, error) {
+	if m, ok := s.(Mapper); ok {
+		return m.Map(key)
+	}
+
+	data, err := s.Read(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
+
+// Dir is a Store which keeps values as files in a directory.
+type Dir string
+
+func (d Dir) path(key string) string {
+	return filepath.Join(string(d), filepath.FromSlash(key))
+}
+
+func (d Dir) Read(key string) ([]byte, error) {
+	return ioutil.ReadFile(d.path(key))
+}
+
+// Write stores data at key, creating the directory if it does not exist.
+func (d Dir) Write(key string, data []byte) error {
+	path := d.path(key)
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, os.ModePerm)
+}
+
+func (d Dir) Map(key string) ([]byte, func() error, error) {
+	return mapFile(d.path(key))
+}
+
+// Memory is a Store which keeps values in memory.
+// It is safe for concurrent use.
+type Memory struct {
+	mu     sync.RWMutex
+	values map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{values: make(map[string][]byte)}
+}
+
+func (m *Memory) Read(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, found := m.values[key]
+	if !found {
+		return nil, &os.PathError{Op: "read", Path: key, Err: os.ErrNotExist}
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (m *Memory) Write(key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[key] = append([]byte{}, data...)
+	return nil
+}
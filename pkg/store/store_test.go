@@ -0,0 +1,159 @@
+package store
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := CreateArchive(filepath.Join(dir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	tcs := []struct {
+		name  string
+		store Store
+	}{
+		{name: "dir", store: Dir(dir)},
+		{name: "memory", store: NewMemory()},
+		{name: "archive", store: archive},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.store.Read("nested/missing")
+			if !os.IsNotExist(err) {
+				t.Fatalf("got Read() error %v, want not exist", err)
+			}
+
+			want := []byte("value")
+			err = tc.store.Write("nested/key", want)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := tc.store.Read("nested/key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Error(diff)
+			}
+
+			mapped, release, err := Map(tc.store, "nested/key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, mapped); diff != "" {
+				t.Error(diff)
+			}
+			err = release()
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tiles.archive")
+
+	a, err := CreateArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"0/0/0.png", "1/0/0.png", "1/0/1.png"} {
+		err = a.Write(key, []byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = a.Write("0/0/0.png", []byte("replaced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen the archive and add to it.
+	a, err = OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Write("1/1/0.png", []byte("1/1/0.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err = OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	keys := a.Keys()
+	sort.Strings(keys)
+	if diff := cmp.Diff([]string{"0/0/0.png", "1/0/0.png", "1/0/1.png", "1/1/0.png"}, keys); diff != "" {
+		t.Error(diff)
+	}
+	for key, want := range map[string]string{
+		"0/0/0.png": "replaced",
+		"1/0/1.png": "1/0/1.png",
+		"1/1/0.png": "1/1/0.png",
+	} {
+		got, err := a.Read(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got Read(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestOpenArchive_Unclosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tiles.archive")
+
+	a, err := CreateArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Write("key", []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenArchive(path)
+	if err == nil {
+		t.Error("got OpenArchive() error nil for archive without index")
+	}
+	_ = a.Close()
+}
@@ -1,6 +1,7 @@
 package sun
 
 import (
+	"fmt"
 	"github.com/willbeason/worldproc/pkg/geodesic"
 	"math"
 )
@@ -8,6 +9,9 @@ import (
 type Light interface {
 	VisualIntensity(vector geodesic.Vector) float64
 	AltitudeAzimuth(angle geodesic.Angle) geodesic.Angle
+	// Direction returns the unit vector pointing toward the light from the
+	// point on the surface of the planet in the direction of vector.
+	Direction(vector geodesic.Vector) geodesic.Vector
 }
 
 type Constant struct {}
@@ -24,6 +28,11 @@ func (s Constant) VisualIntensity(_ geodesic.Vector) float64 {
 	return 1.0
 }
 
+// Direction is straight up everywhere, as the light is always overhead.
+func (s Constant) Direction(v geodesic.Vector) geodesic.Vector {
+	return v.Normalize()
+}
+
 type Directional struct {
 	// Sun is the directional vector from the planet's core to the Sun.
 	Sun geodesic.Vector
@@ -80,6 +89,32 @@ func (s *Directional) Set(date float64) {
 	s.Sun = s.SunAngle.Vector()
 }
 
+// DaysPerYear is how many days Set takes the planet to orbit the Sun.
+const DaysPerYear = 360
+
+// FormatDate returns date, in days since spring equinox year 0 as Set takes
+// it, as the year, day of the year and time on the prime meridian.
+func FormatDate(date float64) string {
+	// Set starts at noon on the prime meridian.
+	minutes := int(math.Floor((date + 0.5) * 24 * 60))
+	days := minutes / (24 * 60)
+	if minutes < 0 && minutes%(24*60) != 0 {
+		days--
+	}
+	minutes -= days * 24 * 60
+	year := days / DaysPerYear
+	if days < 0 && days%DaysPerYear != 0 {
+		year--
+	}
+	days -= year * DaysPerYear
+	return fmt.Sprintf("Year %d Day %d %02d:%02d", year, days, minutes/60, minutes%60)
+}
+
+// Direction is the same everywhere, as the Sun is far from the planet.
+func (s *Directional) Direction(_ geodesic.Vector) geodesic.Vector {
+	return s.Sun
+}
+
 func (s *Directional) VisualIntensity(v geodesic.Vector) float64 {
 	dot := s.Sun.Dot(v)
 	dot *= 2
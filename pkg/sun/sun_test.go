@@ -334,3 +334,23 @@ func TestDirectional_DeclinationAzimuth_AfterNoon(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatDate(t *testing.T) {
+	tcs := []struct {
+		date float64
+		want string
+	}{
+		{0, "Year 0 Day 0 12:00"},
+		{0.25, "Year 0 Day 0 18:00"},
+		{0.5, "Year 0 Day 1 00:00"},
+		{12.3, "Year 0 Day 12 19:12"},
+		{DaysPerYear + 1, "Year 1 Day 1 12:00"},
+		{-1, "Year -1 Day 359 12:00"},
+	}
+
+	for _, tc := range tcs {
+		if got := FormatDate(tc.date); got != tc.want {
+			t.Errorf("got FormatDate(%v) = %q, want %q", tc.date, got, tc.want)
+		}
+	}
+}
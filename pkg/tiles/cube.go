@@ -0,0 +1,79 @@
+package tiles
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/render"
+	"math"
+)
+
+// CubeFaces names the faces of a cube map in the order of the OpenGL cube map
+// targets: the faces facing +X, -X, +Y, -Y, +Z and -Z. Each face is oriented
+// as OpenGL expects when seen from the center of the sphere, with the
+// planet's axes used as is, so north is +Z.
+var CubeFaces = []string{"px", "nx", "py", "ny", "pz", "nz"}
+
+// cubeFace projects the sphere from its center onto a face of the cube around
+// it.
+type cubeFace struct {
+	screen render.Screen
+	face   int
+}
+
+var _ render.Projector = cubeFace{}
+
+func (c cubeFace) Project(x, y float64) (geodesic.Angle, bool) {
+	px, py := c.screen.Position(x, y)
+	// a is across the face from left to right and b down it from the top.
+	a := 2*(px+0.5)/float64(c.screen.Width) - 1
+	b := 2*(py+0.5)/float64(c.screen.Height) - 1
+
+	var v geodesic.Vector
+	switch c.face {
+	case 0:
+		v = geodesic.Vector{X: 1, Y: -b, Z: -a}
+	case 1:
+		v = geodesic.Vector{X: -1, Y: -b, Z: a}
+	case 2:
+		v = geodesic.Vector{X: a, Y: 1, Z: b}
+	case 3:
+		v = geodesic.Vector{X: a, Y: -1, Z: -b}
+	case 4:
+		v = geodesic.Vector{X: a, Y: -b, Z: 1}
+	default:
+		v = geodesic.Vector{X: -a, Y: -b, Z: -1}
+	}
+	return v.Normalize().Angle(), true
+}
+
+func (c cubeFace) Inverse(angle geodesic.Angle) (float64, float64, bool) {
+	v := angle.Vector()
+
+	// The face is the axis v is closest to, scaled to reach it.
+	var major, a, b float64
+	switch c.face {
+	case 0:
+		major, a, b = v.X, -v.Z, -v.Y
+	case 1:
+		major, a, b = -v.X, v.Z, -v.Y
+	case 2:
+		major, a, b = v.Y, v.X, v.Z
+	case 3:
+		major, a, b = -v.Y, v.X, -v.Z
+	case 4:
+		major, a, b = v.Z, v.X, -v.Y
+	default:
+		major, a, b = -v.Z, -v.X, -v.Y
+	}
+	if major <= 0 {
+		return 0, 0, false
+	}
+	a, b = a/major, b/major
+	if math.Abs(a) > 1 || math.Abs(b) > 1 {
+		return 0, 0, false
+	}
+
+	px := (a+1)/2*float64(c.screen.Width) - 0.5
+	py := (b+1)/2*float64(c.screen.Height) - 0.5
+	x, y := c.screen.Coordinates(px, py)
+	return x, y, true
+}
@@ -0,0 +1,234 @@
+package tiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/store"
+	"github.com/willbeason/worldproc/pkg/sun"
+	"image"
+	"image/png"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// DefaultTileSize is the width and height of tiles in pixels unless a
+// Renderer says otherwise.
+const DefaultTileSize = 256
+
+// MetadataKey is the key Pyramid stores Metadata at.
+const MetadataKey = "metadata.json"
+
+// Metadata describes a pyramid of tiles to map viewers.
+type Metadata struct {
+	Scheme   string `json:"scheme"`
+	TMS      bool   `json:"tms,omitempty"`
+	Format   string `json:"format"`
+	TileSize int    `json:"tileSize"`
+	MinZoom  int    `json:"minZoom"`
+	MaxZoom  int    `json:"maxZoom"`
+	// Layer is the layer the tiles show, or empty for terrain.
+	Layer string `json:"layer,omitempty"`
+}
+
+// Renderer renders images of a planet.
+//
+// It is safe for concurrent use once any of its methods has been called, and
+// its fields must not be changed afterward.
+type Renderer struct {
+	Planet *planet.Planet
+	// Sphere is the sphere whose cells Planet has values for.
+	Sphere *geodesic.Geodesic
+
+	// Light shades terrain, or if nil it is lit evenly from above.
+	Light sun.Light
+	// Layer, if set, is the layer of Planet to paint with ColorScale instead
+	// of terrain. Without a Layer, ColorScale colors the land, or
+	// render.LandColorScale does if it is nil.
+	Layer      string
+	ColorScale *render.ColorScale
+
+	// TileSize is the width and height of tiles in pixels, or DefaultTileSize
+	// if zero.
+	TileSize int
+
+	once    sync.Once
+	sampler *geodesic.Sampler
+	shades  []float64
+}
+
+func (r *Renderer) tileSize() int {
+	if r.TileSize == 0 {
+		return DefaultTileSize
+	}
+	return r.TileSize
+}
+
+// Render renders the planet onto screen with projector.
+func (r *Renderer) Render(screen render.Screen, projector render.Projector) (*image.RGBA, error) {
+	if len(r.Planet.Heights) != len(r.Sphere.Centers) {
+		return nil, fmt.Errorf("planet has %d cells but sphere has %d", len(r.Planet.Heights), len(r.Sphere.Centers))
+	}
+	r.once.Do(func() {
+		r.sampler = geodesic.NewSampler(r.Sphere)
+		if r.Layer == "" {
+			light := r.Light
+			if light == nil {
+				light = sun.Constant{}
+			}
+			r.shades = planet.DefaultHillshade.Shade(r.Planet, r.Sphere, light)
+		}
+	})
+
+	cells := render.NewCellMapFromSampler(render.Project(screen, projector), r.sampler)
+	if r.Layer != "" {
+		return planet.RenderLayer(r.Planet, r.Layer, cells, r.ColorScale)
+	}
+	land := r.ColorScale
+	if land == nil {
+		land = render.LandColorScale
+	}
+	return planet.RenderShadedTerrain(r.Planet, cells, r.shades, land), nil
+}
+
+// Tile renders tile t of scheme.
+func (r *Renderer) Tile(scheme Scheme, t Tile) (*image.RGBA, error) {
+	if !contains(scheme, t) {
+		return nil, fmt.Errorf("%s has no tile %v", scheme.Name(), t)
+	}
+	size := r.tileSize()
+	screen := render.Screen{Width: size, Height: size}
+	return r.Render(screen, tileProjector{screen: screen, scheme: scheme, tile: t})
+}
+
+// Load returns tile t of scheme as a PNG from s, rendering and storing it
+// first if s doesn't have it yet.
+func (r *Renderer) Load(s store.Store, scheme Scheme, t Tile) ([]byte, error) {
+	key := scheme.Key(t)
+	data, err := s.Read(key)
+	if err == nil {
+		return data, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	img, err := r.Tile(scheme, t)
+	if err != nil {
+		return nil, err
+	}
+	data, err = encodePNG(img)
+	if err != nil {
+		return nil, err
+	}
+	return data, s.Write(key, data)
+}
+
+// Pyramid renders every tile of scheme from minZoom to maxZoom in parallel
+// and stores them in s, along with Metadata describing them.
+func (r *Renderer) Pyramid(s store.Store, scheme Scheme, minZoom, maxZoom int) error {
+	var tiles []Tile
+	for zoom := minZoom; zoom <= maxZoom; zoom++ {
+		columns, rows := scheme.Size(zoom)
+		for y := 0; y < rows; y++ {
+			for x := 0; x < columns; x++ {
+				tiles = append(tiles, Tile{Zoom: zoom, X: x, Y: y})
+			}
+		}
+	}
+
+	err := parallel(len(tiles), func(i int) error {
+		img, err := r.Tile(scheme, tiles[i])
+		if err != nil {
+			return err
+		}
+		data, err := encodePNG(img)
+		if err != nil {
+			return err
+		}
+		return s.Write(scheme.Key(tiles[i]), data)
+	})
+	if err != nil {
+		return err
+	}
+
+	metadata := Metadata{
+		Scheme:   scheme.Name(),
+		Format:   "png",
+		TileSize: r.tileSize(),
+		MinZoom:  minZoom,
+		MaxZoom:  maxZoom,
+		Layer:    r.Layer,
+	}
+	switch scheme := scheme.(type) {
+	case WebMercator:
+		metadata.TMS = scheme.TMS
+	case Equirectangular:
+		metadata.TMS = scheme.TMS
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return s.Write(MetadataKey, data)
+}
+
+// CubeMap renders the six faces of a cube map of the planet, each TileSize
+// pixels across, and stores them in s as px.png, nx.png, py.png, ny.png,
+// pz.png and nz.png.
+func (r *Renderer) CubeMap(s store.Store) error {
+	size := r.tileSize()
+	screen := render.Screen{Width: size, Height: size}
+	return parallel(len(CubeFaces), func(face int) error {
+		img, err := r.Render(screen, cubeFace{screen: screen, face: face})
+		if err != nil {
+			return err
+		}
+		data, err := encodePNG(img)
+		if err != nil {
+			return err
+		}
+		return s.Write(CubeFaces[face]+".png", data)
+	})
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, img)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parallel calls fn for every i from 0 to n, splitting them between one
+// worker per CPU, and returns the first error any call returns.
+func parallel(n int, fn func(i int) error) error {
+	indices := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+
+	var once sync.Once
+	var result error
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < runtime.NumCPU(); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				err := fn(i)
+				if err != nil {
+					once.Do(func() { result = err })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,169 @@
+// Package tiles renders planets as pyramids of map tiles and as cube maps,
+// for web map viewers and 3D engines.
+package tiles
+
+import (
+	"fmt"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/render"
+	"math"
+)
+
+// Tile is a tile of a Scheme. X counts columns from the west and Y counts rows
+// from the north.
+type Tile struct {
+	Zoom, X, Y int
+}
+
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Zoom, t.X, t.Y)
+}
+
+// Scheme divides a map of the sphere into square tiles, with each zoom level
+// dividing each tile of the previous one into four.
+type Scheme interface {
+	// Name identifies the scheme to map viewers.
+	Name() string
+	// Size returns how many columns and rows of tiles there are at zoom.
+	Size(zoom int) (columns, rows int)
+	// Locate returns the point at u,v across tile t, where 0,0 is the
+	// north-west corner of the tile and 1,1 the south-east corner.
+	Locate(t Tile, u, v float64) geodesic.Angle
+	// Place is the inverse of Locate. ok is false if a is not on t.
+	Place(t Tile, a geodesic.Angle) (u, v float64, ok bool)
+	// Key returns the key tile t is stored at.
+	Key(t Tile) string
+}
+
+// contains returns whether t is one of the tiles of s.
+func contains(s Scheme, t Tile) bool {
+	if t.Zoom < 0 {
+		return false
+	}
+	columns, rows := s.Size(t.Zoom)
+	return t.X >= 0 && t.X < columns && t.Y >= 0 && t.Y < rows
+}
+
+// key returns the usual path of tile t, with rows counted from the south if
+// tms is set.
+func key(s Scheme, t Tile, tms bool) string {
+	if tms {
+		_, rows := s.Size(t.Zoom)
+		t.Y = rows - 1 - t.Y
+	}
+	return fmt.Sprintf("%d/%d/%d.png", t.Zoom, t.X, t.Y)
+}
+
+// place returns the position of global coordinates gx,gy, which count tiles
+// across the whole map, within t.
+func place(t Tile, gx, gy float64) (float64, float64, bool) {
+	u, v := gx-float64(t.X), gy-float64(t.Y)
+	return u, v, u >= 0 && u <= 1 && v >= 0 && v <= 1
+}
+
+// WebMercator is the scheme of most web maps, with a single square tile of
+// the Mercator projection at zoom 0 reaching about 85 degrees north and south.
+// Tiles are stored at zoom/x/y.png, with rows counted from the north, or from
+// the south if TMS is set.
+type WebMercator struct {
+	TMS bool
+}
+
+var _ Scheme = WebMercator{}
+
+func (WebMercator) Name() string {
+	return "webmercator"
+}
+
+func (WebMercator) Size(zoom int) (int, int) {
+	return 1 << zoom, 1 << zoom
+}
+
+func (WebMercator) Locate(t Tile, u, v float64) geodesic.Angle {
+	n := float64(int(1) << t.Zoom)
+	x := (float64(t.X) + u) / n
+	y := (float64(t.Y) + v) / n
+	return geodesic.Angle{
+		Theta: math.Atan(math.Sinh(math.Pi * (1 - 2*y))),
+		Phi:   2*math.Pi*x - math.Pi,
+	}
+}
+
+func (WebMercator) Place(t Tile, a geodesic.Angle) (float64, float64, bool) {
+	n := float64(int(1) << t.Zoom)
+	x := (a.Phi/math.Pi + 1) / 2
+	y := (1 - math.Log(math.Tan(math.Pi/4+a.Theta/2))/math.Pi) / 2
+	if math.IsNaN(y) || math.IsInf(y, 0) {
+		return 0, 0, false
+	}
+	return place(t, x*n, y*n)
+}
+
+func (w WebMercator) Key(t Tile) string {
+	return key(w, t, w.TMS)
+}
+
+// Equirectangular is the scheme of maps of longitude and latitude, such as
+// render.Equirectangular draws, with two square tiles side by side at zoom 0
+// covering the whole sphere. Tiles are stored as for WebMercator.
+type Equirectangular struct {
+	TMS bool
+}
+
+var _ Scheme = Equirectangular{}
+
+func (Equirectangular) Name() string {
+	return "equirectangular"
+}
+
+func (Equirectangular) Size(zoom int) (int, int) {
+	return 2 << zoom, 1 << zoom
+}
+
+func (Equirectangular) Locate(t Tile, u, v float64) geodesic.Angle {
+	n := float64(int(1) << t.Zoom)
+	return geodesic.Angle{
+		Theta: math.Pi/2 - math.Pi*(float64(t.Y)+v)/n,
+		Phi:   math.Pi*(float64(t.X)+u)/n - math.Pi,
+	}
+}
+
+func (Equirectangular) Place(t Tile, a geodesic.Angle) (float64, float64, bool) {
+	n := float64(int(1) << t.Zoom)
+	x := (a.Phi + math.Pi) / math.Pi * n
+	y := (math.Pi/2 - a.Theta) / math.Pi * n
+	return place(t, x, y)
+}
+
+func (e Equirectangular) Key(t Tile) string {
+	return key(e, t, e.TMS)
+}
+
+// tileProjector projects tile t of scheme onto screen.
+//
+// Unlike the projectors in render, tiles have north at the top of the image,
+// as map viewers expect.
+type tileProjector struct {
+	screen render.Screen
+	scheme Scheme
+	tile   Tile
+}
+
+var _ render.Projector = tileProjector{}
+
+func (p tileProjector) Project(x, y float64) (geodesic.Angle, bool) {
+	// Work in pixels so the edges of neighboring tiles meet exactly.
+	px, py := p.screen.Position(x, y)
+	u := (px + 0.5) / float64(p.screen.Width)
+	v := (py + 0.5) / float64(p.screen.Height)
+	return p.scheme.Locate(p.tile, u, v), true
+}
+
+func (p tileProjector) Inverse(a geodesic.Angle) (float64, float64, bool) {
+	u, v, ok := p.scheme.Place(p.tile, a)
+	if !ok {
+		return 0, 0, false
+	}
+	x, y := p.screen.Coordinates(u*float64(p.screen.Width)-0.5, v*float64(p.screen.Height)-0.5)
+	return x, y, true
+}
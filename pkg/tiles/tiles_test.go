@@ -0,0 +1,248 @@
+package tiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"github.com/willbeason/worldproc/pkg/planet"
+	"github.com/willbeason/worldproc/pkg/render"
+	"github.com/willbeason/worldproc/pkg/store"
+	"image/png"
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestScheme_Place(t *testing.T) {
+	schemes := []Scheme{WebMercator{}, Equirectangular{}}
+
+	for _, scheme := range schemes {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			for zoom := 0; zoom <= 3; zoom++ {
+				columns, rows := scheme.Size(zoom)
+				for y := 0; y < rows; y++ {
+					for x := 0; x < columns; x++ {
+						tile := Tile{Zoom: zoom, X: x, Y: y}
+						for _, uv := range [][2]float64{{0.1, 0.2}, {0.5, 0.5}, {0.9, 0.7}} {
+							a := scheme.Locate(tile, uv[0], uv[1])
+							u, v, ok := scheme.Place(tile, a)
+							if !ok {
+								t.Fatalf("got Place(%v, %v) not on tile", tile, a)
+							}
+							if math.Abs(u-uv[0]) > 1e-9 || math.Abs(v-uv[1]) > 1e-9 {
+								t.Fatalf("got Place(%v, Locate(%v)) = %g, %g", tile, uv, u, v)
+							}
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestScheme_Edges(t *testing.T) {
+	schemes := []Scheme{WebMercator{}, Equirectangular{}}
+
+	for _, scheme := range schemes {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			// The east edge of a tile is the west edge of the next, and the
+			// south edge the north edge of the one below.
+			tile := Tile{Zoom: 2, X: 1, Y: 1}
+			east := Tile{Zoom: 2, X: 2, Y: 1}
+			south := Tile{Zoom: 2, X: 1, Y: 2}
+			for _, f := range []float64{0, 0.3, 1} {
+				if diff := cmp.Diff(scheme.Locate(tile, 1, f), scheme.Locate(east, 0, f), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+					t.Error(diff)
+				}
+				if diff := cmp.Diff(scheme.Locate(tile, f, 1), scheme.Locate(south, f, 0), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+					t.Error(diff)
+				}
+			}
+
+			// North is at the top.
+			if a, b := scheme.Locate(tile, 0.5, 0), scheme.Locate(tile, 0.5, 1); a.Theta <= b.Theta {
+				t.Errorf("got top of tile at %g, bottom at %g, want top further north", a.Theta, b.Theta)
+			}
+		})
+	}
+}
+
+func TestScheme_Key(t *testing.T) {
+	tcs := []struct {
+		name   string
+		scheme Scheme
+		tile   Tile
+		want   string
+	}{
+		{name: "xyz", scheme: WebMercator{}, tile: Tile{Zoom: 2, X: 1, Y: 0}, want: "2/1/0.png"},
+		{name: "tms", scheme: WebMercator{TMS: true}, tile: Tile{Zoom: 2, X: 1, Y: 0}, want: "2/1/3.png"},
+		{name: "equirectangular tms", scheme: Equirectangular{TMS: true}, tile: Tile{Zoom: 1, X: 3, Y: 0}, want: "1/3/1.png"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.scheme.Key(tc.tile); got != tc.want {
+				t.Errorf("got Key(%v) = %q, want %q", tc.tile, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTileProjector(t *testing.T) {
+	screen := render.Screen{Width: 8, Height: 8}
+	p := tileProjector{screen: screen, scheme: Equirectangular{}, tile: Tile{Zoom: 1, X: 1, Y: 0}}
+
+	// The centers of the corner pixels are half a pixel in from the corners of
+	// the tile, which reaches from 90 degrees west to the prime meridian and
+	// from the north pole to the equator.
+	x, y := screen.Coordinates(0, 0)
+	got, _ := p.Project(x, y)
+	want := geodesic.Angle{Theta: math.Pi/2 - math.Pi/32, Phi: -math.Pi/2 + math.Pi/32}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Error(diff)
+	}
+
+	gotX, gotY, ok := p.Inverse(got)
+	if !ok || math.Abs(gotX-x) > 1e-12 || math.Abs(gotY-y) > 1e-12 {
+		t.Errorf("got Inverse() = %g, %g, %t, want %g, %g, true", gotX, gotY, ok, x, y)
+	}
+}
+
+func TestCubeFace(t *testing.T) {
+	screen := render.Screen{Width: 16, Height: 16}
+
+	// Every direction is on exactly one face, and projects back to itself.
+	for _, theta := range []float64{-1.4, -0.5, 0.1, 0.7, 1.5} {
+		for _, phi := range []float64{-3, -1.9, -0.4, 0.6, 2.2} {
+			a := geodesic.Angle{Theta: theta, Phi: phi}
+			found := 0
+			for face := range CubeFaces {
+				c := cubeFace{screen: screen, face: face}
+				x, y, ok := c.Inverse(a)
+				if !ok {
+					continue
+				}
+				found++
+				got, _ := c.Project(x, y)
+				if diff := cmp.Diff(a.Vector(), got.Vector(), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+					t.Errorf("face %s: %s", CubeFaces[face], diff)
+				}
+			}
+			if found != 1 {
+				t.Errorf("got %v on %d faces, want 1", a, found)
+			}
+		}
+	}
+}
+
+func newTestRenderer() *Renderer {
+	sphere := geodesic.Chamfer(geodesic.Dodecahedron())
+	p := &planet.Planet{
+		Heights: make([]float64, len(sphere.Centers)),
+		Waters:  make([]float64, len(sphere.Centers)),
+		Flows:   make([]float64, len(sphere.Centers)),
+	}
+	for i, c := range sphere.Centers {
+		p.Heights[i] = c.Z
+	}
+	return &Renderer{Planet: p, Sphere: sphere, TileSize: 16}
+}
+
+func TestRenderer_Pyramid(t *testing.T) {
+	r := newTestRenderer()
+	s := store.NewMemory()
+
+	err := r.Pyramid(s, WebMercator{}, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"0/0/0.png", "1/0/0.png", "1/1/0.png", "1/0/1.png", "1/1/1.png"} {
+		data, err := s.Read(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := img.Bounds().Dx(); got != 16 {
+			t.Errorf("got %s %d pixels wide, want %d", key, got, 16)
+		}
+	}
+
+	data, err := s.Read(MetadataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Metadata
+	err = json.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{Scheme: "webmercator", Format: "png", TileSize: 16, MinZoom: 0, MaxZoom: 1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRenderer_Load(t *testing.T) {
+	r := newTestRenderer()
+	s := store.NewMemory()
+	tile := Tile{Zoom: 1, X: 2, Y: 1}
+
+	got, err := r.Load(s, Equirectangular{}, tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.Read("1/2/1.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, stored) {
+		t.Error("got Load() different from the stored tile")
+	}
+
+	// Later loads return the stored tile rather than rendering it again.
+	err = s.Write("1/2/1.png", []byte("cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.Load(s, Equirectangular{}, tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "cached" {
+		t.Errorf("got Load() = %q, want %q", got, "cached")
+	}
+
+	_, err = r.Load(s, Equirectangular{}, Tile{Zoom: 1, X: 4, Y: 0})
+	if err == nil {
+		t.Error("got no error loading a tile outside the scheme")
+	}
+}
+
+func TestRenderer_CubeMap(t *testing.T) {
+	r := newTestRenderer()
+	s := store.NewMemory()
+
+	err := r.CubeMap(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, face := range CubeFaces {
+		_, err := s.Read(face + ".png")
+		if err == nil {
+			got = append(got, face)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"nx", "ny", "nz", "px", "py", "pz"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
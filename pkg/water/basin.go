@@ -0,0 +1,489 @@
+package water
+
+import (
+	"container/heap"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+	"sort"
+)
+
+// Basin is a depression in the surface of a sphere, which holds water until it
+// overflows into a neighboring basin.
+//
+// Basins form a tree. Its leaves are the basins around each local minimum, and
+// every other basin is a pair of basins which have filled and overflowed into
+// each other.
+type Basin struct {
+	// Minimum is the lowest cell of the basin.
+	Minimum int
+	// Children are the two basins merged into this one, or both -1 for a
+	// basin around a single local minimum.
+	Children [2]int
+	// Parent is the basin this one merges into, or -1 if it never overflows.
+	Parent int
+	// Level is the height at which Children overflow into each other, or the
+	// height of Minimum for a basin around a single local minimum.
+	Level float64
+
+	// Spill is the cell the basin overflows into its sibling over, or -1 if
+	// it never overflows. Water in the basin reaches the height of Spill, the
+	// Level of Parent, before it does.
+	Spill int
+	// Capacity is how much water the basin holds before it overflows.
+	Capacity float64
+
+	// entry is the cell on this basin's side of the pass to its sibling,
+	// which water overflowing from the sibling enters.
+	entry int
+	// first and last are the range of the positions of the leaves under this
+	// basin in Basins.leafCells.
+	first, last int
+}
+
+// IsLeaf returns whether b is the basin around a single local minimum.
+func (b *Basin) IsLeaf() bool {
+	return b.Children[0] < 0
+}
+
+// Basins are the basins of the surface of a sphere and the cells which drain
+// into each of them.
+type Basins struct {
+	// Labels holds the leaf basin each cell drains into.
+	Labels []int
+	// Basins holds the leaf basins, followed by the basins merging them in
+	// order of increasing Level, so every basin is after its children.
+	Basins []Basin
+	// Roots are the basins which never overflow, one for each connected part
+	// of the sphere.
+	Roots []int
+
+	heights []float64
+	// cells holds every cell, ordered so the cells of each basin are
+	// contiguous, and leafCells holds where the cells of the leaf at each
+	// position start in cells.
+	cells     []int
+	leafCells []int
+}
+
+// cellLevel is a cell and the height of the water covering it.
+type cellLevel struct {
+	cell  int
+	level float64
+}
+
+// levelHeap is a min-heap of cells by the height of the water covering them.
+type levelHeap []cellLevel
+
+func (h levelHeap) Len() int {
+	return len(h)
+}
+
+func (h levelHeap) Less(i, j int) bool {
+	if h[i].level != h[j].level {
+		return h[i].level < h[j].level
+	}
+	return h[i].cell < h[j].cell
+}
+
+func (h levelHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *levelHeap) Push(x interface{}) {
+	*h = append(*h, x.(cellLevel))
+}
+
+func (h *levelHeap) Pop() interface{} {
+	old := *h
+	result := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return result
+}
+
+// Fill returns heights with every depression filled to the level at which it
+// overflows toward one of outlets, as by rising water entering at outlets.
+// If outlets is empty, the lowest cell is the outlet, as water on a planet
+// without an ocean drains toward its lowest point.
+//
+// Cells the outlets can't reach keep their heights.
+func Fill(heights []float64, sphere *geodesic.Geodesic, outlets []int) []float64 {
+	filled, _, _ := flood(heights, sphere, outlets)
+	return filled
+}
+
+// flood raises water from outlets over heights, as Fill does. It also returns
+// the neighbor whose water first covered each cell, or the cell itself for
+// outlets and cells the water never reaches, and the cells in the order the
+// water covered them.
+func flood(heights []float64, sphere *geodesic.Geodesic, outlets []int) (filled []float64, from []int, order []int) {
+	filled = make([]float64, len(heights))
+	copy(filled, heights)
+	from = make([]int, len(heights))
+	for i := range from {
+		from[i] = i
+	}
+	if len(heights) == 0 {
+		return filled, from, nil
+	}
+
+	if len(outlets) == 0 {
+		lowest := 0
+		for i, h := range heights {
+			if h < heights[lowest] {
+				lowest = i
+			}
+		}
+		outlets = []int{lowest}
+	}
+
+	visited := make([]bool, len(heights))
+	h := &levelHeap{}
+	for _, o := range outlets {
+		if !visited[o] {
+			visited[o] = true
+			heap.Push(h, cellLevel{cell: o, level: heights[o]})
+		}
+	}
+
+	order = make([]int, 0, len(heights))
+	for h.Len() > 0 {
+		c := heap.Pop(h).(cellLevel)
+		order = append(order, c.cell)
+		for _, n := range sphere.Faces[c.cell].Neighbors {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			filled[n] = math.Max(heights[n], c.level)
+			from[n] = c.cell
+			heap.Push(h, cellLevel{cell: n, level: filled[n]})
+		}
+	}
+	return filled, from, order
+}
+
+// pass is the lowest crossing between two leaf basins, over the edge between
+// cells.
+type pass struct {
+	basins [2]int
+	cells  [2]int
+	height float64
+}
+
+// FindBasins finds the basins of heights on sphere.
+//
+// Every cell with no lower neighbor starts a basin, which rising water then
+// floods outward from in order of height, so each cell is labelled with the
+// basin whose water first covers it. Two basins merge at the lowest edge
+// between them, at the height of the higher of its cells.
+func FindBasins(heights []float64, sphere *geodesic.Geodesic) *Basins {
+	b := &Basins{
+		Labels:  make([]int, len(heights)),
+		heights: heights,
+	}
+	for i := range b.Labels {
+		b.Labels[i] = -1
+	}
+
+	h := &levelHeap{}
+	for i, hi := range heights {
+		isMinimum := true
+		for _, n := range sphere.Faces[i].Neighbors {
+			if heights[n] < hi {
+				isMinimum = false
+				break
+			}
+		}
+		if isMinimum {
+			b.Labels[i] = len(b.Basins)
+			b.Basins = append(b.Basins, Basin{
+				Minimum:  i,
+				Children: [2]int{-1, -1},
+				Parent:   -1,
+				Level:    hi,
+				Spill:    -1,
+			})
+			heap.Push(h, cellLevel{cell: i, level: hi})
+		}
+	}
+
+	// Find the lowest pass between each pair of adjacent basins.
+	passes := make(map[[2]int]*pass)
+	for h.Len() > 0 {
+		c := heap.Pop(h).(cellLevel)
+		label := b.Labels[c.cell]
+		for _, n := range sphere.Faces[c.cell].Neighbors {
+			switch b.Labels[n] {
+			case -1:
+				b.Labels[n] = label
+				heap.Push(h, cellLevel{cell: n, level: math.Max(heights[n], c.level)})
+			case label:
+			default:
+				p := pass{
+					basins: [2]int{label, b.Labels[n]},
+					cells:  [2]int{c.cell, n},
+					height: math.Max(heights[c.cell], heights[n]),
+				}
+				if p.basins[0] > p.basins[1] {
+					p.basins[0], p.basins[1] = p.basins[1], p.basins[0]
+					p.cells[0], p.cells[1] = p.cells[1], p.cells[0]
+				}
+				if existing := passes[p.basins]; existing == nil || p.height < existing.height {
+					passes[p.basins] = &p
+				}
+			}
+		}
+	}
+
+	sorted := make([]*pass, 0, len(passes))
+	for _, p := range passes {
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].height != sorted[j].height {
+			return sorted[i].height < sorted[j].height
+		}
+		if sorted[i].basins[0] != sorted[j].basins[0] {
+			return sorted[i].basins[0] < sorted[j].basins[0]
+		}
+		return sorted[i].basins[1] < sorted[j].basins[1]
+	})
+
+	b.merge(sorted)
+	b.orderCells()
+	return b
+}
+
+// merge joins basins at passes, from lowest to highest, into a tree.
+func (b *Basins) merge(passes []*pass) {
+	nLeaves := len(b.Basins)
+	// Union-find over leaves, with the basin each set has merged into and
+	// the number and total height of its cells below the current pass.
+	parents := make([]int, nLeaves)
+	tops := make([]int, nLeaves)
+	counts := make([]float64, nLeaves)
+	sums := make([]float64, nLeaves)
+	for i := range parents {
+		parents[i] = i
+		tops[i] = i
+	}
+	find := func(i int) int {
+		for parents[i] != i {
+			parents[i] = parents[parents[i]]
+			i = parents[i]
+		}
+		return i
+	}
+
+	cells := make([]int, len(b.heights))
+	for i := range cells {
+		cells[i] = i
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return b.heights[cells[i]] < b.heights[cells[j]]
+	})
+
+	next := 0
+	for _, p := range passes {
+		for ; next < len(cells) && b.heights[cells[next]] < p.height; next++ {
+			r := find(b.Labels[cells[next]])
+			counts[r]++
+			sums[r] += b.heights[cells[next]]
+		}
+
+		roots := [2]int{find(p.basins[0]), find(p.basins[1])}
+		if roots[0] == roots[1] {
+			continue
+		}
+
+		merged := len(b.Basins)
+		basin := Basin{
+			Parent: -1,
+			Level:  p.height,
+			Spill:  -1,
+		}
+		spill := p.cells[0]
+		if b.heights[p.cells[1]] > b.heights[spill] {
+			spill = p.cells[1]
+		}
+		for i, r := range roots {
+			child := &b.Basins[tops[r]]
+			child.Parent = merged
+			child.Spill = spill
+			child.Capacity = counts[r]*p.height - sums[r]
+			child.entry = p.cells[i]
+			basin.Children[i] = tops[r]
+		}
+		basin.Minimum = b.Basins[basin.Children[0]].Minimum
+		if other := b.Basins[basin.Children[1]].Minimum; b.heights[other] < b.heights[basin.Minimum] {
+			basin.Minimum = other
+		}
+		b.Basins = append(b.Basins, basin)
+
+		parents[roots[0]] = roots[1]
+		counts[roots[1]] += counts[roots[0]]
+		sums[roots[1]] += sums[roots[0]]
+		tops[roots[1]] = merged
+	}
+
+	for i := range b.Basins {
+		if b.Basins[i].Parent < 0 {
+			b.Roots = append(b.Roots, i)
+		}
+	}
+}
+
+// orderCells orders cells so the cells of every basin are contiguous.
+func (b *Basins) orderCells() {
+	// Number the leaves depth first, so the leaves under each basin are
+	// numbered consecutively.
+	positions := make([]int, len(b.Basins))
+	nLeaves := 0
+	stack := append([]int(nil), b.Roots...)
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if b.Basins[i].IsLeaf() {
+			positions[i] = nLeaves
+			nLeaves++
+			continue
+		}
+		stack = append(stack, b.Basins[i].Children[1], b.Basins[i].Children[0])
+	}
+
+	for i := range b.Basins {
+		basin := &b.Basins[i]
+		if basin.IsLeaf() {
+			basin.first, basin.last = positions[i], positions[i]+1
+			continue
+		}
+		left, right := b.Basins[basin.Children[0]], b.Basins[basin.Children[1]]
+		basin.first = left.first
+		if right.first < basin.first {
+			basin.first = right.first
+		}
+		basin.last = left.last
+		if right.last > basin.last {
+			basin.last = right.last
+		}
+	}
+
+	b.leafCells = make([]int, nLeaves+1)
+	for _, label := range b.Labels {
+		b.leafCells[b.Basins[label].first+1]++
+	}
+	for i := 1; i < len(b.leafCells); i++ {
+		b.leafCells[i] += b.leafCells[i-1]
+	}
+	b.cells = make([]int, len(b.Labels))
+	next := append([]int(nil), b.leafCells...)
+	for c, label := range b.Labels {
+		pos := b.Basins[label].first
+		b.cells[next[pos]] = c
+		next[pos]++
+	}
+}
+
+// Cells returns the cells which drain into basin i. The result must not be
+// modified.
+func (b *Basins) Cells(i int) []int {
+	return b.cells[b.leafCells[b.Basins[i].first]:b.leafCells[b.Basins[i].last]]
+}
+
+// contains returns whether cell drains into basin i.
+func (b *Basins) contains(i, cell int) bool {
+	pos := b.Basins[b.Labels[cell]].first
+	return b.Basins[i].first <= pos && pos < b.Basins[i].last
+}
+
+// inflow is water entering a basin at a cell.
+type inflow struct {
+	cell   int
+	volume float64
+}
+
+// Lakes returns the lakes waters forms once it has flowed downhill and
+// settled, filling basins until they overflow into their neighbors.
+//
+// Every cell is in exactly one lake, with no water until Lake.Equalize
+// spreads the water of the lake over its cells.
+func (b *Basins) Lakes(waters []float64) []Lake {
+	// volumes holds the water which falls in each basin.
+	volumes := make([]float64, len(b.Basins))
+	for c, w := range waters {
+		volumes[b.Labels[c]] += w
+	}
+	for i, basin := range b.Basins {
+		if !basin.IsLeaf() {
+			volumes[i] = volumes[basin.Children[0]] + volumes[basin.Children[1]]
+		}
+	}
+
+	type pour struct {
+		basin   int
+		inflows []inflow
+	}
+	var stack []pour
+	for _, root := range b.Roots {
+		stack = append(stack, pour{basin: root})
+	}
+
+	var result []Lake
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		basin := b.Basins[p.basin]
+
+		total := volumes[p.basin]
+		for _, in := range p.inflows {
+			total += in.volume
+		}
+		if basin.IsLeaf() {
+			result = append(result, b.lake(p.basin, total))
+			continue
+		}
+
+		// Water entering the basin enters whichever child it falls in.
+		var children [2]pour
+		var childVolumes [2]float64
+		for i, child := range basin.Children {
+			children[i].basin = child
+			childVolumes[i] = volumes[child]
+		}
+		for _, in := range p.inflows {
+			i := 1
+			if b.contains(basin.Children[0], in.cell) {
+				i = 0
+			}
+			children[i].inflows = append(children[i].inflows, in)
+			childVolumes[i] += in.volume
+		}
+
+		// If both children fill, they overflow into each other and make one
+		// lake. Otherwise at most one overflows into the other.
+		left, right := b.Basins[basin.Children[0]], b.Basins[basin.Children[1]]
+		if childVolumes[0]+childVolumes[1] >= left.Capacity+right.Capacity {
+			result = append(result, b.lake(p.basin, total))
+			continue
+		}
+		if excess := childVolumes[0] - left.Capacity; excess > 0 {
+			children[1].inflows = append(children[1].inflows, inflow{cell: right.entry, volume: excess})
+			children[0].inflows = append(children[0].inflows, inflow{cell: left.entry, volume: -excess})
+		} else if excess := childVolumes[1] - right.Capacity; excess > 0 {
+			children[0].inflows = append(children[0].inflows, inflow{cell: left.entry, volume: excess})
+			children[1].inflows = append(children[1].inflows, inflow{cell: right.entry, volume: -excess})
+		}
+		stack = append(stack, children[0], children[1])
+	}
+	return result
+}
+
+// lake returns the lake covering the cells of basin i, holding volume water.
+func (b *Basins) lake(i int, volume float64) Lake {
+	l := Lake{}
+	for _, c := range b.Cells(i) {
+		l.Add(c, b.heights[c], 0)
+	}
+	l.WaterVolume = volume
+	return l
+}
@@ -0,0 +1,171 @@
+package water
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// chain returns a graph of n cells, each linked to the next.
+func chain(n int) *geodesic.Geodesic {
+	g := &geodesic.Geodesic{
+		Faces: make([]geodesic.Node, n),
+		Edges: map[geodesic.Edge]int{},
+	}
+	for i := 0; i < n-1; i++ {
+		g.Link(i, i+1)
+	}
+	return g
+}
+
+func TestFindBasins(t *testing.T) {
+	heights := []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0}
+	b := FindBasins(heights, chain(len(heights)))
+
+	wantLabels := []int{0, 0, 1, 2, 2, 2}
+	if diff := cmp.Diff(wantLabels, b.Labels); diff != "" {
+		t.Error(diff)
+	}
+
+	// The basins at 0.0 and 0.2 fill and overflow into each other at 0.5,
+	// and then into the basin at 0.1 at 0.8.
+	want := []Basin{
+		{Minimum: 0, Children: [2]int{-1, -1}, Parent: 3, Level: 0.0, Spill: 1, Capacity: 0.5},
+		{Minimum: 2, Children: [2]int{-1, -1}, Parent: 3, Level: 0.2, Spill: 1, Capacity: 0.3},
+		{Minimum: 4, Children: [2]int{-1, -1}, Parent: 4, Level: 0.1, Spill: 3, Capacity: 0.7},
+		{Minimum: 0, Children: [2]int{0, 1}, Parent: 4, Level: 0.5, Spill: 3, Capacity: 0.8 + 0.3 + 0.6},
+		{Minimum: 0, Children: [2]int{3, 2}, Parent: -1, Level: 0.8, Spill: -1},
+	}
+	if diff := cmp.Diff(want, b.Basins, cmpopts.IgnoreUnexported(Basin{}), cmpopts.EquateApprox(0.0, 1e-9)); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]int{4}, b.Roots); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]int{0, 1, 2}, b.Cells(3), cmpopts.SortSlices(func(l, r int) bool { return l < r })); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFill(t *testing.T) {
+	tcs := []struct {
+		name    string
+		heights []float64
+		outlets []int
+		want    []float64
+	}{
+		{
+			name:    "lowest cell",
+			heights: []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0},
+			want:    []float64{0.0, 0.5, 0.5, 0.8, 0.8, 1.0},
+		},
+		{
+			name:    "outlet",
+			heights: []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0},
+			outlets: []int{5},
+			want:    []float64{1.0, 1.0, 1.0, 1.0, 1.0, 1.0},
+		},
+		{
+			name:    "two outlets",
+			heights: []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0},
+			outlets: []int{0, 4},
+			want:    []float64{0.0, 0.5, 0.5, 0.8, 0.1, 1.0},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Fill(tc.heights, chain(len(tc.heights)), tc.outlets)
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEqualize_Basins(t *testing.T) {
+	tcs := []struct {
+		name    string
+		heights []float64
+		water   []float64
+		want    []float64
+	}{
+		{
+			// The lake at 0.2 overflows at 0.5 into the basin at 0.0, which
+			// holds it below the pass to the lake at 0.1.
+			name:    "overflow into neighbor",
+			heights: []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0},
+			water:   []float64{0.0, 0.0, 0.5, 0.0, 0.0, 0.0},
+			want:    []float64{0.2, 0.0, 0.3, 0.0, 0.0, 0.0},
+		},
+		{
+			// Water overflowing from the basin at 0.1 fills the basin at 0.2
+			// until it overflows into the basin at 0.0.
+			name:    "overflow into nested basin",
+			heights: []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0},
+			water:   []float64{0.0, 0.0, 0.0, 0.0, 1.0, 0.0},
+			want:    []float64{0.0, 0.0, 0.3, 0.0, 0.7, 0.0},
+		},
+		{
+			name:    "overflow through nested basin",
+			heights: []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0},
+			water:   []float64{0.0, 0.0, 0.0, 0.0, 1.2, 0.0},
+			want:    []float64{0.2, 0.0, 0.3, 0.0, 0.7, 0.0},
+		},
+		{
+			name:    "everything full",
+			heights: []float64{0.0, 0.5, 0.2, 0.8, 0.1, 1.0},
+			water:   []float64{0.0, 1.0, 0.0, 0.0, 1.6, 0.0},
+			want:    []float64{0.84, 0.34, 0.64, 0.04, 0.74, 0.0},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			Equalize(tc.water, tc.heights, chain(len(tc.heights)))
+
+			if diff := cmp.Diff(tc.want, tc.water, cmpopts.EquateApprox(0.0, 1e-9)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEqualize_Sphere(t *testing.T) {
+	g := geodesic.New(4, true)[4]
+	r := rand.New(rand.NewSource(0))
+	heights := make([]float64, len(g.Faces))
+	waters := make([]float64, len(g.Faces))
+	before := 0.0
+	for i := range heights {
+		heights[i] = r.Float64()
+		waters[i] = 0.01 * r.Float64()
+		before += waters[i]
+	}
+
+	Equalize(waters, heights, g)
+
+	after := 0.0
+	for i, w := range waters {
+		after += w
+		if w < 0 {
+			t.Fatalf("got %v water in cell %d, want at least 0", w, i)
+		}
+		if w == 0 {
+			continue
+		}
+		// Water at rest never stands above a neighbor it could flow onto.
+		for _, n := range g.Faces[i].Neighbors {
+			if surface := heights[n] + waters[n]; heights[i]+w > surface+1e-9 {
+				t.Fatalf("water in cell %d at %v stands above cell %d at %v", i, heights[i]+w, n, surface)
+			}
+		}
+	}
+	if math.Abs(after-before) > 1e-9 {
+		t.Errorf("got %v water after equalizing, want %v", after, before)
+	}
+}
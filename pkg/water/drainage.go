@@ -0,0 +1,185 @@
+package water
+
+import (
+	"github.com/willbeason/worldproc/pkg/geodesic"
+)
+
+// Drainage is the network of channels water flows along over the surface of
+// a sphere, from each cell to the one it drains into, until it reaches an
+// outlet.
+type Drainage struct {
+	// Receivers holds the cell each cell drains into, or the cell itself for
+	// outlets.
+	Receivers []int
+	// Order holds every cell, ordered so each is before the cell it drains
+	// into.
+	Order []int
+	// Filled holds the heights with every depression filled, as Fill returns
+	// them.
+	Filled []float64
+}
+
+// Drain finds where water falling on heights flows as it runs downhill
+// toward outlets, as with Fill.
+//
+// Each cell drains into its lowest neighbor lower than it, which since cells
+// are about equally far apart is the steepest way down. Water in depressions
+// instead runs toward where the depression overflows, and on flats toward
+// the nearest lower ground, so every cell drains into an outlet.
+func Drain(heights []float64, sphere *geodesic.Geodesic, outlets []int) *Drainage {
+	filled, from, order := flood(heights, sphere, outlets)
+
+	d := &Drainage{
+		Receivers: make([]int, len(heights)),
+		Order:     make([]int, 0, len(heights)),
+		Filled:    filled,
+	}
+	reached := make([]bool, len(heights))
+	for _, c := range order {
+		reached[c] = true
+	}
+	for i := range d.Receivers {
+		d.Receivers[i] = from[i]
+		if !reached[i] || from[i] == i {
+			continue
+		}
+		lowest := filled[i]
+		for _, n := range sphere.Faces[i].Neighbors {
+			if filled[n] < lowest {
+				d.Receivers[i] = n
+				lowest = filled[n]
+			}
+		}
+	}
+
+	// Water covers cells from outlets upward, so every cell is covered after
+	// the cell it drains into. Cells the water never reaches drain nowhere.
+	for i, isReached := range reached {
+		if !isReached {
+			d.Order = append(d.Order, i)
+		}
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		d.Order = append(d.Order, order[i])
+	}
+	return d
+}
+
+// Accumulate returns how much water flows through each cell when rain falls
+// on each cell: its own rain, and all the rain upstream of it.
+func (d *Drainage) Accumulate(rain []float64) []float64 {
+	result := make([]float64, len(rain))
+	copy(result, rain)
+	for _, c := range d.Order {
+		if r := d.Receivers[c]; r != c {
+			result[r] += result[c]
+		}
+	}
+	return result
+}
+
+// StreamOrders returns the Strahler order of each cell of the channels which
+// carry at least threshold of accumulation, or 0 for cells which carry less.
+//
+// Channels rising from cells nothing flows into are of order 1. Where two
+// channels of the same order meet, the channel below is of one order more,
+// and otherwise it is of the greater order of those flowing into it.
+func (d *Drainage) StreamOrders(accumulation []float64, threshold float64) []int {
+	result := make([]int, len(accumulation))
+	// maxIn is the greatest order flowing into each cell, and nMaxIn how many
+	// channels of that order do.
+	maxIn := make([]int, len(accumulation))
+	nMaxIn := make([]int, len(accumulation))
+
+	for _, c := range d.Order {
+		if accumulation[c] < threshold {
+			continue
+		}
+		order := 1
+		if maxIn[c] > 0 {
+			order = maxIn[c]
+			if nMaxIn[c] > 1 {
+				order++
+			}
+		}
+		result[c] = order
+
+		r := d.Receivers[c]
+		switch {
+		case r == c:
+		case order > maxIn[r]:
+			maxIn[r], nMaxIn[r] = order, 1
+		case order == maxIn[r]:
+			nMaxIn[r]++
+		}
+	}
+	return result
+}
+
+// River is a stretch of channel of a single Strahler order.
+type River struct {
+	// Cells are the cells the river flows through, from its source to its
+	// mouth. Rivers of order 1 rise at a cell nothing flows into, and others
+	// where two rivers of one less order meet. Rivers end where they flow
+	// into a river of a greater order, or into an outlet.
+	Cells []int `json:"cells"`
+	// Order is the Strahler order of the river.
+	Order int `json:"order"`
+	// Into is the index of the river this one flows into at its mouth, or -1
+	// if it flows into an outlet.
+	Into int `json:"into"`
+}
+
+// Source returns the cell the river starts at.
+func (r *River) Source() int {
+	return r.Cells[0]
+}
+
+// Mouth returns the cell the river ends at.
+func (r *River) Mouth() int {
+	return r.Cells[len(r.Cells)-1]
+}
+
+// Rivers returns the channels which carry at least threshold of
+// accumulation, divided into rivers as StreamOrders orders them.
+func (d *Drainage) Rivers(accumulation []float64, threshold float64) []River {
+	orders := d.StreamOrders(accumulation, threshold)
+
+	// Rivers start at channels with no channel of the same order flowing
+	// into them.
+	continued := make([]bool, len(orders))
+	for c, order := range orders {
+		if r := d.Receivers[c]; order > 0 && r != c && orders[r] == order {
+			continued[r] = true
+		}
+	}
+
+	var result []River
+	// riverOf holds the river each cell is in, other than at its mouth.
+	riverOf := make([]int, len(orders))
+	for _, c := range d.Order {
+		if orders[c] == 0 || continued[c] || d.Receivers[c] == c {
+			continue
+		}
+
+		river := River{Cells: []int{c}, Order: orders[c], Into: -1}
+		riverOf[c] = len(result)
+		for cur := c; ; {
+			next := d.Receivers[cur]
+			river.Cells = append(river.Cells, next)
+			if orders[next] != river.Order || d.Receivers[next] == next {
+				break
+			}
+			riverOf[next] = len(result)
+			cur = next
+		}
+		result = append(result, river)
+	}
+
+	for i := range result {
+		if mouth := result[i].Mouth(); orders[mouth] > 0 && d.Receivers[mouth] != mouth {
+			result[i].Into = riverOf[mouth]
+		}
+	}
+	return result
+}
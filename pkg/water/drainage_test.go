@@ -0,0 +1,116 @@
+package water
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/willbeason/worldproc/pkg/geodesic"
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	// The depression at 0.8 overflows over the cell at 0.9.
+	heights := []float64{1.0, 0.8, 0.9, 0.5, 0.0}
+	d := Drain(heights, chain(len(heights)), nil)
+
+	if diff := cmp.Diff([]int{1, 2, 3, 4, 4}, d.Receivers); diff != "" {
+		t.Error(diff)
+	}
+	got := d.Accumulate([]float64{1, 1, 1, 1, 1})
+	if diff := cmp.Diff([]float64{1, 2, 3, 4, 5}, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+// confluence returns a graph of two streams which meet, and are then joined
+// by a third before reaching the outlet, cell 5.
+func confluence() ([]float64, *geodesic.Geodesic) {
+	g := &geodesic.Geodesic{
+		Faces: make([]geodesic.Node, 6),
+		Edges: map[geodesic.Edge]int{},
+	}
+	for _, e := range [][2]int{{0, 2}, {1, 2}, {2, 3}, {4, 3}, {3, 5}} {
+		g.Link(e[0], e[1])
+	}
+	return []float64{1.0, 1.0, 0.6, 0.3, 0.7, 0.0}, g
+}
+
+func TestDrainage_StreamOrders(t *testing.T) {
+	heights, g := confluence()
+	d := Drain(heights, g, []int{5})
+	accumulation := d.Accumulate([]float64{1, 1, 1, 1, 1, 1})
+
+	tcs := []struct {
+		name      string
+		threshold float64
+		want      []int
+	}{
+		{
+			name:      "every cell",
+			threshold: 1,
+			want:      []int{1, 1, 2, 2, 1, 2},
+		},
+		{
+			name:      "large channels",
+			threshold: 2,
+			want:      []int{0, 0, 1, 1, 0, 1},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := d.StreamOrders(accumulation, tc.threshold)
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestDrainage_Rivers(t *testing.T) {
+	heights, g := confluence()
+	d := Drain(heights, g, []int{5})
+
+	got := d.Rivers(d.Accumulate([]float64{1, 1, 1, 1, 1, 1}), 1)
+
+	want := []River{
+		{Cells: []int{1, 2}, Order: 1, Into: 3},
+		{Cells: []int{0, 2}, Order: 1, Into: 3},
+		{Cells: []int{4, 3}, Order: 1, Into: 3},
+		{Cells: []int{2, 3, 5}, Order: 2, Into: -1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDrainage_Sphere(t *testing.T) {
+	g := geodesic.New(4, true)[4]
+	heights := make([]float64, len(g.Centers))
+	rain := make([]float64, len(g.Centers))
+	for i, c := range g.Centers {
+		heights[i] = c.Z + 0.1*c.X*c.Y
+		rain[i] = 1
+	}
+
+	d := Drain(heights, g, nil)
+	accumulation := d.Accumulate(rain)
+
+	// Everything drains to the lowest cell.
+	lowest := 0
+	for i, h := range heights {
+		if h < heights[lowest] {
+			lowest = i
+		}
+	}
+	if got, want := accumulation[lowest], float64(len(heights)); got != want {
+		t.Errorf("got %v flowing into the lowest cell, want %v", got, want)
+	}
+
+	for _, r := range d.Rivers(accumulation, 10) {
+		for i, c := range r.Cells[1:] {
+			if d.Receivers[r.Cells[i]] != c {
+				t.Fatalf("river %v doesn't follow receivers", r.Cells)
+			}
+		}
+	}
+}
@@ -2,7 +2,6 @@ package water
 
 import (
 	"github.com/willbeason/worldproc/pkg/geodesic"
-	"math"
 	"sort"
 )
 
@@ -33,7 +32,7 @@ func (l *Lake) Merge(other *Lake) {
 		return
 	}
 	l.IndexHeights = append(l.IndexHeights, other.IndexHeights...)
-	l.WaterVolume += l.WaterVolume
+	l.WaterVolume += other.WaterVolume
 }
 
 func (l *Lake) Equalize() {
@@ -75,87 +74,14 @@ func (l *Lake) Equalize() {
 	}
 }
 
+// Equalize moves waters downhill over heights, filling basins until they
+// overflow into their neighbors, until it is at rest.
 func Equalize(waters, heights []float64, sphere *geodesic.Geodesic) {
-	var lakes []Lake
-
-	// Visit nodes from lowest to highest.
-	toVisit := make([]Ordinal, len(heights))
-	for i, h := range heights {
-		toVisit[i].Index = i
-		toVisit[i].Height = h
-	}
-	sort.Slice(toVisit, func(i, j int) bool {
-		return toVisit[i].Height < toVisit[j].Height
-	})
-
-	visited := make(map[int]bool, len(sphere.Centers))
-
-	for _, v := range toVisit {
-		if visited[v.Index] {
-			continue
-		}
-		newLake := visitEqualize(v.Index, waters, heights, visited, sphere)
-		if newLake != nil && newLake.WaterVolume > 0.0 {
-			lakes = append(lakes, *newLake)
-		}
-	}
-
-	sort.Slice(lakes, func(i, j int) bool {
-		return len(lakes[i].IndexHeights) > len(lakes[j].IndexHeights)
-	})
-	for _, l := range lakes {
+	basins := FindBasins(heights, sphere)
+	for _, l := range basins.Lakes(waters) {
 		l.Equalize()
 		for _, ih := range l.IndexHeights {
-			waters[ih.Index] += ih.Water
+			waters[ih.Index] = ih.Water
 		}
 	}
 }
-
-func visitEqualize(i int, waters, heights []float64, visited map[int]bool, sphere *geodesic.Geodesic) *Lake {
-	if visited[i] {
-		return nil
-	}
-	visited[i] = true
-
-	l := &Lake{}
-
-	var toVisit OrdinalList
-	hi := heights[i]
-	toVisit.Insert(Ordinal{Index: i, Height: hi})
-	// We are guaranteed that every neighbor is the same level or higher.
-	for _, n := range sphere.Faces[i].Neighbors {
-		toVisit.Insert(Ordinal{Index: n, Height: hi})
-	}
-
-	for cell := toVisit.Pop(); cell != nil; cell = toVisit.Pop() {
-		if i != cell.Index && visited[cell.Index] {
-			continue
-		}
-		visited[cell.Index] = true
-
-		// cell.Height actually records the minimum height of the water we may
-		// take from this cell.
-		hc := heights[cell.Index]
-		w := math.Max(0.0, hc+waters[cell.Index]-cell.Height)
-		w = math.Min(w, waters[cell.Index])
-		waters[cell.Index] -= w
-		l.Add(cell.Index, math.Max(hc, cell.Height), w)
-
-		// hi tracks the level at which we can start taking water from a cell
-		// adjacent to the current cell.
-		hi := math.Max(cell.Height, hc)
-		for _, n := range sphere.Faces[cell.Index].Neighbors {
-			if visited[n] {
-				// Don't add already-visited cells.
-				continue
-			}
-			if heights[n]+waters[n]-hi < 0.001 {
-				// Don't add cells we can't possibly take water from.
-				continue
-			}
-			toVisit.Insert(Ordinal{Index: n, Height: hi})
-		}
-	}
-
-	return l
-}
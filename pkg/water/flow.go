@@ -4,6 +4,13 @@ import (
 	"github.com/willbeason/worldproc/pkg/geodesic"
 )
 
+// Rain adds amt of water to every cell, which runs downhill over the surface
+// of the water until it pools, adding amt to the flow of every cell it
+// passes through.
+//
+// Rain moves the water already there, so repeated rain fills depressions.
+// To find how much water flows through each cell of fixed terrain, use Drain
+// and Drainage.Accumulate, which are much faster.
 func Rain(amt float64, waters, heights, flow []float64, sphere *geodesic.Geodesic) {
 	for i := range waters {
 		rainFlow(amt, i, waters, heights, flow, sphere)
@@ -11,24 +18,24 @@ func Rain(amt float64, waters, heights, flow []float64, sphere *geodesic.Geodesi
 }
 
 func rainFlow(amt float64, idx int, waters, heights, flow []float64, sphere *geodesic.Geodesic) {
-	flow[idx] += amt
+	for {
+		flow[idx] += amt
 
-	flowTo := idx
-	flowToWh := waters[idx] + heights[idx]
-	for _, n := range sphere.Faces[idx].Neighbors {
-		nwh := waters[n] + heights[n]
-		if nwh < flowToWh {
-			flowTo = n
-			flowToWh = nwh
+		flowTo := idx
+		flowToWh := waters[idx] + heights[idx]
+		for _, n := range sphere.Faces[idx].Neighbors {
+			nwh := waters[n] + heights[n]
+			if nwh < flowToWh {
+				flowTo = n
+				flowToWh = nwh
+			}
 		}
-	}
 
-	if flowTo != idx {
-		rainFlow(amt, flowTo, waters, heights, flow, sphere)
-		return
+		if flowTo == idx {
+			// All goes to this index.
+			waters[idx] += amt
+			return
+		}
+		idx = flowTo
 	}
-
-	// All goes to this index.
-	waters[idx] += amt
-	return
 }